@@ -25,6 +25,12 @@ func main() {
 
 	configMapper := models.NewConfigurationMapperFromPath(absPath)
 	captin := core.NewCaptin(*configMapper)
+	// Held events are only restored with a persistent store, see Captin.SetStore
+	if restored, err := captin.RestoreHeldEvents(context.Background()); err != nil {
+		log.WithFields(log.Fields{"error": err}).Error("Failed to restore held events")
+	} else {
+		log.Infof("Restored %d held events", restored)
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -50,6 +56,7 @@ func main() {
 		time.Sleep(1 * time.Second)
 		if captin.IsRunning() != true {
 			log.Println("Tasks released")
+			captin.Close()
 			break
 		}
 	}
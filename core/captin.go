@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	statsd "github.com/joeycumines/statsd"
 	destination_filters "github.com/shoplineapp/captin/v2/destinations/filters"
 	d "github.com/shoplineapp/captin/v2/dispatcher"
 	interfaces "github.com/shoplineapp/captin/v2/interfaces"
@@ -39,12 +40,16 @@ type Captin struct {
 	throttler            interfaces.ThrottleInterface
 }
 
-// NewCaptin - Create Captin instance with default http senders and time throttler
+// NewCaptin - Create Captin instance with default http, beanstalkd, sns, grpc, chat and smtp senders and time throttler
 func NewCaptin(configMap interfaces.ConfigMapperInterface) *Captin {
 	store := stores.NewMemoryStore()
 	senderMapping := map[string]interfaces.EventSenderInterface{
 		"http":       &senders.HTTPEventSender{},
 		"beanstalkd": &senders.BeanstalkdSender{},
+		"sns":        &senders.SnsSender{},
+		"grpc":       senders.NewGRPCSender(),
+		"chat":       &senders.ChatSender{},
+		"smtp":       &senders.SMTPSender{},
 	}
 	c := Captin{
 		Status:    STATUS_READY,
@@ -54,6 +59,7 @@ func NewCaptin(configMap interfaces.ConfigMapperInterface) *Captin {
 			destination_filters.SourceFilter{},
 			destination_filters.DesiredHookFilter{},
 			destination_filters.EnvironmentFilter{},
+			destination_filters.SamplingFilter{},
 		},
 		SenderMapping: senderMapping,
 		store:         store,
@@ -112,6 +118,44 @@ func (c *Captin) SetSenderMapping(senderMapping map[string]interfaces.EventSende
 	c.SenderMapping = senderMapping
 }
 
+// SetStatsdClient - Report metrics of default filters and http sender to statsd, e.g. events sampled out by SamplingFilter
+// Filters and senders set afterwards should be given their own client.
+func (c *Captin) SetStatsdClient(client *statsd.Client) {
+	c.filters = withStatsdClient(c.filters, client)
+	c.dispatchFilters = withStatsdClient(c.dispatchFilters, client)
+	if sender, ok := c.SenderMapping["http"].(*senders.HTTPEventSender); ok {
+		sender.StatsdClient = client
+	}
+}
+
+func withStatsdClient(filters []destination_filters.DestinationFilterInterface, client *statsd.Client) []destination_filters.DestinationFilterInterface {
+	result := make([]destination_filters.DestinationFilterInterface, len(filters))
+	for i, filter := range filters {
+		if sampling, ok := filter.(destination_filters.SamplingFilter); ok {
+			sampling.StatsdClient = client
+			filter = sampling
+		}
+		result[i] = filter
+	}
+	return result
+}
+
+// Close - Release resources of senders on shutdown, e.g. flush pending batches
+func (c *Captin) Close() []error {
+	errors := []error{}
+	for key, sender := range c.SenderMapping {
+		closer, ok := sender.(interfaces.EventSenderCloserInterface)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			cLogger.WithFields(log.Fields{"sender": key, "error": err}).Error("Failed to close sender")
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
 func (c Captin) IsRunning() bool {
 	return c.Status == STATUS_RUNNING || d.PendingJobCount() > 0
 }
@@ -139,17 +183,29 @@ func (c *Captin) Execute(ctx context.Context, ie interfaces.IncomingEventInterfa
 	}).Info("Ready to dispatch event with destinations")
 
 	// Create dispatcher and dispatch events
-	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, c.SenderMapping)
-	dispatcher.SetFilters(c.dispatchFilters)
-	dispatcher.SetMiddlewares(c.dispatchMiddlewares)
-	dispatcher.SetErrorHandler(c.dispatchErrorHandler)
-	dispatcher.SetDelayer(c.dispatchDelayer)
+	dispatcher := c.newDispatcher(destinations)
 	dispatcher.Dispatch(ctx, e, c.store, c.throttler, c.DocumentStoreMapping)
 
 	errors := dispatcher.GetErrors()
 
-	cLogger.Debug(fmt.Sprintf("Captin event executed, %d destinations, %d failed, %d pending", len(destinations), len(errors), d.PendingJobCount()))
+	cLogger.Debug(fmt.Sprintf("Captin event executed, %d destinations, %d failed, %d pending, %d held", len(destinations), len(errors), d.PendingJobCount(), d.HeldEventCount()))
 
 	c.Status = STATUS_READY
 	return true, errors
 }
+
+// RestoreHeldEvents - Schedule release of events held outside delivery windows, should be called on start
+// so that events held before restart are not lost. Store should be persistent and implement
+// interfaces.StoreKeysInterface, events held in the default MemoryStore are lost on restart.
+func (c *Captin) RestoreHeldEvents(ctx context.Context) (int, error) {
+	return c.newDispatcher([]models.Destination{}).RestoreHeldEvents(ctx, c.ConfigMap, c.store, c.throttler, c.DocumentStoreMapping)
+}
+
+func (c *Captin) newDispatcher(destinations []models.Destination) *outgoing.Dispatcher {
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, c.SenderMapping)
+	dispatcher.SetFilters(c.dispatchFilters)
+	dispatcher.SetMiddlewares(c.dispatchMiddlewares)
+	dispatcher.SetErrorHandler(c.dispatchErrorHandler)
+	dispatcher.SetDelayer(c.dispatchDelayer)
+	return dispatcher
+}
@@ -16,3 +16,13 @@ type DestinationFilterInterface interface {
 	Run(ctx context.Context, e models.IncomingEvent, c models.Destination) (bool, error)
 	Applicable(ctx context.Context, e models.IncomingEvent, c models.Destination) bool
 }
+
+// DestinationDeliveredCallbackInterface - Optional interface for filters to be notified after event is delivered to destination
+type DestinationDeliveredCallbackInterface interface {
+	OnDelivered(ctx context.Context, e models.IncomingEvent, d models.Destination)
+}
+
+// DestinationFailedCallbackInterface - Optional interface for filters to be notified after event passed filters but failed to be delivered
+type DestinationFailedCallbackInterface interface {
+	OnFailed(ctx context.Context, e models.IncomingEvent, d models.Destination)
+}
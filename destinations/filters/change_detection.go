@@ -0,0 +1,144 @@
+package destination_filters
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var cdLogger = log.WithFields(log.Fields{"class": "ChangeDetectionFilter"})
+
+var _ DestinationFilterInterface = ChangeDetectionFilter{}
+var _ DestinationDeliveredCallbackInterface = ChangeDetectionFilter{}
+var _ DestinationFailedCallbackInterface = ChangeDetectionFilter{}
+
+// Times to compare with hash changed by concurrent events before giving up and delivering event
+const changeDetectionClaimAttempts = 3
+
+// ChangeDetectionFilter - Deliver event only when watched payload or document fields changed
+// Hash of watched fields is remembered per hook and target, it should be used as the last dispatch filter
+// so that target document is loaded and the hash is not claimed by events dropped by other filters.
+// With a store implementing StoreCompareAndSetInterface, hash is claimed atomically on filtering and released
+// when delivery fails, so that only one of concurrent events with the same watched fields is delivered.
+// Otherwise hash is remembered after delivery, and concurrent events with the same watched fields may all be delivered.
+type ChangeDetectionFilter struct {
+	Store interfaces.StoreInterface
+}
+
+// Run - Compare hash of watched fields with the last delivered one, and claim it when store supports compare and set
+func (f ChangeDetectionFilter) Run(ctx context.Context, e models.IncomingEvent, d models.Destination) (bool, error) {
+	hash, err := watchedFieldsHash(e, d)
+	if err != nil {
+		return true, err
+	}
+
+	key := watchedFieldsHashKey(ctx, f.Store, e, d)
+	store, claimable := f.Store.(interfaces.StoreCompareAndSetInterface)
+	for attempt := 1; ; attempt++ {
+		lastHash, exists, _, err := f.Store.Get(ctx, key)
+		if err != nil {
+			cdLogger.WithFields(log.Fields{"key": key, "error": err}).Error("Unable to get last delivered hash")
+			return true, err
+		}
+
+		if exists && lastHash == hash {
+			cdLogger.WithFields(log.Fields{"key": key, "hash": hash}).Debug("Watched fields unchanged. Destination ignored.")
+			trace.SpanFromContext(ctx).AddEvent("watched fields unchanged", trace.WithAttributes(
+				attribute.String("destination", d.Config.GetName()),
+				attribute.String("hash", hash),
+			))
+			return false, nil
+		}
+		if !claimable {
+			return true, nil
+		}
+
+		claimed, err := store.CompareAndSet(ctx, key, lastHash, hash, d.Config.GetWatchTTLValue())
+		if err != nil {
+			cdLogger.WithFields(log.Fields{"key": key, "error": err}).Error("Unable to claim hash")
+			return true, err
+		}
+		if claimed {
+			return true, nil
+		}
+		if attempt >= changeDetectionClaimAttempts {
+			cdLogger.WithFields(log.Fields{"key": key, "hash": hash}).Warn("Hash keeps changing by concurrent events, deliver without claiming")
+			return true, nil
+		}
+		// Hash is changed by concurrent event, compare with it again
+	}
+}
+
+// Applicable - Check if any field is watched
+func (f ChangeDetectionFilter) Applicable(ctx context.Context, e models.IncomingEvent, d models.Destination) bool {
+	return f.Store != nil && (len(d.Config.GetWatchPayloadAttrs()) > 0 || len(d.Config.GetWatchDocumentAttrs()) > 0)
+}
+
+// OnDelivered - Remember hash of watched fields after event is delivered
+func (f ChangeDetectionFilter) OnDelivered(ctx context.Context, e models.IncomingEvent, d models.Destination) {
+	if !f.Applicable(ctx, e, d) {
+		return
+	}
+	hash, err := watchedFieldsHash(e, d)
+	if err != nil {
+		return
+	}
+
+	key := watchedFieldsHashKey(ctx, f.Store, e, d)
+	_, err = f.Store.Set(ctx, key, hash, d.Config.GetWatchTTLValue())
+	if err != nil {
+		cdLogger.WithFields(log.Fields{"key": key, "error": err}).Error("Unable to store last delivered hash")
+	}
+}
+
+// OnFailed - Release hash claimed on filtering, so that retried event is not ignored
+// Hash delivered before the claim is not restored, next event of target is delivered even if unchanged.
+func (f ChangeDetectionFilter) OnFailed(ctx context.Context, e models.IncomingEvent, d models.Destination) {
+	store, claimable := f.Store.(interfaces.StoreCompareAndSetInterface)
+	if !claimable || !f.Applicable(ctx, e, d) {
+		return
+	}
+	hash, err := watchedFieldsHash(e, d)
+	if err != nil {
+		return
+	}
+
+	key := watchedFieldsHashKey(ctx, f.Store, e, d)
+	// Hash claimed by another event meanwhile is kept
+	if _, err := store.CompareAndSet(ctx, key, hash, "", 0); err != nil {
+		cdLogger.WithFields(log.Fields{"key": key, "error": err}).Error("Unable to release claimed hash")
+	}
+}
+
+func watchedFieldsHash(e models.IncomingEvent, d models.Destination) (string, error) {
+	watched := map[string]interface{}{}
+	if attrs := d.Config.GetWatchPayloadAttrs(); len(attrs) > 0 && e.Payload != nil {
+		watched["payload"] = helpers.IncludeFields(e.Payload, attrs)
+	}
+	if attrs := d.Config.GetWatchDocumentAttrs(); len(attrs) > 0 && e.TargetDocument != nil {
+		watched["document"] = helpers.IncludeFields(e.TargetDocument, attrs)
+	}
+
+	// json.Marshal sorts map keys, the result is stable for the same content
+	data, err := json.Marshal(watched)
+	if err != nil {
+		cdLogger.WithFields(log.Fields{"error": err}).Error("Unable to hash watched fields")
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Hash is kept per target instead of per event key, so that create and update events share the last delivered hash
+func watchedFieldsHashKey(ctx context.Context, s interfaces.StoreInterface, e models.IncomingEvent, d models.Destination) string {
+	keyEvent := models.IncomingEvent{Key: e.TargetType, TargetType: e.TargetType, TargetId: e.TargetId}
+	return s.DataKey(ctx, keyEvent, d, "", "-watched_hash")
+}
@@ -0,0 +1,81 @@
+package destination_filters
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	statsd "github.com/joeycumines/statsd"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var sLogger = log.WithFields(log.Fields{"class": "SamplingFilter"})
+
+// Number of buckets used for sampling, giving a precision of 0.01%
+const samplingBuckets = 10000
+
+var _ DestinationFilterInterface = SamplingFilter{}
+
+// SamplingFilter - Deliver only a fraction of events to destination, configured by sample_rate
+// Sampling is deterministic by sample_key (target_id by default, or trace_id),
+// so that the same target is always in or always out for a given rate.
+// Rate could be overridden by ENV HOOK_{Config Name}_SAMPLE_RATE for gradual rollout.
+type SamplingFilter struct {
+	StatsdClient *statsd.Client
+}
+
+func (f SamplingFilter) Run(ctx context.Context, e models.IncomingEvent, d models.Destination) (bool, error) {
+	rate := d.GetSampleRate()
+	key := samplingKey(e, d.Config.GetSampleKey())
+	bucket := samplingBucket(d.Config.GetName(), key)
+	sampled := float64(bucket) < rate*samplingBuckets
+
+	if !sampled {
+		sLogger.WithFields(log.Fields{
+			"hook_name":   d.Config.GetName(),
+			"sample_rate": rate,
+			"sample_key":  key,
+		}).Debug("Event sampled out. Destination ignored.")
+
+		trace.SpanFromContext(ctx).AddEvent("destination sampled out", trace.WithAttributes(
+			attribute.String("destination", d.Config.GetName()),
+			attribute.Float64("sample_rate", rate),
+			attribute.Int("sample_bucket", bucket),
+		))
+
+		if f.StatsdClient != nil {
+			f.StatsdClient.Increment(fmt.Sprintf("hook.filter.sampling.skipped,metricname=%s,hook=%s", d.Config.GetName(), d.Config.GetName()))
+		}
+	}
+
+	return sampled, nil
+}
+
+// Applicable - Only sample when rate is lower than 1
+func (f SamplingFilter) Applicable(ctx context.Context, e models.IncomingEvent, d models.Destination) bool {
+	return d.GetSampleRate() < 1
+}
+
+func samplingKey(e models.IncomingEvent, sampleKey string) string {
+	switch sampleKey {
+	case "trace_id":
+		return e.TraceId
+	default:
+		// Event without target falls back to trace ID, so that retries stay consistent
+		if e.TargetId == "" {
+			return e.TraceId
+		}
+		return e.TargetId
+	}
+}
+
+func samplingBucket(hook string, key string) int {
+	h := fnv.New64a()
+	h.Write([]byte(hook))
+	h.Write([]byte(":"))
+	h.Write([]byte(key))
+	return int(h.Sum64() % samplingBuckets)
+}
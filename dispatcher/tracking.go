@@ -1,12 +1,16 @@
 package dispatcher
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 var pendingJobCount int64 = 0
 
+var heldEventCounts = map[string]int64{}
+var muHeldEventCounts sync.Mutex
+
 func PendingJobCount() int64 {
 	return atomic.LoadInt64(&pendingJobCount)
 }
@@ -30,3 +34,33 @@ func TrackGoRoutine(f func()) {
 		defer atomic.AddInt64(&pendingJobCount, -1)
 	}()
 }
+
+// TrackHeldEvents - Track number of events held outside delivery window of a hook
+func TrackHeldEvents(hook string, delta int64) {
+	muHeldEventCounts.Lock()
+	defer muHeldEventCounts.Unlock()
+	heldEventCounts[hook] += delta
+	if heldEventCounts[hook] <= 0 {
+		delete(heldEventCounts, hook)
+	}
+}
+
+// HeldEventCounts - Number of events held outside delivery window, grouped by hook name
+func HeldEventCounts() map[string]int64 {
+	muHeldEventCounts.Lock()
+	defer muHeldEventCounts.Unlock()
+	counts := make(map[string]int64, len(heldEventCounts))
+	for hook, count := range heldEventCounts {
+		counts[hook] = count
+	}
+	return counts
+}
+
+// HeldEventCount - Total number of events held outside delivery window
+func HeldEventCount() int64 {
+	var total int64
+	for _, count := range HeldEventCounts() {
+		total += count
+	}
+	return total
+}
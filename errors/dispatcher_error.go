@@ -14,6 +14,8 @@ type DispatcherError struct {
 	Msg         string
 	Event       models.IncomingEvent
 	Destination models.Destination
+	// Response of destination, nil if the event was not sent over HTTP
+	Response *HTTPResponse
 }
 
 func (e DispatcherError) Error() string {
@@ -0,0 +1,10 @@
+package errors
+
+// HTTPResponse - Response of a failed HTTP delivery, kept on errors for troubleshooting
+type HTTPResponse struct {
+	StatusCode int
+	// Selected headers only, e.g. Retry-After
+	Headers map[string]string
+	// Truncated response body
+	Body string
+}
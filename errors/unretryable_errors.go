@@ -13,6 +13,8 @@ type UnretryableError struct {
 	Msg         string
 	Event       models.IncomingEvent
 	Destination models.Destination
+	// Response of destination, nil if the event was not sent over HTTP
+	Response *HTTPResponse
 }
 
 func (e UnretryableError) Error() string {
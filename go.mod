@@ -3,21 +3,30 @@ module github.com/shoplineapp/captin/v2
 go 1.15
 
 require (
-	github.com/aws/aws-sdk-go v1.34.34
+	github.com/Shopify/sarama v1.27.2
+	github.com/alicebob/miniredis/v2 v2.16.0
+	github.com/aws/aws-sdk-go v1.35.37
 	github.com/beanstalkd/go-beanstalk v0.0.0-20190515041346-390b03b3064a
+	github.com/emersion/go-smtp v0.16.0
+	github.com/go-redis/redis/v8 v8.11.4
 	github.com/google/uuid v1.2.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/joeycumines/statsd v1.0.1-0.20201117043332-bb35aa955658
+	github.com/klauspost/compress v1.13.6
 	github.com/konsorten/go-windows-terminal-sequences v1.0.2 // indirect
-	github.com/kr/pretty v0.1.0 // indirect
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826
+	github.com/nats-io/nats-server/v2 v2.3.0
+	github.com/nats-io/nats.go v1.11.0
+	github.com/rabbitmq/amqp091-go v1.1.0
 	github.com/robertkrimen/otto v0.0.0-20180617131154-15f95af6e78d
 	github.com/sirupsen/logrus v1.4.2
 	github.com/stretchr/testify v1.8.4
-	github.com/thoas/go-funk v0.7.0
-	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.46.1 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.46.1
 	go.opentelemetry.io/otel v1.21.0
 	go.opentelemetry.io/otel/trace v1.21.0
 	golang.org/x/sys v0.0.0-20220817070843-5a390386f1f2 // indirect
-	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	google.golang.org/grpc v1.40.0
+	google.golang.org/protobuf v1.26.0
 	gopkg.in/sourcemap.v1 v1.0.5 // indirect
 )
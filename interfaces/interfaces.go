@@ -16,6 +16,11 @@ type EventSenderInterface interface {
 	SendEvent(ctx context.Context, e IncomingEventInterface, d DestinationInterface) error
 }
 
+// EventSenderCloserInterface - Event sender holding resources to be released on shutdown, e.g. pending batches
+type EventSenderCloserInterface interface {
+	Close() error
+}
+
 // ThrottleInterface - interface for a throttle object
 // Throttle event flow:
 // Mutex Lock:		1
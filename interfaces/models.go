@@ -26,6 +26,7 @@ type DestinationInterface interface {
 	GetConfig() ConfigurationInterface
 	GetCallbackURL() string
 	GetSqsSenderConfig(key string) string
+	GetSnsSenderConfig(key string) string
 	GetDocumentStore() string
 }
 
@@ -55,4 +56,82 @@ type ConfigurationInterface interface {
 	GetIncludePayloadAttrs() []string
 	GetExcludePayloadAttrs() []string
 	GetExtras() map[string]string
+	GetSampleRate() float64
+	GetSampleKey() string
+	GetDeliveryTimezone() string
+	GetDeliveryWindows() []string
+	GetDeliveryBlackouts() []string
+	GetWatchPayloadAttrs() []string
+	GetWatchDocumentAttrs() []string
+	GetWatchTTLValue() time.Duration
+	GetSplitOn() string
+	GetSplitTargetIdField() string
+	GetTransform() string
+	GetRedactRules() map[string]string
+	GetRedactLogs() bool
+	GetHTTPSuccessCodes() []int
+	GetHTTPTimeoutValue() time.Duration
+	GetHTTPConnectTimeoutValue() time.Duration
+	GetHTTPTLSTimeoutValue() time.Duration
+	GetHTTPKeepAliveValue() time.Duration
+	GetHTTPMaxIdleConns() int
+	GetHTTPMaxIdleConnsPerHost() int
+	GetHTTPInsecureSkipVerify() bool
+	GetHTTPCABundle() string
+	GetHTTPEnableHTTP2() bool
+	GetSigningSecrets() []string
+	GetSigningScheme() string
+	GetSigningHeader() string
+	GetHTTPHeaders() map[string]string
+	GetHTTPAuth() string
+	GetHTTPAuthHeader() string
+	GetHTTPAuthUsername() string
+	GetHTTPAuthSecret() string
+	GetHTTPOAuth2TokenURL() string
+	GetHTTPOAuth2ClientID() string
+	GetHTTPOAuth2Scopes() []string
+	GetHTTPMethod() string
+	GetHTTPEncoding() string
+	GetHTTPBody() string
+	GetHTTPTLSCert() string
+	GetHTTPTLSKey() string
+	GetHTTPTLSServerName() string
+	GetHTTPTLSPins() []string
+	GetCompression() string
+	GetCompressionThreshold() int
+	GetMessageGroupID() string
+	GetDeduplicationID() string
+	GetSqsNativeDelay() bool
+	GetKafkaTopic() string
+	GetKafkaPartitionKey() string
+	GetKafkaAcks() string
+	GetKafkaIdempotent() bool
+	GetKafkaLingerValue() time.Duration
+	GetKafkaBatchSize() int
+	GetNatsSubject() string
+	GetNatsJetStream() bool
+	GetNatsAckTimeoutValue() time.Duration
+	GetAmqpExchange() string
+	GetAmqpRoutingKey() string
+	GetAmqpDeliveryMode() string
+	GetAmqpHeaders() map[string]string
+	GetAmqpConfirmTimeoutValue() time.Duration
+	GetRedisStream() string
+	GetRedisStreamMaxLen() int
+	GetRedisStreamTrimExact() bool
+	GetGRPCTimeoutValue() time.Duration
+	GetGRPCPlaintext() bool
+	GetPushChannel() string
+	GetChatFormat() string
+	GetChatTemplate() string
+	GetChatMinIntervalValue() time.Duration
+	GetSMTPFrom() string
+	GetSMTPTo() string
+	GetSMTPSubject() string
+	GetSMTPTextBody() string
+	GetSMTPHTMLBody() string
+	GetSMTPUsername() string
+	GetSMTPPassword() string
+	GetSMTPTLS() string
+	GetSMTPTimeoutValue() time.Duration
 }
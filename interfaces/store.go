@@ -25,3 +25,17 @@ type StoreInterface interface {
 
 	GetQueue(ctx context.Context, key string) (values []string, exists bool, ttl time.Duration, err error)
 }
+
+// StoreKeysInterface - Store able to list its keys, e.g. for restoring held events after restart
+// Restoring is only useful with a persistent store, contents of MemoryStore are lost on restart.
+type StoreKeysInterface interface {
+	// Keys - List keys ending with suffix
+	Keys(ctx context.Context, suffix string) ([]string, error)
+}
+
+// StoreCompareAndSetInterface - Store able to change value of key atomically, e.g. for claiming state before delivery
+type StoreCompareAndSetInterface interface {
+	// CompareAndSet - Set value with ttl only when current value equals old, empty old means key should not exist
+	// and empty value removes the key. Return false when current value is not old.
+	CompareAndSet(ctx context.Context, key string, old string, value string, ttl time.Duration) (bool, error)
+}
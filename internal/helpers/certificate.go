@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// LoadCertificate - Load certificate and private key in PEM, given by file paths or secret references
+func LoadCertificate(certRef string, keyRef string) (tls.Certificate, error) {
+	certPEM, err := readPEM(certRef)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to load certificate: %s", err)
+	}
+	keyPEM, err := readPEM(keyRef)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to load private key: %s", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid certificate or private key: %s", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return tls.Certificate{}, fmt.Errorf("invalid certificate: %s", err)
+		}
+	}
+	return cert, nil
+}
+
+// CertificateFilePath - Get file path of certificate reference, empty if it is not a file
+func CertificateFilePath(ref string) string {
+	if VerifySecretRef(ref) != nil {
+		return ref
+	}
+	if strings.HasPrefix(ref, secretRefFile) {
+		return strings.TrimPrefix(ref, secretRefFile)
+	}
+	return ""
+}
+
+// readPEM - Read PEM from secret reference, e.g. "env:PARTNER_CERT", or plain file path
+func readPEM(ref string) ([]byte, error) {
+	if VerifySecretRef(ref) == nil {
+		value, err := ResolveSecret(ref)
+		return []byte(value), err
+	}
+	return ioutil.ReadFile(ref)
+}
+
+// CertificatePin - Public key pin of certificate, "sha256/{base64 SHA-256 digest of SubjectPublicKeyInfo}"
+func CertificatePin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// VerifyCertificatePin - Check if public key pin is well-formed
+func VerifyCertificatePin(pin string) error {
+	if !strings.HasPrefix(pin, "sha256/") {
+		return fmt.Errorf("invalid pin %q, expected sha256/{base64 digest}", pin)
+	}
+	digest, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256/"))
+	if err != nil || len(digest) != sha256.Size {
+		return fmt.Errorf("invalid pin %q, expected sha256/{base64 digest}", pin)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms, named as HTTP Content-Encoding values
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Encoders are safe for concurrent EncodeAll calls, share them to reuse their buffers
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// VerifyCompression - Check if compression algorithm is supported
+func VerifyCompression(algorithm string) error {
+	switch algorithm {
+	case CompressionGzip, CompressionZstd:
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression %q", algorithm)
+	}
+}
+
+// Compress - Compress data with algorithm
+func Compress(data []byte, algorithm string) ([]byte, error) {
+	switch algorithm {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return nil, VerifyCompression(algorithm)
+	}
+}
+
+// Decompress - Decompress data compressed with algorithm
+func Decompress(data []byte, algorithm string) ([]byte, error) {
+	switch algorithm {
+	case CompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	case CompressionZstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	default:
+		return nil, VerifyCompression(algorithm)
+	}
+}
@@ -0,0 +1,188 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Field paths select fields of documents and payloads, e.g.
+//   - "customer.email": key of nested object, arrays along the path are selected element by element
+//   - "items[*].sku": every element of array, same as "items.sku"
+//   - "items[0]": element of array by index
+//   - "metadata.*_id": keys matched by glob, where "*" matches any characters and "?" matches one character
+//   - "metadata.a\.b": escaped dot, "\" also escapes "[", "*" and "?"
+
+// Index selecting all elements of array, i.e. "[*]"
+const allIndexes = -1
+
+// fieldSegment - Parsed segment of field path between dots
+type fieldSegment struct {
+	raw     string
+	key     string
+	glob    *regexp.Regexp
+	indexes []int
+}
+
+func (s fieldSegment) matchKey(key string) bool {
+	if s.glob != nil {
+		return s.glob.MatchString(key)
+	}
+	return s.key == key
+}
+
+func (s fieldSegment) matchIndex(selector int, i int) bool {
+	return selector == allIndexes || selector == i
+}
+
+// fieldTree - Compiled field paths merged by common prefix
+type fieldTree struct {
+	branches []*fieldBranch
+}
+
+type fieldBranch struct {
+	segment fieldSegment
+	// whole value is selected when a path ends at this branch
+	whole   bool
+	subtree *fieldTree
+}
+
+// Compiled field trees, keyed by joined field paths
+var fieldTreeCache sync.Map
+
+// VerifyFieldPath - Check if field path could be parsed
+func VerifyFieldPath(path string) error {
+	_, err := parseFieldPath(path)
+	return err
+}
+
+func compileFieldTree(fields []string) *fieldTree {
+	cacheKey := strings.Join(fields, "\x00")
+	if cached, ok := fieldTreeCache.Load(cacheKey); ok {
+		return cached.(*fieldTree)
+	}
+
+	tree := &fieldTree{}
+	for _, field := range fields {
+		segments, err := parseFieldPath(field)
+		if err != nil {
+			// Invalid paths are rejected on config load, ignore them here
+			continue
+		}
+		tree.add(segments)
+	}
+	fieldTreeCache.Store(cacheKey, tree)
+	return tree
+}
+
+func (t *fieldTree) add(segments []fieldSegment) {
+	var branch *fieldBranch
+	for _, b := range t.branches {
+		if b.segment.raw == segments[0].raw {
+			branch = b
+			break
+		}
+	}
+	if branch == nil {
+		branch = &fieldBranch{segment: segments[0], subtree: &fieldTree{}}
+		t.branches = append(t.branches, branch)
+	}
+
+	if len(segments) == 1 {
+		branch.whole = true
+		return
+	}
+	branch.subtree.add(segments[1:])
+}
+
+func parseFieldPath(path string) ([]fieldSegment, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty field path")
+	}
+
+	segments := []fieldSegment{}
+	runes := []rune(path)
+	current := fieldSegment{}
+	var key, pattern, raw strings.Builder
+	isGlob := false
+
+	closeSegment := func() error {
+		if key.Len() == 0 && !isGlob {
+			return fmt.Errorf("empty key in field path %q", path)
+		}
+		current.raw = raw.String()
+		current.key = key.String()
+		if isGlob {
+			current.glob = regexp.MustCompile("^" + pattern.String() + "$")
+		}
+		segments = append(segments, current)
+		current = fieldSegment{}
+		key.Reset()
+		pattern.Reset()
+		raw.Reset()
+		isGlob = false
+		return nil
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("dangling escape in field path %q", path)
+			}
+			i++
+			if len(current.indexes) > 0 {
+				return nil, fmt.Errorf("unexpected key after index in field path %q", path)
+			}
+			key.WriteRune(runes[i])
+			pattern.WriteString(regexp.QuoteMeta(string(runes[i])))
+			raw.WriteRune(r)
+			raw.WriteRune(runes[i])
+		case r == '.':
+			if err := closeSegment(); err != nil {
+				return nil, err
+			}
+		case r == '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unclosed bracket in field path %q", path)
+			}
+			selector := string(runes[i+1 : end])
+			raw.WriteString("[" + selector + "]")
+			if selector == "*" {
+				current.indexes = append(current.indexes, allIndexes)
+			} else {
+				index, err := strconv.Atoi(selector)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid index %q in field path %q", selector, path)
+				}
+				current.indexes = append(current.indexes, index)
+			}
+			i = end
+		case len(current.indexes) > 0:
+			return nil, fmt.Errorf("unexpected key after index in field path %q", path)
+		case r == '*':
+			isGlob = true
+			pattern.WriteString(".*")
+			raw.WriteRune(r)
+		case r == '?':
+			isGlob = true
+			pattern.WriteString(".")
+			raw.WriteRune(r)
+		default:
+			key.WriteRune(r)
+			pattern.WriteString(regexp.QuoteMeta(string(r)))
+			raw.WriteRune(r)
+		}
+	}
+	if err := closeSegment(); err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
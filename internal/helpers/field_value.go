@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Keys of field paths parsed by parseFieldKeys, keyed by field path
+var fieldKeysCache sync.Map
+
+// VerifyFieldKeyPath - Check if field path selects a single field by keys, see field_path.go for path syntax
+// Indexes and globs select many fields, so they are rejected.
+func VerifyFieldKeyPath(path string) error {
+	_, err := parseFieldKeys(path)
+	return err
+}
+
+// GetFieldValue - Get value of object by field path of keys, e.g. "items", "order.items" or "metadata.a\.b"
+func GetFieldValue(object map[string]interface{}, path string) (interface{}, bool) {
+	keys, err := parseFieldKeys(path)
+	if err != nil {
+		return nil, false
+	}
+	var current interface{} = object
+	for _, key := range keys {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// SetFieldValue - Set value of object by field path of keys, intermediate objects are created when missing
+// Invalid paths are rejected on config load, nothing is set for them here.
+func SetFieldValue(object map[string]interface{}, path string, value interface{}) {
+	keys, err := parseFieldKeys(path)
+	if err != nil {
+		return
+	}
+	current := object
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[key] = next
+		}
+		current = next
+	}
+	current[keys[len(keys)-1]] = value
+}
+
+func parseFieldKeys(path string) ([]string, error) {
+	if cached, ok := fieldKeysCache.Load(path); ok {
+		return cached.([]string), nil
+	}
+	segments, err := parseFieldPath(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(segments))
+	for i, segment := range segments {
+		if segment.glob != nil || len(segment.indexes) > 0 {
+			return nil, fmt.Errorf("field path %q should select a single field by keys", path)
+		}
+		keys[i] = segment.key
+	}
+	fieldKeysCache.Store(path, keys)
+	return keys, nil
+}
@@ -7,7 +7,7 @@ import (
 
 func Inspect(object interface{}) {
 	fooType := reflect.TypeOf(object)
-	fmt.Println("inspect: %s", fooType)
+	fmt.Printf("inspect: %s\n", fooType)
 	for i := 0; i < fooType.NumMethod(); i++ {
 		method := fooType.Method(i)
 		fmt.Println(method.Name)
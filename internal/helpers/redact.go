@@ -0,0 +1,168 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mohae/deepcopy"
+)
+
+// Redaction strategies
+const (
+	RedactMask      = "mask"       // "john" => "j***"
+	RedactMaskEmail = "mask_email" // "john@example.com" => "j***@example.com"
+	RedactHash      = "hash"       // salted SHA-256 hex digest, masked as a whole without salt
+	RedactTruncate  = "truncate"   // "truncate:3", "Taipei City" => "Tai"
+	RedactRemove    = "remove"     // remove the field
+)
+
+// Redact - Redact fields of object by rules of field path and strategy, e.g. {"customer.email": "mask_email"}
+// Field paths follow the syntax of IncludeFields, the original object is not modified.
+func Redact(object map[string]interface{}, rules map[string]string, salt string) map[string]interface{} {
+	if object == nil || len(rules) == 0 {
+		return object
+	}
+	clone := deepcopy.Copy(object).(map[string]interface{})
+	for path, strategy := range rules {
+		segments, err := parseFieldPath(path)
+		if err != nil {
+			// Invalid paths are rejected on config load, ignore them here
+			continue
+		}
+		redactPath(clone, segments, strategy, salt)
+	}
+	return clone
+}
+
+// VerifyRedactStrategy - Check if redaction strategy is supported
+func VerifyRedactStrategy(strategy string) error {
+	name, arg := parseRedactStrategy(strategy)
+	switch name {
+	case RedactMask, RedactMaskEmail, RedactHash, RedactRemove:
+		return nil
+	case RedactTruncate:
+		if n, err := strconv.Atoi(arg); err != nil || n < 0 {
+			return fmt.Errorf("invalid truncate length %q", arg)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown redact strategy %q", strategy)
+	}
+}
+
+func redactPath(object interface{}, segments []fieldSegment, strategy string, salt string) {
+	switch o := object.(type) {
+	case []interface{}:
+		for _, element := range o {
+			redactPath(element, segments, strategy, salt)
+		}
+	case []map[string]interface{}:
+		for _, element := range o {
+			redactPath(element, segments, strategy, salt)
+		}
+	case map[string]interface{}:
+		for key, value := range o {
+			if !segments[0].matchKey(key) {
+				continue
+			}
+			result, keep := redactIndexes(value, segments[0], segments[0].indexes, segments[1:], strategy, salt)
+			if keep {
+				o[key] = result
+			} else {
+				delete(o, key)
+			}
+		}
+	}
+}
+
+// redactIndexes - Redact elements of array selected by indexes, returns false if the value should be removed
+func redactIndexes(value interface{}, segment fieldSegment, indexes []int, rest []fieldSegment, strategy string, salt string) (interface{}, bool) {
+	if len(indexes) == 0 {
+		if len(rest) > 0 {
+			redactPath(value, rest, strategy, salt)
+			return value, true
+		}
+		if name, _ := parseRedactStrategy(strategy); name == RedactRemove {
+			return nil, false
+		}
+		return redactValue(value, strategy, salt), true
+	}
+
+	elements, ok := value.([]interface{})
+	if !ok {
+		return value, true
+	}
+	remaining := []interface{}{}
+	for i, element := range elements {
+		if !segment.matchIndex(indexes[0], i) {
+			remaining = append(remaining, element)
+			continue
+		}
+		if result, keep := redactIndexes(element, segment, indexes[1:], rest, strategy, salt); keep {
+			remaining = append(remaining, result)
+		}
+	}
+	return remaining, true
+}
+
+func redactValue(value interface{}, strategy string, salt string) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, element := range v {
+			result[i] = redactValue(element, strategy, salt)
+		}
+		return result
+	}
+
+	str := fmt.Sprint(value)
+	name, arg := parseRedactStrategy(strategy)
+	switch name {
+	case RedactMask:
+		return maskString(str)
+	case RedactMaskEmail:
+		at := strings.LastIndex(str, "@")
+		if at < 0 {
+			return maskString(str)
+		}
+		return maskString(str[:at]) + str[at:]
+	case RedactHash:
+		// Unsalted digest of short values like phone numbers could be reversed by brute force
+		if salt == "" {
+			return "***"
+		}
+		sum := sha256.Sum256([]byte(salt + str))
+		return hex.EncodeToString(sum[:])
+	case RedactTruncate:
+		n, _ := strconv.Atoi(arg)
+		runes := []rune(str)
+		if len(runes) > n {
+			return string(runes[:n])
+		}
+		return str
+	default:
+		// Unknown strategy masks the whole value to be safe
+		return "***"
+	}
+}
+
+func maskString(str string) string {
+	runes := []rune(str)
+	if len(runes) <= 1 {
+		return "***"
+	}
+	return string(runes[:1]) + "***"
+}
+
+func parseRedactStrategy(strategy string) (string, string) {
+	parts := strings.SplitN(strategy, ":", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
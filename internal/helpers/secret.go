@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Secret references keep credentials out of hook configurations, e.g.
+//   - "env:PARTNER_SECRET": value of environment variable
+//   - "file:/run/secrets/partner": content of file, trailing newline trimmed
+const (
+	secretRefEnv  = "env:"
+	secretRefFile = "file:"
+)
+
+// ResolveSecret - Resolve value of secret reference
+func ResolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretRefEnv):
+		name := strings.TrimPrefix(ref, secretRefEnv)
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return "", fmt.Errorf("secret env %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, secretRefFile):
+		path := strings.TrimPrefix(ref, secretRefFile)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret file %s: %s", path, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		// Never include the reference itself, it may be a secret configured by mistake
+		return "", fmt.Errorf("unsupported secret reference, expected env:NAME or file:PATH")
+	}
+}
+
+// VerifySecretRef - Check if secret reference is well-formed, the secret itself is resolved on use
+func VerifySecretRef(ref string) error {
+	for _, prefix := range []string{secretRefEnv, secretRefFile} {
+		if strings.HasPrefix(ref, prefix) && len(ref) > len(prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported secret reference, expected env:NAME or file:PATH")
+}
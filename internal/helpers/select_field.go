@@ -1,102 +1,176 @@
 package helpers
 
 import (
-  "fmt"
-  "sort"
-  "strings"
-  "reflect"
-  "github.com/thoas/go-funk"
-  "github.com/mohae/deepcopy"
+	"github.com/mohae/deepcopy"
 )
 
+// IncludeFields - Keep only fields of object selected by field paths, see field_path.go for path syntax
 func IncludeFields(object map[string]interface{}, fields []string) interface{} {
-  fieldTree := buildFieldTree(fields)
-  clone := deepcopy.Copy(object)
-  return filter(clone, fieldTree, "include")
+	fieldTree := compileFieldTree(fields)
+	clone := deepcopy.Copy(object)
+	return includeValue(clone, fieldTree)
 }
 
+// ExcludeFields - Remove fields of object selected by field paths, see field_path.go for path syntax
 func ExcludeFields(object map[string]interface{}, fields []string) interface{} {
-  fieldTree := buildFieldTree(fields)
-  clone := deepcopy.Copy(object)
-  return filter(clone, fieldTree, "exclude")
+	fieldTree := compileFieldTree(fields)
+	clone := deepcopy.Copy(object)
+	return excludeValue(clone, fieldTree)
 }
 
-func buildNode(ancestor map[string]interface{}, nodes []string, i int) {
-  if(i >= len(nodes)) { return }
-
-  node := nodes[i]
-
-  if(ancestor[node] == nil) { ancestor[node] = map[string]interface{}{} }
-
-  buildNode(ancestor[node].(map[string]interface{}), nodes, i + 1)
+func includeValue(value interface{}, tree *fieldTree) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, child := range v {
+			for _, branch := range tree.branches {
+				if !branch.segment.matchKey(key) {
+					continue
+				}
+				selected, ok := includeIndexes(child, branch.segment.indexes, branch)
+				if !ok {
+					continue
+				}
+				if existing, exists := result[key]; exists {
+					selected = mergeIncluded(existing, selected)
+				}
+				result[key] = selected
+			}
+		}
+		return result
+	case []interface{}:
+		// apply filter on each element of array
+		result := make([]interface{}, len(v))
+		for i, element := range v {
+			result[i] = includeValue(element, tree)
+		}
+		return result
+	case []map[string]interface{}:
+		result := make([]map[string]interface{}, len(v))
+		for i, element := range v {
+			result[i] = includeValue(element, tree).(map[string]interface{})
+		}
+		return result
+	default:
+		// do not filter primitive types (e.g, object contains array of strings)
+		return value
+	}
 }
 
-// adapted from open-api-node FieldFilter
-func buildFieldTree(fields []string) map[string]interface{} {
-  sort.Strings(fields)
-
-  tree := map[string]interface{}{}
-
-  for _, field := range fields {
-    nodes := strings.Split(field, ".")
-    buildNode(tree, nodes, 0)
-  }
-
-  return tree
+// includeIndexes - Select elements of array by indexes, then fields of the selected elements
+func includeIndexes(value interface{}, indexes []int, branch *fieldBranch) (interface{}, bool) {
+	if len(indexes) == 0 {
+		if branch.whole {
+			return value, true
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}, []map[string]interface{}:
+			return includeValue(value, branch.subtree), true
+		}
+		// Primitive has no nested field selected by path, so it is not included
+		return nil, false
+	}
+
+	elements, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	selected := []interface{}{}
+	for i, element := range elements {
+		if !branch.segment.matchIndex(indexes[0], i) {
+			continue
+		}
+		if result, ok := includeIndexes(element, indexes[1:], branch); ok {
+			selected = append(selected, result)
+		}
+	}
+	return selected, true
 }
 
-func applyFilter(result map[string]interface{}, key string, value interface{}, mode string) {
-  switch (mode) {
-    case "include":
-      result[key] = value
-    case "exclude":
-      delete(result, key)
-    default:
-      panic(fmt.Sprintf("unknown filter mode %s", "mode"))
-  }
+// mergeIncluded - Merge values of the same key selected by different paths, e.g. "metadata.*_id" and "metadata.order_id.number"
+func mergeIncluded(a interface{}, b interface{}) interface{} {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			return a
+		}
+		for key, value := range bv {
+			if existing, exists := av[key]; exists {
+				value = mergeIncluded(existing, value)
+			}
+			av[key] = value
+		}
+		return av
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return a
+		}
+		for i := range av {
+			av[i] = mergeIncluded(av[i], bv[i])
+		}
+		return av
+	default:
+		return a
+	}
 }
 
-func filter(object interface{}, fieldTree map[string]interface{}, mode string) interface{} {
-  var result interface{}
-
-  switch mode {
-    case "include":
-      result = map[string]interface{}{}
-    case "exclude":
-      result = object
-  }
-
-  // do not filter primitive types (e.g, object contains array of strings)
-  if(reflect.TypeOf(object).Kind() != reflect.Map) {
-    return object
-  }
-
-  // eslint-disable-next-line no-restricted-syntax
-  for key, value := range object.(map[string]interface{}) {
-    // not in include/exclude tree, keep it as is
-    if (!funk.Contains(funk.Keys(fieldTree), key)) {
-      continue
-    }
-
-    // {} is wildcard, keep/discard everything
-    if (reflect.TypeOf(fieldTree[key]).Kind() == reflect.Map && len(fieldTree[key].(map[string]interface{})) == 0){
-      applyFilter(result.(map[string]interface{}), key, value, mode)
-      continue
-    }
-
-    // nested object
-    if (reflect.TypeOf(value).Kind() == reflect.Map) {
-      result.(map[string]interface{})[key] = filter(value, fieldTree[key].(map[string]interface{}), mode)
-      continue
-    }
-
-    // apply filter on each element of array
-    if (reflect.TypeOf(value).Kind() == reflect.Slice || reflect.TypeOf(value).Kind() == reflect.Array) {
-      result.(map[string]interface{})[key] = funk.Map(value, func(element interface{}) interface{} {
-        return filter(element, fieldTree[key].(map[string]interface{}), mode)
-      })
-    }
-  }
+func excludeValue(value interface{}, tree *fieldTree) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key := range v {
+			for _, branch := range tree.branches {
+				if !branch.segment.matchKey(key) {
+					continue
+				}
+				if len(branch.segment.indexes) == 0 && branch.whole {
+					delete(v, key)
+					break
+				}
+				v[key] = excludeIndexes(v[key], branch.segment.indexes, branch)
+			}
+		}
+		return v
+	case []interface{}:
+		// apply filter on each element of array
+		for i, element := range v {
+			v[i] = excludeValue(element, tree)
+		}
+		return v
+	case []map[string]interface{}:
+		for i, element := range v {
+			v[i] = excludeValue(element, tree).(map[string]interface{})
+		}
+		return v
+	default:
+		return value
+	}
+}
 
-  return result
+// excludeIndexes - Remove elements of array selected by indexes, or fields of the selected elements
+func excludeIndexes(value interface{}, indexes []int, branch *fieldBranch) interface{} {
+	if len(indexes) == 0 {
+		return excludeValue(value, branch.subtree)
+	}
+
+	elements, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+	if len(indexes) == 1 && branch.whole {
+		remaining := []interface{}{}
+		for i, element := range elements {
+			if !branch.segment.matchIndex(indexes[0], i) {
+				remaining = append(remaining, element)
+			}
+		}
+		return remaining
+	}
+	for i, element := range elements {
+		if branch.segment.matchIndex(indexes[0], i) {
+			elements[i] = excludeIndexes(element, indexes[1:], branch)
+		}
+	}
+	return elements
 }
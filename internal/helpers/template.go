@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	html_template "html/template"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Compiled templates, keyed by template source
+var templateCache sync.Map
+var htmlTemplateCache sync.Map
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"default": func(d interface{}, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return d
+		}
+		return v
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join": func(sep string, v []interface{}) string {
+		s := make([]string, len(v))
+		for i, e := range v {
+			s[i], _ = e.(string)
+		}
+		return strings.Join(s, sep)
+	},
+	"now": func() string {
+		return time.Now().UTC().Format(time.RFC3339)
+	},
+}
+
+// CompileTemplate - Compile Go text/template with captin template functions, compiled templates are reused
+func CompileTemplate(src string) (*template.Template, error) {
+	if cached, ok := templateCache.Load(src); ok {
+		return cached.(*template.Template), nil
+	}
+	t, err := template.New("captin").Funcs(templateFuncs).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	templateCache.Store(src, t)
+	return t, nil
+}
+
+// RenderTemplate - Render template source with data
+func RenderTemplate(src string, data interface{}) (string, error) {
+	t, err := CompileTemplate(src)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// CompileHTMLTemplate - Compile Go html/template with captin template functions, values are escaped for HTML context
+func CompileHTMLTemplate(src string) (*html_template.Template, error) {
+	if cached, ok := htmlTemplateCache.Load(src); ok {
+		return cached.(*html_template.Template), nil
+	}
+	t, err := html_template.New("captin").Funcs(html_template.FuncMap(templateFuncs)).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	htmlTemplateCache.Store(src, t)
+	return t, nil
+}
+
+// RenderHTMLTemplate - Render HTML template source with data
+func RenderHTMLTemplate(src string, data interface{}) (string, error) {
+	t, err := CompileHTMLTemplate(src)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -28,14 +28,14 @@ var nullDocumentStore = documentStores.NewNullDocumentStore()
 
 // Dispatcher - Event Dispatcher
 type Dispatcher struct {
-	destinations   []models.Destination
-	senderMapping  map[string]interfaces.EventSenderInterface
-	Errors         []interfaces.ErrorInterface
-	targetDocument map[string]interface{}
-	filters        []destination_filters.DestinationFilterInterface
-	middlewares    []destination_filters.DestinationMiddlewareInterface
-	errorHandler   interfaces.ErrorHandlerInterface
-	delayer        interfaces.DispatchDelayerInterface
+	destinations    []models.Destination
+	senderMapping   map[string]interfaces.EventSenderInterface
+	Errors          []interfaces.ErrorInterface
+	targetDocuments map[string]map[string]interface{}
+	filters         []destination_filters.DestinationFilterInterface
+	middlewares     []destination_filters.DestinationMiddlewareInterface
+	errorHandler    interfaces.ErrorHandlerInterface
+	delayer         interfaces.DispatchDelayerInterface
 
 	muTargetDocument sync.Mutex
 	muErrors         sync.Mutex
@@ -89,11 +89,13 @@ func (d *Dispatcher) OnError(ctx context.Context, evt interfaces.IncomingEventIn
 	switch dispatcherErr := err.(type) {
 	case *captin_errors.DispatcherError:
 		dLogger.WithFields(log.Fields{
-			"event":       dispatcherErr.Event,
+			"event":       dispatcherErr.Destination.RedactEventForLog(dispatcherErr.Event),
 			"destination": dispatcherErr.Destination,
 			"reason":      dispatcherErr.Error(),
 		}).Error("Failed to dispatch event")
 		d.TriggerErrorHandler(ctx, dispatcherErr)
+	case *captin_errors.UnretryableError:
+		dLogger.WithFields(log.Fields{"event": dispatcherErr.Destination.RedactEventForLog(dispatcherErr.Event), "error": err}).Error("Unhandled error on dispatcher")
 	default:
 		dLogger.WithFields(log.Fields{"event": evt, "error": err}).Error("Unhandled error on dispatcher")
 	}
@@ -118,7 +120,6 @@ func (d *Dispatcher) Dispatch(
 		dLogger.WithField("event", e).Warn("Triggering dispatch more than once")
 	}
 	d.dispatchCalled.Store(true)
-	responses := make(chan int, len(d.destinations))
 	ctx = e.DistributedTracingInfo.PropagateIntoContext(ctx)
 	ctx, span := helpers.Tracer().Start(ctx, "captin.Dispatch", trace.WithAttributes(
 		attribute.String("event_key", e.Key),
@@ -128,44 +129,65 @@ func (d *Dispatcher) Dispatch(
 	defer span.End()
 	// propagate the trace context into the event, for both downstream receivers, and also later workers if the event is delayed or throttled
 	e.DistributedTracingInfo.InjectContext(ctx)
-	for _, destination := range d.destinations {
-		config := destination.Config
-		canTrigger, timeRemain, err := throttler.CanTrigger(ctx, getEventKey(ctx, store, e, destination), config.GetThrottleValue())
-		documentStore := d.getDocumentStore(destination, documentStoreMappings)
-
-		if err != nil {
-			dLogger.WithFields(log.Fields{"event": e, "destination": destination, "error": err}).Error("Error on getting throttle key")
-
-			// Send without throttling
-			go func(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreInterface) {
-				d.sendEvent(ctx, e, destination, store, documentStore)
-				responses <- 1
-			}(e, destination, documentStore)
-			continue
-		}
 
-		if canTrigger {
-			go func(e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreInterface) {
-				d.sendEvent(ctx, e, destination, store, documentStore)
-				responses <- 1
-			}(e, destination, documentStore)
-		} else if !config.GetThrottleTrailingDisabled() {
-			go func(ctx context.Context, e models.IncomingEvent, destination models.Destination, documentStore interfaces.DocumentStoreInterface) {
-				d.processDelayedEvent(ctx, e, timeRemain, destination, store, documentStore)
-				responses <- 1
-			}(ctx, e, destination, documentStore)
-		} else {
-			dLogger.WithFields(log.Fields{"event": e, "destination": destination}).Info("Cannot trigger send event")
-			responses <- 0
+	deliveries := []delivery{}
+	for _, destination := range d.destinations {
+		for _, splitted := range splitEvent(e, destination) {
+			deliveries = append(deliveries, delivery{event: splitted, destination: destination})
 		}
 	}
+	span.SetAttributes(attribute.Int("delivery_count", len(deliveries)))
+	responses := make(chan int, len(deliveries))
+
+	for _, delivery := range deliveries {
+		go func(e models.IncomingEvent, destination models.Destination) {
+			d.dispatchDelivery(ctx, e, destination, store, throttler, d.getDocumentStore(destination, documentStoreMappings))
+			responses <- 1
+		}(delivery.event, delivery.destination)
+	}
 	// Wait for destination completion
-	for range d.destinations {
+	for range deliveries {
 		<-responses
 	}
 	return nil
 }
 
+// dispatchDelivery - Hold event outside delivery window, otherwise send it now or later by throttle of destination
+func (d *Dispatcher) dispatchDelivery(ctx context.Context, e models.IncomingEvent, destination models.Destination, store interfaces.StoreInterface, throttler interfaces.ThrottleInterface, documentStore interfaces.DocumentStoreInterface) {
+	config := destination.Config
+
+	wait, windowErr := destination.GetDeliveryWait(time.Now())
+	if windowErr != nil {
+		// Window is not known to be open, e.g. blackout longer than look ahead, hold event and check again later
+		dLogger.WithFields(log.Fields{"event": destination.RedactEventForLog(e), "destination": destination, "error": windowErr}).Warn("Error on checking delivery window, event is held")
+		d.processHeldEvent(ctx, e, heldEventRecheckInterval, destination, store, throttler, documentStore)
+		return
+	}
+	if wait > 0 {
+		// Hold event until delivery window opens
+		d.processHeldEvent(ctx, e, wait, destination, store, throttler, documentStore)
+		return
+	}
+
+	canTrigger, timeRemain, err := throttler.CanTrigger(ctx, getEventKey(ctx, store, e, destination), config.GetThrottleValue())
+
+	if err != nil {
+		dLogger.WithFields(log.Fields{"event": destination.RedactEventForLog(e), "destination": destination, "error": err}).Error("Error on getting throttle key")
+
+		// Send without throttling
+		d.sendEvent(ctx, e, destination, store, documentStore)
+		return
+	}
+
+	if canTrigger {
+		d.sendEvent(ctx, e, destination, store, documentStore)
+	} else if !config.GetThrottleTrailingDisabled() {
+		d.processDelayedEvent(ctx, e, timeRemain, destination, store, documentStore)
+	} else {
+		dLogger.WithFields(log.Fields{"event": destination.RedactEventForLog(e), "destination": destination}).Info("Cannot trigger send event")
+	}
+}
+
 func (d *Dispatcher) TriggerErrorHandler(ctx context.Context, err *captin_errors.DispatcherError) {
 	if d.errorHandler != nil {
 		dispatcher.TrackGoRoutine(func() {
@@ -176,6 +198,24 @@ func (d *Dispatcher) TriggerErrorHandler(ctx context.Context, err *captin_errors
 
 // Private Functions
 
+// notify filters which keep state of delivered events
+func (d *Dispatcher) notifyDelivered(ctx context.Context, e models.IncomingEvent, destination models.Destination) {
+	for _, filter := range d.filters {
+		if callback, ok := filter.(destination_filters.DestinationDeliveredCallbackInterface); ok {
+			callback.OnDelivered(ctx, e, destination)
+		}
+	}
+}
+
+// notify filters which keep state of events passing them that delivery failed
+func (d *Dispatcher) notifyFailed(ctx context.Context, e models.IncomingEvent, destination models.Destination) {
+	for _, filter := range d.filters {
+		if callback, ok := filter.(destination_filters.DestinationFailedCallbackInterface); ok {
+			callback.OnFailed(ctx, e, destination)
+		}
+	}
+}
+
 func (d *Dispatcher) getDocumentStore(dest models.Destination, documentStoreMappings map[string]interfaces.DocumentStoreInterface) interfaces.DocumentStoreInterface {
 	if documentStoreMappings[dest.GetDocumentStore()] != nil {
 		return documentStoreMappings[dest.GetDocumentStore()]
@@ -198,19 +238,19 @@ func (d *Dispatcher) injectThrottledPayloads(ctx context.Context, e models.Incom
 		queueKey := getEventThrottledPayloadsKey(ctx, store, e, destination)
 		payloadStrings, _, _, err := store.GetQueue(ctx, queueKey)
 		if err != nil {
-			dLogger.WithFields(log.Fields{"event": e, "error": err}).Error("Error occurred when getting queue for throttled payloads")
+			dLogger.WithFields(log.Fields{"event": destination.RedactEventForLog(e), "error": err}).Error("Error occurred when getting queue for throttled payloads")
 			// TODO: handle the error properly
 		}
 		_, err = store.Remove(ctx, queueKey)
 		if err != nil {
-			dLogger.WithFields(log.Fields{"event": e, "error": err}).Error("Error occurred when removing queue key for throttled payloads")
+			dLogger.WithFields(log.Fields{"event": destination.RedactEventForLog(e), "error": err}).Error("Error occurred when removing queue key for throttled payloads")
 			// TODO: handle the error properly
 		}
 		for _, payloadStr := range payloadStrings {
 			payload := map[string]interface{}{}
 			err := json.Unmarshal([]byte(payloadStr), &payload)
 			if err != nil {
-				dLogger.WithFields(log.Fields{"event": e, "error": err}).Error("Error occurred when unmarshalling throttled payload")
+				dLogger.WithFields(log.Fields{"event": destination.RedactEventForLog(e), "error": err}).Error("Error occurred when unmarshalling throttled payload")
 				// TODO: handle the error properly
 			}
 			e.ThrottledPayloads = append(e.ThrottledPayloads, payload)
@@ -225,19 +265,19 @@ func (d *Dispatcher) injectThrottledDocuments(ctx context.Context, e models.Inco
 		queueKey := getEventThrottledDocumentsKey(ctx, store, e, destination)
 		documentStrings, _, _, err := store.GetQueue(ctx, queueKey)
 		if err != nil {
-			dLogger.WithFields(log.Fields{"event": e, "error": err}).Warn("Error occurred when getting queue for throttled documents")
+			dLogger.WithFields(log.Fields{"event": destination.RedactEventForLog(e), "error": err}).Warn("Error occurred when getting queue for throttled documents")
 			// TODO: handle the error properly
 		}
 		_, err = store.Remove(ctx, queueKey)
 		if err != nil {
-			dLogger.WithFields(log.Fields{"event": e, "error": err}).Warn("Error occurred when removing queue key for throttled documents")
+			dLogger.WithFields(log.Fields{"event": destination.RedactEventForLog(e), "error": err}).Warn("Error occurred when removing queue key for throttled documents")
 			// TODO: handle the error properly
 		}
 		for _, documentStr := range documentStrings {
 			document := map[string]interface{}{}
 			err := json.Unmarshal([]byte(documentStr), &document)
 			if err != nil {
-				dLogger.WithFields(log.Fields{"event": e, "error": err}).Warn("Error occurred when unmarshalling throttled document")
+				dLogger.WithFields(log.Fields{"event": destination.RedactEventForLog(e), "error": err}).Warn("Error occurred when unmarshalling throttled document")
 				// TODO: handle the error properly
 			}
 			e.ThrottledDocuments = append(e.ThrottledDocuments, document)
@@ -256,16 +296,23 @@ func (d *Dispatcher) customizeDocument(ctx context.Context, e *models.IncomingEv
 	defer d.muTargetDocument.Unlock()
 
 	// memoize document to be used across events for diff. destinations
-	if d.targetDocument == nil {
-		d.targetDocument = documentStore.GetDocument(ctx, *e)
+	// split events load the document of the parent target, as their target type is kept from the parent
+	target := documentEvent(*e)
+	if d.targetDocuments == nil {
+		d.targetDocuments = map[string]map[string]interface{}{}
+	}
+	targetDocument, memoized := d.targetDocuments[target.TargetId]
+	if !memoized {
+		targetDocument = documentStore.GetDocument(ctx, target)
+		d.targetDocuments[target.TargetId] = targetDocument
 	}
 
 	if len(config.GetIncludeDocumentAttrs()) >= 1 {
-		return helpers.IncludeFields(d.targetDocument, config.GetIncludeDocumentAttrs()).(map[string]interface{})
+		return helpers.IncludeFields(targetDocument, config.GetIncludeDocumentAttrs()).(map[string]interface{})
 	} else if len(config.GetExcludeDocumentAttrs()) >= 1 {
-		return helpers.ExcludeFields(d.targetDocument, config.GetExcludeDocumentAttrs()).(map[string]interface{})
+		return helpers.ExcludeFields(targetDocument, config.GetExcludeDocumentAttrs()).(map[string]interface{})
 	} else {
-		return d.targetDocument
+		return targetDocument
 	}
 }
 
@@ -308,7 +355,7 @@ func (d *Dispatcher) processDelayedEvent(ctx context.Context, e models.IncomingE
 		storedEvent = models.IncomingEvent{}
 		err := json.Unmarshal([]byte(storedData), &storedEvent)
 		if err != nil {
-			dLogger.WithFields(log.Fields{"event": e, "error": err}).Warn("Error occurred when unmarshalling stored event when processing delayed event")
+			dLogger.WithFields(log.Fields{"event": dest.RedactEventForLog(e), "error": err}).Warn("Error occurred when unmarshalling stored event when processing delayed event")
 			span.RecordError(err)
 			// TODO: handle the error properly
 		}
@@ -317,8 +364,8 @@ func (d *Dispatcher) processDelayedEvent(ctx context.Context, e models.IncomingE
 	if dataExists && getControlTimestamp(storedEvent, 0) > getControlTimestamp(e, uint64(time.Now().UnixNano())) {
 		// Skip updating event data as stored data has newer timestamp
 		dLogger.WithFields(log.Fields{
-			"storedEvent":  storedEvent,
-			"event":        e,
+			"storedEvent":  dest.RedactEventForLog(storedEvent),
+			"event":        dest.RedactEventForLog(e),
 			"eventDataKey": "dataKey",
 		}).Debug("Skipping update on event data")
 		span.AddEvent("Skipping update on event data")
@@ -335,14 +382,14 @@ func (d *Dispatcher) processDelayedEvent(ctx context.Context, e models.IncomingE
 		ttl := dest.Config.GetThrottleValue() * 2
 		dLogger.WithFields(log.Fields{
 			"queueKey":       queueKey,
-			"event":          e,
-			"enqueuePayload": jsonString,
+			"event":          dest.RedactEventForLog(e),
+			"enqueuePayload": dest.RedactForLog(customizedPayload),
 			"ttl":            ttl,
 		}).Debug("Storing throttled payload")
 		span.AddEvent("Storing throttled payload", trace.WithAttributes(attribute.String("queueKey", queueKey), attribute.String("throttleValue", ttl.String())))
 		_, err := store.Enqueue(ctx, queueKey, string(jsonString), ttl)
 		if err != nil {
-			dLogger.WithFields(log.Fields{"event": e, "error": err, "queueKey": queueKey}).Warn("Error occurred when enqueuing customized payload when processing delayed event")
+			dLogger.WithFields(log.Fields{"event": dest.RedactEventForLog(e), "error": err, "queueKey": queueKey}).Warn("Error occurred when enqueuing customized payload when processing delayed event")
 			span.RecordError(err)
 			// TODO: handle the error properly
 		}
@@ -358,14 +405,14 @@ func (d *Dispatcher) processDelayedEvent(ctx context.Context, e models.IncomingE
 		ttl := dest.Config.GetThrottleValue() * 2
 		dLogger.WithFields(log.Fields{
 			"queueKey":        queueKey,
-			"event":           e,
-			"enqueueDocument": jsonString,
+			"event":           dest.RedactEventForLog(e),
+			"enqueueDocument": dest.RedactForLog(customizedDocument),
 			"ttl":             ttl,
 		}).Debug("Storing throttled document")
 		span.AddEvent("Storing throttled document", trace.WithAttributes(attribute.String("queueKey", queueKey), attribute.String("throttleValue", ttl.String())))
 		_, err := store.Enqueue(ctx, queueKey, string(jsonString), ttl)
 		if err != nil {
-			dLogger.WithFields(log.Fields{"event": e, "error": err, "queueKey": queueKey}).Warn("Error occurred when enqueuing customized document when processing delayed event")
+			dLogger.WithFields(log.Fields{"event": dest.RedactEventForLog(e), "error": err, "queueKey": queueKey}).Warn("Error occurred when enqueuing customized document when processing delayed event")
 			span.RecordError(err)
 			// TODO: handle the error properly
 		}
@@ -399,7 +446,7 @@ func (d *Dispatcher) processDelayedEvent(ctx context.Context, e models.IncomingE
 			dLogger.WithFields(log.Fields{"key": dataKey}).Debug("After event callback")
 			payload, exists, _, err := store.Get(ctx, dataKey)
 			if err != nil {
-				dLogger.WithFields(log.Fields{"event": e, "error": err, "dataKey": dataKey}).Warn("Error occurred when getting payload for delayed event")
+				dLogger.WithFields(log.Fields{"event": dest.RedactEventForLog(e), "error": err, "dataKey": dataKey}).Warn("Error occurred when getting payload for delayed event")
 				span.RecordError(err)
 				// TODO: handle the error properly
 			}
@@ -455,20 +502,24 @@ func getControlTimestamp(e models.IncomingEvent, defaultValue uint64) uint64 {
 	return value.(uint64)
 }
 
-func getEventKey(ctx context.Context, s interfaces.StoreInterface, e interfaces.IncomingEventInterface, d interfaces.DestinationInterface) string {
-	return s.DataKey(ctx, e, d, "", "")
+func getEventKey(ctx context.Context, s interfaces.StoreInterface, e models.IncomingEvent, d interfaces.DestinationInterface) string {
+	return s.DataKey(ctx, throttleEvent(e), d, "", "")
 }
 
-func getEventDataKey(ctx context.Context, s interfaces.StoreInterface, e interfaces.IncomingEventInterface, d interfaces.DestinationInterface) string {
-	return s.DataKey(ctx, e, d, "", "-data")
+func getEventDataKey(ctx context.Context, s interfaces.StoreInterface, e models.IncomingEvent, d interfaces.DestinationInterface) string {
+	return s.DataKey(ctx, throttleEvent(e), d, "", "-data")
 }
 
 func getEventThrottledPayloadsKey(ctx context.Context, s interfaces.StoreInterface, e models.IncomingEvent, d models.Destination) string {
-	return s.DataKey(ctx, e, d, "", "-throttled_payloads")
+	return s.DataKey(ctx, throttleEvent(e), d, "", "-throttled_payloads")
 }
 
 func getEventThrottledDocumentsKey(ctx context.Context, s interfaces.StoreInterface, e models.IncomingEvent, d models.Destination) string {
-	return s.DataKey(ctx, e, d, "", "-throttled_documents")
+	return s.DataKey(ctx, throttleEvent(e), d, "", "-throttled_documents")
+}
+
+func getEventHeldEventsKey(ctx context.Context, s interfaces.StoreInterface, e models.IncomingEvent, d models.Destination) string {
+	return s.DataKey(ctx, e, d, "", heldEventsSuffix)
 }
 
 // TODO:
@@ -480,7 +531,7 @@ func (d *Dispatcher) sendEvent(ctx context.Context, evt models.IncomingEvent, de
 	config := destination.Config
 	callbackLogger := dLogger.WithFields(log.Fields{
 		"action":         evt.Key,
-		"event":          evt,
+		"event":          destination.RedactEventForLog(evt),
 		"hook_name":      config.GetName(),
 		"callback_url":   destination.GetCallbackURL(),
 		"document_store": destination.GetDocumentStore(),
@@ -493,15 +544,30 @@ func (d *Dispatcher) sendEvent(ctx context.Context, evt models.IncomingEvent, de
 		attribute.String("document_store", destination.GetDocumentStore()),
 	))
 
+	// Event passed filters, which are notified with it on delivery or failure
+	var delivered models.IncomingEvent
+	passedFilters := false
+
 	defer func() {
 		if err := recover(); err != nil {
 			errMsg := fmt.Sprintf("Event failed sending to %s [%s]", config.GetName(), destination.GetCallbackURL())
 			callbackLogger.Info(errMsg)
-			d.OnError(ctx, evt, &captin_errors.DispatcherError{
-				Msg:         err.(error).Error(),
-				Destination: destination,
-				Event:       evt,
-			})
+			if passedFilters {
+				d.notifyFailed(ctx, delivered, destination)
+			}
+			var newErr interfaces.ErrorInterface
+			switch err := err.(type) {
+			// Event could not be prepared for destination, retrying will not help
+			case *captin_errors.UnretryableError:
+				newErr = err
+			default:
+				newErr = &captin_errors.DispatcherError{
+					Msg:         err.(error).Error(),
+					Destination: destination,
+					Event:       evt,
+				}
+			}
+			d.OnError(ctx, evt, newErr)
 			span.RecordError(err.(error))
 			span.SetStatus(codes.Error, errMsg)
 			span.End()
@@ -524,6 +590,27 @@ func (d *Dispatcher) sendEvent(ctx context.Context, evt models.IncomingEvent, de
 		return
 	}
 
+	// Filters keeping state of delivered events are notified with event before redaction and transform,
+	// so that they see the same event as on filtering
+	delivered = evt
+	passedFilters = true
+
+	evt = destination.RedactEvent(evt)
+
+	if config.GetTransform() != "" {
+		callbackLogger.Debug("Transform payload")
+		payload, err := transformPayload(evt, destination)
+		if err != nil {
+			panic(&captin_errors.UnretryableError{
+				Msg:         err.Error(),
+				Destination: destination,
+				Event:       evt,
+			})
+		}
+		span.AddEvent("payload transformed")
+		evt.Payload = payload
+	}
+
 	callbackLogger.Debug("Ready to send event")
 
 	senderKey := config.GetSender()
@@ -550,6 +637,9 @@ func (d *Dispatcher) sendEvent(ctx context.Context, evt models.IncomingEvent, de
 				// As the event is invalid, this error is raised so that the event is not retried
 				case *captin_errors.UnretryableError:
 					newErr = err
+				// Sender has classified the failure as retryable, e.g. HTTP 5xx response
+				case *captin_errors.DispatcherError:
+					newErr = err
 				default:
 					newErr = &captin_errors.DispatcherError{
 						Msg:         err.(error).Error(),
@@ -557,6 +647,7 @@ func (d *Dispatcher) sendEvent(ctx context.Context, evt models.IncomingEvent, de
 						Event:       evt,
 					}
 				}
+				d.notifyFailed(ctx, delivered, destination)
 				d.OnError(ctx, evt, newErr)
 				span.RecordError(newErr)
 				span.SetStatus(codes.Error, newErr.Error())
@@ -572,6 +663,7 @@ func (d *Dispatcher) sendEvent(ctx context.Context, evt models.IncomingEvent, de
 			panic(err)
 		}
 		callbackLogger.Info(fmt.Sprintf("Event successfully sent to %s [%s]", config.GetName(), destination.GetCallbackURL()))
+		d.notifyDelivered(ctx, delivered, destination)
 	}
 
 	if destination.RequireDelay(evt) {
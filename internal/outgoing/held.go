@@ -0,0 +1,253 @@
+package outgoing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shoplineapp/captin/v2/dispatcher"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Suffix of store keys of events held outside delivery windows
+const heldEventsSuffix = "-held_events"
+
+// Held events are kept beyond their release time, so that they could be restored after downtime
+const heldEventRetention = 7 * 24 * time.Hour
+
+// Interval of retrying release of held events when store is not available
+const heldEventRetryInterval = time.Minute
+
+// Interval of checking delivery window again when it does not open within look ahead or could not be checked
+const heldEventRecheckInterval = time.Hour
+
+// heldEvent - Entry of held events queue, hook is kept for finding the destination on restore
+// Event is kept as full JSON of ToJson, MarshalJSON of event only contains trace info.
+type heldEvent struct {
+	Hook  string          `json:"hook"`
+	Event json.RawMessage `json:"event"`
+}
+
+// heldQueues - Release state of held events queues, keyed by queue key
+// The lock only guards the map, store is accessed under lock of the queue.
+var heldQueues = struct {
+	sync.Mutex
+	queues map[string]*heldQueue
+}{queues: map[string]*heldQueue{}}
+
+// heldQueue - Timer releasing held events queue, it is dropped from heldQueues when no release is scheduled
+// Enqueue and release of a queue are serialized by its lock, so that a queue is released by a single timer and
+// no event is enqueued between reading and removing the queue.
+// Timers are not tracked as pending jobs, held events stay in store on shutdown and are restored on start.
+type heldQueue struct {
+	sync.Mutex
+	timer   *time.Timer
+	dropped bool
+}
+
+// lockHeldQueue - Get release state of queue with its lock held
+func lockHeldQueue(queueKey string) *heldQueue {
+	for {
+		heldQueues.Lock()
+		q, ok := heldQueues.queues[queueKey]
+		if !ok {
+			q = &heldQueue{}
+			heldQueues.queues[queueKey] = q
+		}
+		heldQueues.Unlock()
+
+		q.Lock()
+		if !q.dropped {
+			return q
+		}
+		// State was dropped while waiting for its lock, take the current one
+		q.Unlock()
+	}
+}
+
+// unlockHeldQueue - Unlock release state of queue, the state is dropped if no release is scheduled
+func unlockHeldQueue(queueKey string, q *heldQueue) {
+	if q.timer == nil {
+		heldQueues.Lock()
+		if heldQueues.queues[queueKey] == q {
+			delete(heldQueues.queues, queueKey)
+		}
+		heldQueues.Unlock()
+		q.dropped = true
+	}
+	q.Unlock()
+}
+
+// processHeldEvent - Keep event in store and release it when delivery window opens
+func (d *Dispatcher) processHeldEvent(ctx context.Context, e models.IncomingEvent, wait time.Duration, dest models.Destination, store interfaces.StoreInterface, throttler interfaces.ThrottleInterface, documentStore interfaces.DocumentStoreInterface) {
+	ctx, span := helpers.Tracer().Start(ctx, "captin.processHeldEvent", trace.WithAttributes(
+		attribute.String("destination", dest.Config.GetName()),
+		attribute.Int("wait_milliseconds", int(wait.Milliseconds())),
+	))
+	defer func() {
+		if err := recover(); err != nil {
+			err := &captin_errors.DispatcherError{
+				Msg:         err.(error).Error(),
+				Destination: dest,
+				Event:       e,
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Msg)
+			d.OnError(ctx, e, err)
+		}
+		span.End()
+	}()
+
+	queueKey := getEventHeldEventsKey(ctx, store, e, dest)
+	eventJSON, jsonErr := e.ToJson()
+	if jsonErr != nil {
+		panic(jsonErr)
+	}
+	jsonString, jsonErr := json.Marshal(heldEvent{Hook: dest.Config.GetName(), Event: eventJSON})
+	if jsonErr != nil {
+		panic(jsonErr)
+	}
+
+	dLogger.WithFields(log.Fields{
+		"queueKey": queueKey,
+		"event":    dest.RedactEventForLog(e),
+		"wait":     wait,
+	}).Info("Event held until delivery window opens")
+	span.AddEvent("Storing held event", trace.WithAttributes(attribute.String("queueKey", queueKey)))
+
+	q := lockHeldQueue(queueKey)
+	defer unlockHeldQueue(queueKey, q)
+	_, err := store.Enqueue(ctx, queueKey, string(jsonString), wait+heldEventRetention)
+	if err != nil {
+		panic(err)
+	}
+	dispatcher.TrackHeldEvents(dest.Config.GetName(), 1)
+
+	// Events held in the same queue are released together by the first scheduled timer
+	if q.timer == nil {
+		d.scheduleHeldRelease(q, trace.LinkFromContext(ctx), queueKey, wait, dest, store, throttler, documentStore)
+	}
+}
+
+// RestoreHeldEvents - Schedule release of events held in store, e.g. on start after restart
+// Release time is computed again from delivery window of current configuration, queues of unknown hooks are left in store.
+// Store should be persistent and implement interfaces.StoreKeysInterface, MemoryStore implements it but its events do
+// not survive restart. Returns number of restored events.
+func (d *Dispatcher) RestoreHeldEvents(ctx context.Context, configMap interfaces.ConfigMapperInterface, store interfaces.StoreInterface, throttler interfaces.ThrottleInterface, documentStoreMappings map[string]interfaces.DocumentStoreInterface) (int, error) {
+	keysStore, ok := store.(interfaces.StoreKeysInterface)
+	if !ok {
+		return 0, fmt.Errorf("store does not support listing keys of held events")
+	}
+	queueKeys, err := keysStore.Keys(ctx, heldEventsSuffix)
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	for _, queueKey := range queueKeys {
+		entries, _, _, err := store.GetQueue(ctx, queueKey)
+		if err != nil {
+			return restored, err
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		held := heldEvent{}
+		if err := json.Unmarshal([]byte(entries[0]), &held); err != nil {
+			dLogger.WithFields(log.Fields{"queueKey": queueKey, "error": err}).Warn("Error occurred when unmarshalling held event on restore")
+			continue
+		}
+		dest, found := findDestination(configMap, models.NewIncomingEvent(held.Event).Key, held.Hook)
+		if !found {
+			dLogger.WithFields(log.Fields{"queueKey": queueKey, "hook": held.Hook}).Warn("Hook of held events not found, events are left in store")
+			continue
+		}
+		wait, err := dest.GetDeliveryWait(time.Now())
+		if err != nil {
+			dLogger.WithFields(log.Fields{"queueKey": queueKey, "error": err}).Warn("Error on checking delivery window on restore, events are kept held")
+			wait = heldEventRecheckInterval
+		}
+
+		q := lockHeldQueue(queueKey)
+		// Replace timer of previous schedule, release time follows the current configuration
+		scheduled := q.timer != nil
+		if scheduled {
+			q.timer.Stop()
+		}
+		d.scheduleHeldRelease(q, trace.LinkFromContext(ctx), queueKey, wait, dest, store, throttler, d.getDocumentStore(dest, documentStoreMappings))
+		unlockHeldQueue(queueKey, q)
+
+		// Events of scheduled queue are counted already when they were held
+		if !scheduled {
+			dispatcher.TrackHeldEvents(held.Hook, int64(len(entries)))
+		}
+		restored += len(entries)
+	}
+	return restored, nil
+}
+
+// scheduleHeldRelease - Release queue after wait, lock of queue should be held by caller
+// Release runs long after the request holding events is done, so it is traced with a link to the request only.
+func (d *Dispatcher) scheduleHeldRelease(q *heldQueue, link trace.Link, queueKey string, wait time.Duration, dest models.Destination, store interfaces.StoreInterface, throttler interfaces.ThrottleInterface, documentStore interfaces.DocumentStoreInterface) {
+	q.timer = time.AfterFunc(wait, func() {
+		// Releasing is tracked as pending job, so that shutdown waits for events being sent
+		dispatcher.TrackGoRoutine(func() {
+			d.releaseHeldEvents(link, queueKey, dest, store, throttler, documentStore)
+		})
+	})
+}
+
+// releaseHeldEvents - Take held events out of store and dispatch them again, so that window and throttle are checked
+func (d *Dispatcher) releaseHeldEvents(link trace.Link, queueKey string, dest models.Destination, store interfaces.StoreInterface, throttler interfaces.ThrottleInterface, documentStore interfaces.DocumentStoreInterface) {
+	ctx, span := helpers.Tracer().Start(context.Background(), "captin.releaseHeldEvents", trace.WithLinks(link))
+	defer span.End()
+	dLogger.WithFields(log.Fields{"key": queueKey}).Debug("Delivery window opened")
+
+	q := lockHeldQueue(queueKey)
+	q.timer = nil
+	eventStrings, _, _, err := store.GetQueue(ctx, queueKey)
+	if err == nil {
+		_, err = store.Remove(ctx, queueKey)
+	}
+	if err != nil {
+		// Events are kept in store until they could be taken out, retry later
+		dLogger.WithFields(log.Fields{"error": err, "queueKey": queueKey}).Warn("Error occurred when taking held events from store, retry later")
+		span.RecordError(err)
+		d.scheduleHeldRelease(q, link, queueKey, heldEventRetryInterval, dest, store, throttler, documentStore)
+		unlockHeldQueue(queueKey, q)
+		return
+	}
+	unlockHeldQueue(queueKey, q)
+
+	dispatcher.TrackHeldEvents(dest.Config.GetName(), -int64(len(eventStrings)))
+	span.SetAttributes(attribute.Int("released_event_count", len(eventStrings)))
+
+	for _, eventStr := range eventStrings {
+		held := heldEvent{}
+		if err := json.Unmarshal([]byte(eventStr), &held); err != nil {
+			dLogger.WithFields(log.Fields{"key": queueKey, "error": err}).Warn("Error occurred when unmarshalling held event")
+			span.RecordError(err)
+			continue
+		}
+		d.dispatchDelivery(ctx, models.NewIncomingEvent(held.Event), dest, store, throttler, documentStore)
+	}
+}
+
+// findDestination - Find destination of hook configured for event key
+func findDestination(configMap interfaces.ConfigMapperInterface, eventKey string, hook string) (models.Destination, bool) {
+	for _, config := range configMap.ConfigsForKey(eventKey) {
+		if config.GetName() == hook {
+			return models.Destination{Config: config}, true
+		}
+	}
+	return models.Destination{}, false
+}
@@ -0,0 +1,78 @@
+package outgoing
+
+import (
+	"fmt"
+
+	"github.com/mohae/deepcopy"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// delivery - An event to be dispatched to a destination
+type delivery struct {
+	event       models.IncomingEvent
+	destination models.Destination
+}
+
+// splitEvent - Fan out event into one event per element of the split_on array in payload
+// Each split event has the array replaced by its element, with target ID derived from split_target_id_field
+// and trace ID derived from the parent, while the parent trace and target IDs are kept in control for
+// correlation and for loading the document of the parent target.
+func splitEvent(e models.IncomingEvent, destination models.Destination) []models.IncomingEvent {
+	config := destination.Config
+	path := config.GetSplitOn()
+	// Event split before (e.g. retried from error handler) is not split again
+	if path == "" || e.Payload == nil || e.Control["parent_trace_id"] != nil {
+		return []models.IncomingEvent{e}
+	}
+
+	value, exists := helpers.GetFieldValue(e.Payload, path)
+	elements, isArray := value.([]interface{})
+	if !exists || !isArray {
+		dLogger.WithFields(log.Fields{"event": destination.RedactEventForLog(e), "hook_name": config.GetName(), "split_on": path}).Debug("Split field is not an array, skip splitting")
+		return []models.IncomingEvent{e}
+	}
+
+	events := make([]models.IncomingEvent, 0, len(elements))
+	for i, element := range elements {
+		split := deepcopy.Copy(e).(models.IncomingEvent)
+		split.TraceId = fmt.Sprintf("%s-%d", e.TraceId, i)
+		helpers.SetFieldValue(split.Payload, path, element)
+
+		if field := config.GetSplitTargetIdField(); field != "" {
+			if m, ok := element.(map[string]interface{}); ok {
+				if targetId, ok := helpers.GetFieldValue(m, field); ok && targetId != nil {
+					split.TargetId = fmt.Sprint(targetId)
+				}
+			}
+		}
+
+		if split.Control == nil {
+			split.Control = map[string]interface{}{}
+		}
+		split.Control["parent_trace_id"] = e.TraceId
+		split.Control["parent_target_id"] = e.TargetId
+		split.Control["split_index"] = i
+		events = append(events, split)
+	}
+	return events
+}
+
+// documentEvent - Event of target document, split events share the document of the parent target
+func documentEvent(e models.IncomingEvent) models.IncomingEvent {
+	if parent, ok := e.Control["parent_target_id"]; ok && parent != nil {
+		e.TargetId = fmt.Sprint(parent)
+	}
+	return e
+}
+
+// throttleEvent - Event identifying store keys of throttle and delayed data
+// Split event without target ID of its own is keyed by split index, so that splits are not throttled as one.
+func throttleEvent(e models.IncomingEvent) models.IncomingEvent {
+	parent, ok := e.Control["parent_target_id"]
+	if ok && parent != nil && fmt.Sprint(parent) == e.TargetId {
+		e.TargetId = fmt.Sprintf("%s.%v", e.TargetId, e.Control["split_index"])
+	}
+	return e
+}
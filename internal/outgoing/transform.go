@@ -0,0 +1,25 @@
+package outgoing
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+)
+
+// transformPayload - Render payload with transform template of destination
+// Template is rendered with event fields (e.g. .payload, .target_document, .control) and should output a JSON object
+func transformPayload(e models.IncomingEvent, destination models.Destination) (map[string]interface{}, error) {
+	config := destination.Config
+	output, err := helpers.RenderTemplate(config.GetTransform(), e.TemplateData(config.GetName()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render transform of hook %s: %s", config.GetName(), err)
+	}
+
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(output), &payload); err != nil {
+		return nil, fmt.Errorf("transform of hook %s does not output a JSON object: %s", config.GetName(), err)
+	}
+	return payload, nil
+}
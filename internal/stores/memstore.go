@@ -3,6 +3,7 @@ package stores
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,6 +21,8 @@ type item struct {
 }
 
 var _ interfaces.StoreInterface = &MemoryStore{}
+var _ interfaces.StoreKeysInterface = &MemoryStore{}
+var _ interfaces.StoreCompareAndSetInterface = &MemoryStore{}
 
 // MemoryStore - In-app memory storage
 type MemoryStore struct {
@@ -97,6 +100,31 @@ func (ms *MemoryStore) Remove(_ context.Context, key string) (bool, error) {
 	return true, nil
 }
 
+// CompareAndSet - Set value with ttl only when current value equals old, empty old means key should not exist
+// and empty value removes the key
+func (ms *MemoryStore) CompareAndSet(_ context.Context, key string, old string, value string, ttl time.Duration) (bool, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	current := ""
+	if it, ok := ms.m[key]; ok {
+		current, _ = it.value.(string)
+		if current == "" {
+			// Queue or empty value is never the expected one
+			return false, nil
+		}
+	}
+	if current != old {
+		return false, nil
+	}
+	if value == "" {
+		delete(ms.m, key)
+		return true, nil
+	}
+	ms.m[key] = &item{value: value, createDate: time.Now(), ttl: ttl}
+	return true, nil
+}
+
 // Enqueue - ttl: optional params for setting the ttl of queue when first element is enqueued
 func (ms *MemoryStore) Enqueue(_ context.Context, key string, value string, ttl time.Duration) (bool, error) {
 	ms.lock.Lock()
@@ -126,6 +154,20 @@ func (ms *MemoryStore) GetQueue(_ context.Context, key string) ([]string, bool,
 	return []string{}, false, 0, nil
 }
 
+// Keys - List keys ending with suffix
+func (ms *MemoryStore) Keys(_ context.Context, suffix string) ([]string, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	keys := []string{}
+	for key := range ms.m {
+		if strings.HasSuffix(key, suffix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
 // Len - Get memory size
 func (ms *MemoryStore) Len() int {
 	ms.lock.Lock()
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"net/mail"
 	"os"
 	"regexp"
 	"strconv"
@@ -9,10 +10,95 @@ import (
 	"time"
 
 	"github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
 )
 
 var _ interfaces.ConfigurationInterface = &Configuration{}
 
+// Signing schemes of HTTP destinations, see pkg/webhooks
+const (
+	SigningSchemeStandard = "standard_webhooks"
+	SigningSchemeHMAC     = "hmac_sha256"
+)
+
+// Outbound authentication strategies of HTTP destinations
+const (
+	HTTPAuthAPIKey = "api_key" // secret in header, X-API-Key by default
+	HTTPAuthBearer = "bearer"  // secret as bearer token
+	HTTPAuthBasic  = "basic"   // username and secret as password
+	HTTPAuthOAuth2 = "oauth2"  // bearer token from OAuth2 client credentials grant, secret as client secret
+)
+
+// Body encodings of HTTP destinations
+const (
+	HTTPEncodingJSON    = "json"
+	HTTPEncodingForm    = "form"
+	HTTPEncodingXML     = "xml"
+	HTTPEncodingMsgpack = "msgpack"
+)
+
+// Body sources of HTTP destinations
+const (
+	HTTPBodyEvent   = "event"   // full event, see IncomingEvent.ToJson
+	HTTPBodyPayload = "payload" // payload only, transformed if transform is set
+)
+
+// Kafka acknowledgements required from brokers before a record is delivered
+const (
+	KafkaAcksAll    = "all"    // all in-sync replicas, default
+	KafkaAcksLeader = "leader" // partition leader only
+	KafkaAcksNone   = "none"   // no acknowledgement
+)
+
+// DefaultKafkaPartitionKey - Records of the same target go to the same partition, so that per-target ordering holds
+const DefaultKafkaPartitionKey = "{{ .target_id }}"
+
+// AMQP delivery modes of published messages
+const (
+	AmqpDeliveryPersistent = "persistent" // written to disk by broker, default
+	AmqpDeliveryTransient  = "transient"  // kept in memory only
+)
+
+// DefaultAmqpRoutingKey - Messages are routed by event key, e.g. "product.update"
+const DefaultAmqpRoutingKey = "{{ .event_key }}"
+
+// DefaultRedisStream - Redis stream is named by the hook
+const DefaultRedisStream = "{{ .hook }}"
+
+// DefaultPushChannel - Subscribers of push sender listen to channel named by the hook
+const DefaultPushChannel = "{{ .hook }}"
+
+// Payload formats of chat incoming webhooks
+const (
+	ChatFormatSlack = "slack" // {"text": ...}, default
+	ChatFormatTeams = "teams" // MessageCard of Microsoft Teams
+)
+
+// DefaultChatTemplate - Text of chat messages of hooks without chat_template
+const DefaultChatTemplate = "*{{ .event_key }}* {{ .target_type }} {{ .target_id }}"
+
+// Transport security of SMTP connections
+const (
+	SMTPTLSStartTLS = "starttls" // upgrade with STARTTLS, required unless set otherwise, default
+	SMTPTLSImplicit = "implicit" // TLS from connect, e.g. port 465
+	SMTPTLSNone     = "none"     // plaintext, e.g. relay on localhost
+)
+
+// Default templates of email notifications
+const (
+	DefaultSMTPSubject  = "[{{ .event_key }}] {{ .target_type }} {{ .target_id }}"
+	DefaultSMTPTextBody = "Event {{ .event_key }} of {{ .target_type }} {{ .target_id }}, the payload is attached."
+)
+
+// MaxSqsDelay - Maximum delay of SQS messages
+const MaxSqsDelay = 15 * time.Minute
+
+// SenderSqs - Sender key of SQS sender, sqs_native_delay is only supported by hooks sending with it
+const SenderSqs = "sqs"
+
+// MaxSigningSecrets - Maximum number of active signing secrets, i.e. the current and the next one during rotation
+const MaxSigningSecrets = 2
+
 // Configuration - Webhook Configuration Model
 type Configuration struct {
 	ConfigID                 string            `json:"id"`
@@ -36,6 +122,309 @@ type Configuration struct {
 	IncludePayloadAttrs      []string          `json:"include_payload_attrs"`
 	ExcludePayloadAttrs      []string          `json:"exclude_payload_attrs"`
 	Extras                   map[string]string `json:"extras"`
+	SampleRate               *float64          `json:"sample_rate"`
+	SampleKey                string            `json:"sample_key"`
+	DeliveryTimezone         string            `json:"delivery_timezone"`
+	DeliveryWindows          []string          `json:"delivery_windows"`
+	DeliveryBlackouts        []string          `json:"delivery_blackouts"`
+	WatchPayloadAttrs        []string          `json:"watch_payload_attrs"`
+	WatchDocumentAttrs       []string          `json:"watch_document_attrs"`
+	WatchTTL                 string            `json:"watch_ttl"`
+	SplitOn                  string            `json:"split_on"`
+	SplitTargetIdField       string            `json:"split_target_id_field"`
+	Transform                string            `json:"transform"`
+	RedactRules              map[string]string `json:"redact_rules"`
+	RedactLogs               bool              `json:"redact_logs"`
+	HTTPSuccessCodes         []int             `json:"http_success_codes"`
+	HTTPTimeout              string            `json:"http_timeout"`
+	HTTPConnectTimeout       string            `json:"http_connect_timeout"`
+	HTTPTLSTimeout           string            `json:"http_tls_timeout"`
+	HTTPKeepAlive            string            `json:"http_keep_alive"`
+	HTTPMaxIdleConns         int               `json:"http_max_idle_conns"`
+	HTTPMaxIdleConnsPerHost  int               `json:"http_max_idle_conns_per_host"`
+	HTTPInsecureSkipVerify   bool              `json:"http_insecure_skip_verify"`
+	HTTPCABundle             string            `json:"http_ca_bundle"`
+	HTTPEnableHTTP2          bool              `json:"http_enable_http2"`
+	SigningSecrets           []string          `json:"signing_secrets"`
+	SigningScheme            string            `json:"signing_scheme"`
+	SigningHeader            string            `json:"signing_header"`
+	HTTPHeaders              map[string]string `json:"http_headers"`
+	HTTPAuth                 string            `json:"http_auth"`
+	HTTPAuthHeader           string            `json:"http_auth_header"`
+	HTTPAuthUsername         string            `json:"http_auth_username"`
+	HTTPAuthSecret           string            `json:"http_auth_secret"`
+	HTTPOAuth2TokenURL       string            `json:"http_oauth2_token_url"`
+	HTTPOAuth2ClientID       string            `json:"http_oauth2_client_id"`
+	HTTPOAuth2Scopes         []string          `json:"http_oauth2_scopes"`
+	HTTPMethod               string            `json:"http_method"`
+	HTTPEncoding             string            `json:"http_encoding"`
+	HTTPBody                 string            `json:"http_body"`
+	HTTPTLSCert              string            `json:"http_tls_cert"`
+	HTTPTLSKey               string            `json:"http_tls_key"`
+	HTTPTLSServerName        string            `json:"http_tls_server_name"`
+	HTTPTLSPins              []string          `json:"http_tls_pins"`
+	Compression              string            `json:"compression"`
+	CompressionThreshold     int               `json:"compression_threshold"`
+	MessageGroupID           string            `json:"message_group_id"`
+	DeduplicationID          string            `json:"deduplication_id"`
+	SqsNativeDelay           bool              `json:"sqs_native_delay"`
+	KafkaTopic               string            `json:"kafka_topic"`
+	KafkaPartitionKey        string            `json:"kafka_partition_key"`
+	KafkaAcks                string            `json:"kafka_acks"`
+	KafkaIdempotent          bool              `json:"kafka_idempotent"`
+	KafkaLinger              string            `json:"kafka_linger"`
+	KafkaBatchSize           int               `json:"kafka_batch_size"`
+	NatsSubject              string            `json:"nats_subject"`
+	NatsJetStream            bool              `json:"nats_jetstream"`
+	NatsAckTimeout           string            `json:"nats_ack_timeout"`
+	AmqpExchange             string            `json:"amqp_exchange"`
+	AmqpRoutingKey           string            `json:"amqp_routing_key"`
+	AmqpDeliveryMode         string            `json:"amqp_delivery_mode"`
+	AmqpHeaders              map[string]string `json:"amqp_headers"`
+	AmqpConfirmTimeout       string            `json:"amqp_confirm_timeout"`
+	RedisStream              string            `json:"redis_stream"`
+	RedisStreamMaxLen        int               `json:"redis_stream_max_len"`
+	RedisStreamTrimExact     bool              `json:"redis_stream_trim_exact"`
+	GRPCTimeout              string            `json:"grpc_timeout"`
+	GRPCPlaintext            bool              `json:"grpc_plaintext"`
+	PushChannel              string            `json:"push_channel"`
+	ChatFormat               string            `json:"chat_format"`
+	ChatTemplate             string            `json:"chat_template"`
+	ChatMinInterval          string            `json:"chat_min_interval"`
+	SMTPFrom                 string            `json:"smtp_from"`
+	SMTPTo                   string            `json:"smtp_to"`
+	SMTPSubject              string            `json:"smtp_subject"`
+	SMTPTextBody             string            `json:"smtp_text_body"`
+	SMTPHTMLBody             string            `json:"smtp_html_body"`
+	SMTPUsername             string            `json:"smtp_username"`
+	SMTPPassword             string            `json:"smtp_password"`
+	SMTPTLS                  string            `json:"smtp_tls"`
+	SMTPTimeout              string            `json:"smtp_timeout"`
+}
+
+// Verify - Check configuration and compile templates, should be called on config load
+func (c Configuration) Verify() error {
+	if c.Transform != "" {
+		if _, err := helpers.CompileTemplate(c.Transform); err != nil {
+			return fmt.Errorf("invalid transform of hook %s: %s", c.Name, err)
+		}
+	}
+	for path, strategy := range c.RedactRules {
+		if err := helpers.VerifyFieldPath(path); err != nil {
+			return fmt.Errorf("invalid redact rule %s of hook %s: %s", path, c.Name, err)
+		}
+		if err := helpers.VerifyRedactStrategy(strategy); err != nil {
+			return fmt.Errorf("invalid redact rule %s of hook %s: %s", path, c.Name, err)
+		}
+		if envKey, salt := c.GetByEnv("redact_salt"); strategy == helpers.RedactHash && salt == "" {
+			return fmt.Errorf("invalid redact rule %s of hook %s: hash requires salt in ENV %s", path, c.Name, envKey)
+		}
+	}
+	attrs := [][]string{c.IncludeDocumentAttrs, c.ExcludeDocumentAttrs, c.IncludePayloadAttrs, c.ExcludePayloadAttrs, c.WatchPayloadAttrs, c.WatchDocumentAttrs}
+	for _, paths := range attrs {
+		for _, path := range paths {
+			if err := helpers.VerifyFieldPath(path); err != nil {
+				return fmt.Errorf("invalid attribute path of hook %s: %s", c.Name, err)
+			}
+		}
+	}
+	if c.SplitOn != "" {
+		// Split events without target of their own would share throttle and document of the parent target
+		if c.SplitTargetIdField == "" {
+			return fmt.Errorf("split_on of hook %s requires split_target_id_field", c.Name)
+		}
+		for _, path := range []string{c.SplitOn, c.SplitTargetIdField} {
+			if err := helpers.VerifyFieldKeyPath(path); err != nil {
+				return fmt.Errorf("invalid split field of hook %s: %s", c.Name, err)
+			}
+		}
+	}
+	for _, code := range c.HTTPSuccessCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid http success code %d of hook %s", code, c.Name)
+		}
+	}
+	if len(c.SigningSecrets) > MaxSigningSecrets {
+		return fmt.Errorf("too many signing secrets of hook %s, at most %d are active during rotation", c.Name, MaxSigningSecrets)
+	}
+	for _, ref := range c.SigningSecrets {
+		if err := helpers.VerifySecretRef(ref); err != nil {
+			return fmt.Errorf("invalid signing secret of hook %s: %s", c.Name, err)
+		}
+	}
+	if scheme := c.SigningScheme; scheme != "" && scheme != SigningSchemeStandard && scheme != SigningSchemeHMAC {
+		return fmt.Errorf("unknown signing scheme %s of hook %s", scheme, c.Name)
+	}
+	for name, value := range c.HTTPHeaders {
+		if _, err := helpers.CompileTemplate(value); err != nil {
+			return fmt.Errorf("invalid http header %s of hook %s: %s", name, c.Name, err)
+		}
+	}
+	switch strings.ToUpper(c.HTTPMethod) {
+	case "", "GET", "POST", "PUT", "PATCH", "DELETE":
+	default:
+		return fmt.Errorf("unsupported http method %s of hook %s", c.HTTPMethod, c.Name)
+	}
+	switch c.HTTPEncoding {
+	case "", HTTPEncodingJSON, HTTPEncodingForm, HTTPEncodingXML, HTTPEncodingMsgpack:
+	default:
+		return fmt.Errorf("unsupported http encoding %s of hook %s", c.HTTPEncoding, c.Name)
+	}
+	switch c.HTTPBody {
+	case "", HTTPBodyEvent, HTTPBodyPayload:
+	default:
+		return fmt.Errorf("unsupported http body %s of hook %s", c.HTTPBody, c.Name)
+	}
+	for _, tmpl := range []string{c.MessageGroupID, c.DeduplicationID} {
+		if _, err := helpers.CompileTemplate(tmpl); err != nil {
+			return fmt.Errorf("invalid message id template of hook %s: %s", c.Name, err)
+		}
+	}
+	if _, err := helpers.CompileTemplate(c.KafkaPartitionKey); err != nil {
+		return fmt.Errorf("invalid kafka partition key of hook %s: %s", c.Name, err)
+	}
+	if _, err := helpers.CompileTemplate(c.NatsSubject); err != nil {
+		return fmt.Errorf("invalid nats subject of hook %s: %s", c.Name, err)
+	}
+	if _, err := helpers.CompileTemplate(c.AmqpRoutingKey); err != nil {
+		return fmt.Errorf("invalid amqp routing key of hook %s: %s", c.Name, err)
+	}
+	for name, value := range c.AmqpHeaders {
+		if _, err := helpers.CompileTemplate(value); err != nil {
+			return fmt.Errorf("invalid amqp header %s of hook %s: %s", name, c.Name, err)
+		}
+	}
+	switch c.AmqpDeliveryMode {
+	case "", AmqpDeliveryPersistent, AmqpDeliveryTransient:
+	default:
+		return fmt.Errorf("unsupported amqp delivery mode %s of hook %s", c.AmqpDeliveryMode, c.Name)
+	}
+	if _, err := helpers.CompileTemplate(c.RedisStream); err != nil {
+		return fmt.Errorf("invalid redis stream of hook %s: %s", c.Name, err)
+	}
+	if c.RedisStreamMaxLen < 0 {
+		return fmt.Errorf("invalid redis stream max len %d of hook %s", c.RedisStreamMaxLen, c.Name)
+	}
+	if _, err := helpers.CompileTemplate(c.PushChannel); err != nil {
+		return fmt.Errorf("invalid push channel of hook %s: %s", c.Name, err)
+	}
+	if _, err := helpers.CompileTemplate(c.ChatTemplate); err != nil {
+		return fmt.Errorf("invalid chat template of hook %s: %s", c.Name, err)
+	}
+	switch c.ChatFormat {
+	case "", ChatFormatSlack, ChatFormatTeams:
+	default:
+		return fmt.Errorf("unsupported chat format %s of hook %s", c.ChatFormat, c.Name)
+	}
+	if err := c.verifySMTP(); err != nil {
+		return fmt.Errorf("invalid smtp of hook %s: %s", c.Name, err)
+	}
+	switch c.KafkaAcks {
+	case "", KafkaAcksAll, KafkaAcksLeader, KafkaAcksNone:
+	default:
+		return fmt.Errorf("unsupported kafka acks %s of hook %s", c.KafkaAcks, c.Name)
+	}
+	if c.KafkaIdempotent && c.GetKafkaAcks() != KafkaAcksAll {
+		return fmt.Errorf("idempotent kafka producer of hook %s requires acks %s", c.Name, KafkaAcksAll)
+	}
+	if c.SqsNativeDelay {
+		if c.Sender != SenderSqs {
+			return fmt.Errorf("sqs native delay of hook %s requires sender %s", c.Name, SenderSqs)
+		}
+		// SQS rejects DelaySeconds of messages on FIFO queues
+		if c.MessageGroupID != "" {
+			return fmt.Errorf("sqs native delay of hook %s is not supported by FIFO queues with message group id", c.Name)
+		}
+		if c.GetDelayValue() > MaxSqsDelay {
+			return fmt.Errorf("delay of hook %s exceeds maximum SQS delay %s", c.Name, MaxSqsDelay)
+		}
+	}
+	if c.Compression != "" {
+		if err := helpers.VerifyCompression(c.Compression); err != nil {
+			return fmt.Errorf("invalid compression of hook %s: %s", c.Name, err)
+		}
+	}
+	if err := c.verifyHTTPTLS(); err != nil {
+		return fmt.Errorf("invalid http tls of hook %s: %s", c.Name, err)
+	}
+	if err := c.verifyHTTPAuth(); err != nil {
+		return fmt.Errorf("invalid http auth of hook %s: %s", c.Name, err)
+	}
+	window, err := NewDeliveryWindow(c.DeliveryTimezone, c.DeliveryWindows, c.DeliveryBlackouts)
+	if err != nil {
+		return fmt.Errorf("invalid delivery window of hook %s: %s", c.Name, err)
+	}
+	if window.NeverOpens(time.Now()) {
+		return fmt.Errorf("delivery window of hook %s never opens", c.Name)
+	}
+	return nil
+}
+
+// verifyHTTPTLS - Check client certificate could be loaded and has not expired
+func (c Configuration) verifyHTTPTLS() error {
+	for _, pin := range c.HTTPTLSPins {
+		if err := helpers.VerifyCertificatePin(pin); err != nil {
+			return err
+		}
+	}
+	if c.HTTPTLSCert == "" && c.HTTPTLSKey == "" {
+		return nil
+	}
+	if c.HTTPTLSCert == "" || c.HTTPTLSKey == "" {
+		return fmt.Errorf("both client certificate and key are required")
+	}
+	cert, err := helpers.LoadCertificate(c.HTTPTLSCert, c.HTTPTLSKey)
+	if err != nil {
+		return err
+	}
+	if time.Now().After(cert.Leaf.NotAfter) {
+		return fmt.Errorf("client certificate expired at %s", cert.Leaf.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (c Configuration) verifyHTTPAuth() error {
+	switch c.HTTPAuth {
+	case "":
+		return nil
+	case HTTPAuthAPIKey, HTTPAuthBearer:
+	case HTTPAuthBasic:
+		if c.HTTPAuthUsername == "" {
+			return fmt.Errorf("username is required")
+		}
+	case HTTPAuthOAuth2:
+		if c.HTTPOAuth2TokenURL == "" || c.HTTPOAuth2ClientID == "" {
+			return fmt.Errorf("token url and client id are required")
+		}
+	default:
+		return fmt.Errorf("unknown strategy %s", c.HTTPAuth)
+	}
+	return helpers.VerifySecretRef(c.HTTPAuthSecret)
+}
+
+func (c Configuration) verifySMTP() error {
+	for name, tmpl := range map[string]string{"recipients": c.SMTPTo, "subject": c.SMTPSubject, "text body": c.SMTPTextBody} {
+		if _, err := helpers.CompileTemplate(tmpl); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	if _, err := helpers.CompileHTMLTemplate(c.SMTPHTMLBody); err != nil {
+		return fmt.Errorf("html body: %s", err)
+	}
+	if c.SMTPFrom != "" {
+		if _, err := mail.ParseAddress(c.SMTPFrom); err != nil {
+			return fmt.Errorf("from: %s", err)
+		}
+	}
+	switch c.SMTPTLS {
+	case "", SMTPTLSStartTLS, SMTPTLSImplicit, SMTPTLSNone:
+	default:
+		return fmt.Errorf("unknown tls %s", c.SMTPTLS)
+	}
+	if c.SMTPUsername == "" {
+		return nil
+	}
+	return helpers.VerifySecretRef(c.SMTPPassword)
 }
 
 func (c Configuration) GetByEnv(key string) (string, string) {
@@ -167,3 +556,450 @@ func (c Configuration) GetExcludePayloadAttrs() []string {
 func (c Configuration) GetExtras() map[string]string {
 	return c.Extras
 }
+
+// GetSampleRate - Get fraction of events to deliver, default to 1 (deliver all)
+func (c Configuration) GetSampleRate() float64 {
+	if c.SampleRate == nil {
+		return 1
+	}
+	return *c.SampleRate
+}
+
+func (c Configuration) GetSampleKey() string {
+	return c.SampleKey
+}
+
+func (c Configuration) GetDeliveryTimezone() string {
+	return c.DeliveryTimezone
+}
+
+func (c Configuration) GetDeliveryWindows() []string {
+	return c.DeliveryWindows
+}
+
+func (c Configuration) GetDeliveryBlackouts() []string {
+	return c.DeliveryBlackouts
+}
+
+func (c Configuration) GetWatchPayloadAttrs() []string {
+	return c.WatchPayloadAttrs
+}
+
+func (c Configuration) GetWatchDocumentAttrs() []string {
+	return c.WatchDocumentAttrs
+}
+
+// GetWatchTTLValue - Get how long the last delivered hash of watched fields is kept, default to 24 hours
+func (c Configuration) GetWatchTTLValue() time.Duration {
+	return c.getDurationOrDefault(c.WatchTTL, 24*time.Hour)
+}
+
+func (c Configuration) GetSplitOn() string {
+	return c.SplitOn
+}
+
+func (c Configuration) GetSplitTargetIdField() string {
+	return c.SplitTargetIdField
+}
+
+func (c Configuration) GetTransform() string {
+	return c.Transform
+}
+
+func (c Configuration) GetRedactRules() map[string]string {
+	return c.RedactRules
+}
+
+func (c Configuration) GetRedactLogs() bool {
+	return c.RedactLogs
+}
+
+// GetHTTPSuccessCodes - Get status codes treated as successful delivery, any 2xx if empty
+func (c Configuration) GetHTTPSuccessCodes() []int {
+	return c.HTTPSuccessCodes
+}
+
+// GetHTTPTimeoutValue - Get total timeout of HTTP request, default to 30 seconds
+func (c Configuration) GetHTTPTimeoutValue() time.Duration {
+	return c.getDurationOrDefault(c.HTTPTimeout, 30*time.Second)
+}
+
+// GetHTTPConnectTimeoutValue - Get timeout of establishing connection, default to 10 seconds
+func (c Configuration) GetHTTPConnectTimeoutValue() time.Duration {
+	return c.getDurationOrDefault(c.HTTPConnectTimeout, 10*time.Second)
+}
+
+// GetHTTPTLSTimeoutValue - Get timeout of TLS handshake, default to 10 seconds
+func (c Configuration) GetHTTPTLSTimeoutValue() time.Duration {
+	return c.getDurationOrDefault(c.HTTPTLSTimeout, 10*time.Second)
+}
+
+// GetHTTPKeepAliveValue - Get keep-alive period of connections, default to 30 seconds
+func (c Configuration) GetHTTPKeepAliveValue() time.Duration {
+	return c.getDurationOrDefault(c.HTTPKeepAlive, 30*time.Second)
+}
+
+// GetHTTPMaxIdleConns - Get maximum idle connections kept, default to 100
+func (c Configuration) GetHTTPMaxIdleConns() int {
+	if c.HTTPMaxIdleConns <= 0 {
+		return 100
+	}
+	return c.HTTPMaxIdleConns
+}
+
+// GetHTTPMaxIdleConnsPerHost - Get maximum idle connections kept per host, default to 10
+func (c Configuration) GetHTTPMaxIdleConnsPerHost() int {
+	if c.HTTPMaxIdleConnsPerHost <= 0 {
+		return 10
+	}
+	return c.HTTPMaxIdleConnsPerHost
+}
+
+func (c Configuration) GetHTTPInsecureSkipVerify() bool {
+	return c.HTTPInsecureSkipVerify
+}
+
+// GetHTTPCABundle - Get path of PEM file with extra CA certificates trusted in addition to system ones
+func (c Configuration) GetHTTPCABundle() string {
+	return c.HTTPCABundle
+}
+
+func (c Configuration) GetHTTPEnableHTTP2() bool {
+	return c.HTTPEnableHTTP2
+}
+
+func (c Configuration) getDurationOrDefault(value string, defaultValue time.Duration) time.Duration {
+	duration := c.GetTimeValueMillis(value)
+	if duration <= 0 {
+		return defaultValue
+	}
+	return duration
+}
+
+// GetSigningSecrets - Get references of active signing secrets, e.g. "env:PARTNER_SECRET"
+func (c Configuration) GetSigningSecrets() []string {
+	return c.SigningSecrets
+}
+
+// GetSigningScheme - Get signing scheme, default to Standard Webhooks
+func (c Configuration) GetSigningScheme() string {
+	if c.SigningScheme == "" {
+		return SigningSchemeStandard
+	}
+	return c.SigningScheme
+}
+
+// GetSigningHeader - Get header of HMAC-SHA256 signature, default to X-Webhook-Signature
+func (c Configuration) GetSigningHeader() string {
+	if c.SigningHeader == "" {
+		return "X-Webhook-Signature"
+	}
+	return c.SigningHeader
+}
+
+// GetHTTPHeaders - Get extra request headers, values are templates rendered with event, e.g. "{{ .target_id }}"
+func (c Configuration) GetHTTPHeaders() map[string]string {
+	return c.HTTPHeaders
+}
+
+func (c Configuration) GetHTTPAuth() string {
+	return c.HTTPAuth
+}
+
+// GetHTTPAuthHeader - Get header of API key, default to X-API-Key
+func (c Configuration) GetHTTPAuthHeader() string {
+	if c.HTTPAuthHeader == "" {
+		return "X-API-Key"
+	}
+	return c.HTTPAuthHeader
+}
+
+func (c Configuration) GetHTTPAuthUsername() string {
+	return c.HTTPAuthUsername
+}
+
+// GetHTTPAuthSecret - Get reference of auth secret, e.g. "env:PARTNER_API_KEY"
+func (c Configuration) GetHTTPAuthSecret() string {
+	return c.HTTPAuthSecret
+}
+
+func (c Configuration) GetHTTPOAuth2TokenURL() string {
+	return c.HTTPOAuth2TokenURL
+}
+
+func (c Configuration) GetHTTPOAuth2ClientID() string {
+	return c.HTTPOAuth2ClientID
+}
+
+func (c Configuration) GetHTTPOAuth2Scopes() []string {
+	return c.HTTPOAuth2Scopes
+}
+
+// GetHTTPMethod - Get request method in upper case, default to POST
+// Body of GET and DELETE requests is sent as query parameters.
+func (c Configuration) GetHTTPMethod() string {
+	if c.HTTPMethod == "" {
+		return "POST"
+	}
+	return strings.ToUpper(c.HTTPMethod)
+}
+
+// GetHTTPEncoding - Get body encoding, default to JSON
+func (c Configuration) GetHTTPEncoding() string {
+	if c.HTTPEncoding == "" {
+		return HTTPEncodingJSON
+	}
+	return c.HTTPEncoding
+}
+
+// GetHTTPBody - Get body source, empty to use default of sender
+func (c Configuration) GetHTTPBody() string {
+	return c.HTTPBody
+}
+
+// GetHTTPTLSCert - Get client certificate in PEM, file path or secret reference
+func (c Configuration) GetHTTPTLSCert() string {
+	return c.HTTPTLSCert
+}
+
+// GetHTTPTLSKey - Get private key of client certificate in PEM, file path or secret reference
+func (c Configuration) GetHTTPTLSKey() string {
+	return c.HTTPTLSKey
+}
+
+func (c Configuration) GetHTTPTLSServerName() string {
+	return c.HTTPTLSServerName
+}
+
+// GetHTTPTLSPins - Get pinned public keys, e.g. "sha256/{base64 digest of SPKI}", any certificate in chain should match
+func (c Configuration) GetHTTPTLSPins() []string {
+	return c.HTTPTLSPins
+}
+
+// GetCompression - Get compression of delivered bodies, "gzip" or "zstd", empty if not compressed
+func (c Configuration) GetCompression() string {
+	return c.Compression
+}
+
+// GetCompressionThreshold - Get minimum body size in bytes to be compressed, default to 1 KiB
+func (c Configuration) GetCompressionThreshold() int {
+	if c.CompressionThreshold <= 0 {
+		return 1024
+	}
+	return c.CompressionThreshold
+}
+
+// GetMessageGroupID - Get template of message group ID for FIFO queues and topics, e.g. "{{ .target_id }}"
+func (c Configuration) GetMessageGroupID() string {
+	return c.MessageGroupID
+}
+
+// GetDeduplicationID - Get template of deduplication ID for FIFO queues and topics, e.g. "{{ .trace_id }}-{{ .hook }}"
+func (c Configuration) GetDeduplicationID() string {
+	return c.DeduplicationID
+}
+
+// GetSqsNativeDelay - Check if delay of hook is done by SQS DelaySeconds instead of dispatch delayer
+// Hooks of other senders are delayed by dispatch delayer, so that the delay is not skipped.
+func (c Configuration) GetSqsNativeDelay() bool {
+	return c.SqsNativeDelay && c.Sender == SenderSqs
+}
+
+// GetKafkaTopic - Get topic of kafka records, default to callback_url
+func (c Configuration) GetKafkaTopic() string {
+	if c.KafkaTopic == "" {
+		return c.GetCallbackURL()
+	}
+	return c.KafkaTopic
+}
+
+// GetKafkaPartitionKey - Get template of record key deciding the partition, default to target ID
+func (c Configuration) GetKafkaPartitionKey() string {
+	if c.KafkaPartitionKey == "" {
+		return DefaultKafkaPartitionKey
+	}
+	return c.KafkaPartitionKey
+}
+
+// GetKafkaAcks - Get acknowledgements required from brokers, default to all in-sync replicas
+func (c Configuration) GetKafkaAcks() string {
+	if c.KafkaAcks == "" {
+		return KafkaAcksAll
+	}
+	return c.KafkaAcks
+}
+
+// GetKafkaIdempotent - Check if producer is idempotent, i.e. retries do not duplicate records
+func (c Configuration) GetKafkaIdempotent() bool {
+	return c.KafkaIdempotent
+}
+
+// GetKafkaLingerValue - Get time for records to be batched before sent, 0 sends records as soon as possible
+func (c Configuration) GetKafkaLingerValue() time.Duration {
+	return c.getDurationOrDefault(c.KafkaLinger, 0)
+}
+
+// GetKafkaBatchSize - Get number of records to trigger sending of a batch, 0 for no limit
+func (c Configuration) GetKafkaBatchSize() int {
+	return c.KafkaBatchSize
+}
+
+// GetNatsSubject - Get template of NATS subject, e.g. "captin.{{ .event_key }}", default to callback_url
+func (c Configuration) GetNatsSubject() string {
+	if c.NatsSubject == "" {
+		return c.GetCallbackURL()
+	}
+	return c.NatsSubject
+}
+
+// GetNatsJetStream - Check if events are published to JetStream and acknowledged by stream
+func (c Configuration) GetNatsJetStream() bool {
+	return c.NatsJetStream
+}
+
+// GetNatsAckTimeoutValue - Get timeout of waiting JetStream publish ack, default to 5 seconds
+func (c Configuration) GetNatsAckTimeoutValue() time.Duration {
+	return c.getDurationOrDefault(c.NatsAckTimeout, 5*time.Second)
+}
+
+// GetAmqpExchange - Get exchange of AMQP messages, default exchange routes to queue named by routing key
+func (c Configuration) GetAmqpExchange() string {
+	return c.AmqpExchange
+}
+
+// GetAmqpRoutingKey - Get template of routing key, default to event key
+func (c Configuration) GetAmqpRoutingKey() string {
+	if c.AmqpRoutingKey == "" {
+		return DefaultAmqpRoutingKey
+	}
+	return c.AmqpRoutingKey
+}
+
+// GetAmqpDeliveryMode - Get delivery mode of AMQP messages, default to persistent
+func (c Configuration) GetAmqpDeliveryMode() string {
+	if c.AmqpDeliveryMode == "" {
+		return AmqpDeliveryPersistent
+	}
+	return c.AmqpDeliveryMode
+}
+
+// GetAmqpHeaders - Get headers of AMQP messages, values are templates rendered with event
+func (c Configuration) GetAmqpHeaders() map[string]string {
+	return c.AmqpHeaders
+}
+
+// GetAmqpConfirmTimeoutValue - Get timeout of waiting publisher confirm, default to 5 seconds
+func (c Configuration) GetAmqpConfirmTimeoutValue() time.Duration {
+	return c.getDurationOrDefault(c.AmqpConfirmTimeout, 5*time.Second)
+}
+
+// GetRedisStream - Get template of redis stream name, default to hook name
+func (c Configuration) GetRedisStream() string {
+	if c.RedisStream == "" {
+		return DefaultRedisStream
+	}
+	return c.RedisStream
+}
+
+// GetRedisStreamMaxLen - Get maximum length of redis stream kept by trimming on add, 0 for no trimming
+func (c Configuration) GetRedisStreamMaxLen() int {
+	return c.RedisStreamMaxLen
+}
+
+// GetRedisStreamTrimExact - Check if redis stream is trimmed to exact max len, instead of the cheaper approximate trimming
+func (c Configuration) GetRedisStreamTrimExact() bool {
+	return c.RedisStreamTrimExact
+}
+
+// GetGRPCTimeoutValue - Get deadline of grpc call, default to 10 seconds
+func (c Configuration) GetGRPCTimeoutValue() time.Duration {
+	return c.getDurationOrDefault(c.GRPCTimeout, 10*time.Second)
+}
+
+// GetGRPCPlaintext - Check if grpc connection is made without TLS, e.g. inside service mesh
+func (c Configuration) GetGRPCPlaintext() bool {
+	return c.GRPCPlaintext
+}
+
+// GetPushChannel - Get template of push channel, e.g. "merchant:{{ .control.merchant_id }}", default to hook name
+func (c Configuration) GetPushChannel() string {
+	if c.PushChannel == "" {
+		return DefaultPushChannel
+	}
+	return c.PushChannel
+}
+
+// GetChatFormat - Get payload format of chat webhook, default to slack
+func (c Configuration) GetChatFormat() string {
+	if c.ChatFormat == "" {
+		return ChatFormatSlack
+	}
+	return c.ChatFormat
+}
+
+// GetChatTemplate - Get template of chat message text, markdown is rendered by chat service
+func (c Configuration) GetChatTemplate() string {
+	if c.ChatTemplate == "" {
+		return DefaultChatTemplate
+	}
+	return c.ChatTemplate
+}
+
+// GetChatMinIntervalValue - Get minimum interval between messages posted to the same webhook, default to 1 second
+func (c Configuration) GetChatMinIntervalValue() time.Duration {
+	return c.getDurationOrDefault(c.ChatMinInterval, time.Second)
+}
+
+// GetSMTPFrom - Get sender address of emails, e.g. "Captin <captin@example.com>", default address of sender if empty
+func (c Configuration) GetSMTPFrom() string {
+	return c.SMTPFrom
+}
+
+// GetSMTPTo - Get template of comma separated recipients, e.g. "{{ .payload.partner_email }}"
+func (c Configuration) GetSMTPTo() string {
+	return c.SMTPTo
+}
+
+// GetSMTPSubject - Get template of email subject
+func (c Configuration) GetSMTPSubject() string {
+	if c.SMTPSubject == "" {
+		return DefaultSMTPSubject
+	}
+	return c.SMTPSubject
+}
+
+// GetSMTPTextBody - Get template of plain-text email body
+func (c Configuration) GetSMTPTextBody() string {
+	if c.SMTPTextBody == "" {
+		return DefaultSMTPTextBody
+	}
+	return c.SMTPTextBody
+}
+
+// GetSMTPHTMLBody - Get html/template of HTML email body, emails are plain-text only if empty
+func (c Configuration) GetSMTPHTMLBody() string {
+	return c.SMTPHTMLBody
+}
+
+// GetSMTPUsername - Get username of SMTP AUTH, emails are sent without authentication if empty
+func (c Configuration) GetSMTPUsername() string {
+	return c.SMTPUsername
+}
+
+// GetSMTPPassword - Get reference of SMTP AUTH password, e.g. "env:SMTP_PASSWORD"
+func (c Configuration) GetSMTPPassword() string {
+	return c.SMTPPassword
+}
+
+// GetSMTPTLS - Get transport security of SMTP connection, default to STARTTLS
+func (c Configuration) GetSMTPTLS() string {
+	if c.SMTPTLS == "" {
+		return SMTPTLSStartTLS
+	}
+	return c.SMTPTLS
+}
+
+// GetSMTPTimeoutValue - Get timeout of SMTP delivery, default to 30 seconds
+func (c Configuration) GetSMTPTimeoutValue() time.Duration {
+	return c.getDurationOrDefault(c.SMTPTimeout, 30*time.Second)
+}
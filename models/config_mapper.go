@@ -49,6 +49,10 @@ func NewConfigurationMapperFromPath(path string) *ConfigurationMapper {
 
 	configs := []interfaces.ConfigurationInterface{}
 	for _, c := range raw {
+		if err := c.Verify(); err != nil {
+			pathLogger.WithFields(log.Fields{"error": err}).Error("Invalid configuration")
+			panic(err)
+		}
 		configs = append(configs, c)
 	}
 	return NewConfigurationMapper(configs)
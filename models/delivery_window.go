@@ -0,0 +1,252 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Maximum look ahead for next opening of delivery window, a weekly schedule opens within a week if ever
+const deliveryWindowLookAhead = 8 * 24 * time.Hour
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// DeliveryWindow - Time ranges in which events could be delivered to destination
+// Ranges are in the form of "{days} {HH:MM}-{HH:MM}", where days could be
+//   - "*" for every day
+//   - weekday names or ranges, e.g. "mon", "mon-fri", "sat,sun"
+//   - dates, e.g. "2020-12-25", for scheduled blackout periods
+//
+// A range ending before its start wraps to the next day, e.g. "fri 22:00-02:00"
+type DeliveryWindow struct {
+	Location *time.Location
+	Allow    []DeliveryRange
+	Blackout []DeliveryRange
+}
+
+// DeliveryRange - A parsed delivery window range
+type DeliveryRange struct {
+	weekdays map[time.Weekday]bool
+	dates    map[string]bool
+	start    int // minutes from midnight
+	end      int // minutes from midnight
+}
+
+// NewDeliveryWindow - Parse delivery window from timezone and ranges
+func NewDeliveryWindow(timezone string, allow []string, blackout []string) (*DeliveryWindow, error) {
+	location := time.UTC
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, err
+		}
+		location = loc
+	}
+
+	w := DeliveryWindow{Location: location}
+	for _, value := range allow {
+		r, err := parseDeliveryRange(value)
+		if err != nil {
+			return nil, err
+		}
+		w.Allow = append(w.Allow, r)
+	}
+	for _, value := range blackout {
+		r, err := parseDeliveryRange(value)
+		if err != nil {
+			return nil, err
+		}
+		w.Blackout = append(w.Blackout, r)
+	}
+	return &w, nil
+}
+
+// IsOpen - Check if events could be delivered at given time
+func (w DeliveryWindow) IsOpen(t time.Time) bool {
+	t = t.In(w.Location)
+	if len(w.Allow) > 0 && !matchAnyRange(w.Allow, t) {
+		return false
+	}
+	return !matchAnyRange(w.Blackout, t)
+}
+
+// NextOpen - Get the duration until delivery window opens, 0 if it is open at given time
+func (w DeliveryWindow) NextOpen(t time.Time) (time.Duration, error) {
+	if w.IsOpen(t) {
+		return 0, nil
+	}
+	if next, ok := w.nextOpenTime(t, t.Add(deliveryWindowLookAhead)); ok {
+		return next.Sub(t), nil
+	}
+	return 0, fmt.Errorf("delivery window does not open within %s", deliveryWindowLookAhead)
+}
+
+// NeverOpens - Check if delivery window is closed from given time on
+// Dated ranges end at their last date, the weekly ranges left after it open within a week if ever.
+func (w DeliveryWindow) NeverOpens(t time.Time) bool {
+	if w.IsOpen(t) {
+		return false
+	}
+	until := t
+	for _, r := range append(append([]DeliveryRange{}, w.Allow...), w.Blackout...) {
+		for date := range r.dates {
+			// Range of date could wrap to the next day
+			if end, err := time.ParseInLocation("2006-01-02", date, w.Location); err == nil && end.AddDate(0, 0, 2).After(until) {
+				until = end.AddDate(0, 0, 2)
+			}
+		}
+	}
+	_, ok := w.nextOpenTime(t, until.Add(deliveryWindowLookAhead))
+	return !ok
+}
+
+// nextOpenTime - Find the first time after t and until which window is open at
+// Window could only open when a range of Allow starts or a range of Blackout ends, so only these boundaries are checked.
+func (w DeliveryWindow) nextOpenTime(t time.Time, until time.Time) (time.Time, bool) {
+	minutes := w.openingMinutes()
+	local := t.In(w.Location)
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.Location); !day.After(until); day = day.AddDate(0, 0, 1) {
+		for _, minute := range minutes {
+			next := time.Date(day.Year(), day.Month(), day.Day(), 0, minute, 0, 0, w.Location)
+			if next.After(until) {
+				return time.Time{}, false
+			}
+			if next.After(t) && w.IsOpen(next) {
+				return next, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// openingMinutes - Minutes of day at which window could open in ascending order
+func (w DeliveryWindow) openingMinutes() []int {
+	found := map[int]bool{}
+	for _, r := range w.Allow {
+		found[r.start] = true
+	}
+	for _, r := range w.Blackout {
+		found[r.end] = true
+	}
+	minutes := make([]int, 0, len(found))
+	for minute := range found {
+		minutes = append(minutes, minute)
+	}
+	sort.Ints(minutes)
+	return minutes
+}
+
+func matchAnyRange(ranges []DeliveryRange, t time.Time) bool {
+	for _, r := range ranges {
+		if r.match(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r DeliveryRange) matchDay(t time.Time) bool {
+	if r.weekdays == nil && r.dates == nil {
+		return true
+	}
+	return r.weekdays[t.Weekday()] || r.dates[t.Format("2006-01-02")]
+}
+
+func (r DeliveryRange) match(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if r.start < r.end {
+		return r.matchDay(t) && minute >= r.start && minute < r.end
+	}
+	// Range wraps midnight, the tail belongs to the previous day
+	if minute >= r.start {
+		return r.matchDay(t)
+	}
+	return minute < r.end && r.matchDay(t.AddDate(0, 0, -1))
+}
+
+func parseDeliveryRange(value string) (DeliveryRange, error) {
+	r := DeliveryRange{}
+	parts := strings.Fields(value)
+	if len(parts) != 2 {
+		return r, fmt.Errorf("invalid delivery range %q, expected \"{days} {HH:MM}-{HH:MM}\"", value)
+	}
+
+	if parts[0] != "*" {
+		for _, day := range strings.Split(strings.ToLower(parts[0]), ",") {
+			if err := r.addDays(day); err != nil {
+				return r, fmt.Errorf("invalid delivery range %q: %s", value, err)
+			}
+		}
+	}
+
+	times := strings.Split(parts[1], "-")
+	if len(times) != 2 {
+		return r, fmt.Errorf("invalid delivery range %q, expected time range \"HH:MM-HH:MM\"", value)
+	}
+	var err error
+	if r.start, err = parseClock(times[0]); err != nil {
+		return r, fmt.Errorf("invalid delivery range %q: %s", value, err)
+	}
+	if r.end, err = parseClock(times[1]); err != nil {
+		return r, fmt.Errorf("invalid delivery range %q: %s", value, err)
+	}
+	if r.start == r.end {
+		return r, fmt.Errorf("invalid delivery range %q, empty time range", value)
+	}
+	return r, nil
+}
+
+func (r *DeliveryRange) addDays(day string) error {
+	if _, err := time.Parse("2006-01-02", day); err == nil {
+		if r.dates == nil {
+			r.dates = map[string]bool{}
+		}
+		r.dates[day] = true
+		return nil
+	}
+
+	if r.weekdays == nil {
+		r.weekdays = map[time.Weekday]bool{}
+	}
+	bounds := strings.Split(day, "-")
+	from, ok := weekdayNames[bounds[0]]
+	if !ok {
+		return fmt.Errorf("unknown day %q", bounds[0])
+	}
+	to := from
+	if len(bounds) == 2 {
+		if to, ok = weekdayNames[bounds[1]]; !ok {
+			return fmt.Errorf("unknown day %q", bounds[1])
+		}
+	} else if len(bounds) > 2 {
+		return fmt.Errorf("unknown day %q", day)
+	}
+	for d := from; ; d = (d + 1) % 7 {
+		r.weekdays[d] = true
+		if d == to {
+			break
+		}
+	}
+	return nil
+}
+
+func parseClock(value string) (int, error) {
+	// 24:00 is allowed as the end of a day
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
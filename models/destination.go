@@ -5,9 +5,11 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
 )
 
 // Destination - Event dispatch destination
@@ -20,6 +22,9 @@ type Destination struct {
 
 var DEFAULT_RETRY_BACKOFF_SECONDS int64 = 10
 
+// Parsed delivery windows, keyed by timezone and ranges, to avoid loading timezone on every event
+var deliveryWindowCache sync.Map
+
 func (d Destination) GetConfig() interfaces.ConfigurationInterface {
 	return d.Config
 }
@@ -44,6 +49,12 @@ func (d Destination) GetSqsSenderConfig(key string) string {
 	return value
 }
 
+// GetSnsSenderConfig - Get AWS config of SNS sender for destination, e.g. HOOK_{NAME}_SNS_SENDER_AWS_REGION
+func (d Destination) GetSnsSenderConfig(key string) string {
+	_, value := d.Config.GetByEnv(fmt.Sprintf("SNS_SENDER_%s", key))
+	return value
+}
+
 func (d Destination) GetDocumentStore() string {
 	_, value := d.Config.GetByEnv("document_store")
 	if len(value) == 0 {
@@ -55,15 +66,116 @@ func (d Destination) GetDocumentStore() string {
 	return value
 }
 
+// GetSampleRate - Get sample rate of destination, could be overridden by ENV for gradual rollout
+func (d Destination) GetSampleRate() float64 {
+	_, value := d.Config.GetByEnv("sample_rate")
+	if len(value) > 0 {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			return rate
+		}
+	}
+	return d.Config.GetSampleRate()
+}
+
+// GetDeliveryWindow - Get parsed delivery window, nil if destination accepts events at any time
+func (d Destination) GetDeliveryWindow() (*DeliveryWindow, error) {
+	config := d.Config
+	if len(config.GetDeliveryWindows()) == 0 && len(config.GetDeliveryBlackouts()) == 0 {
+		return nil, nil
+	}
+
+	cacheKey := fmt.Sprintf("%s|%s|%s", config.GetDeliveryTimezone(), strings.Join(config.GetDeliveryWindows(), ";"), strings.Join(config.GetDeliveryBlackouts(), ";"))
+	if cached, ok := deliveryWindowCache.Load(cacheKey); ok {
+		return cached.(*DeliveryWindow), nil
+	}
+	window, err := NewDeliveryWindow(config.GetDeliveryTimezone(), config.GetDeliveryWindows(), config.GetDeliveryBlackouts())
+	if err != nil {
+		return nil, err
+	}
+	deliveryWindowCache.Store(cacheKey, window)
+	return window, nil
+}
+
+// GetDeliveryWait - Get the duration to hold event until delivery window opens, 0 if event could be delivered now
+func (d Destination) GetDeliveryWait(now time.Time) (time.Duration, error) {
+	window, err := d.GetDeliveryWindow()
+	if err != nil || window == nil {
+		return 0, err
+	}
+	return window.NextOpen(now)
+}
+
+// Redact - Redact object by redact rules of destination, hash strategy is salted by ENV HOOK_{Config Name}_REDACT_SALT
+// and required by Verify, values are masked instead of hashed without salt.
+func (d Destination) Redact(object map[string]interface{}) map[string]interface{} {
+	_, salt := d.Config.GetByEnv("redact_salt")
+	return helpers.Redact(object, d.Config.GetRedactRules(), salt)
+}
+
+// RedactEvent - Redact payload, document and throttled ones of event for sending to destination
+func (d Destination) RedactEvent(e IncomingEvent) IncomingEvent {
+	if len(d.Config.GetRedactRules()) == 0 {
+		return e
+	}
+	e.Payload = d.Redact(e.Payload)
+	e.TargetDocument = d.Redact(e.TargetDocument)
+	if e.ThrottledPayloads != nil {
+		throttledPayloads := make([]map[string]interface{}, len(e.ThrottledPayloads))
+		for i, payload := range e.ThrottledPayloads {
+			throttledPayloads[i] = d.Redact(payload)
+		}
+		e.ThrottledPayloads = throttledPayloads
+	}
+	if e.ThrottledDocuments != nil {
+		throttledDocuments := make([]map[string]interface{}, len(e.ThrottledDocuments))
+		for i, document := range e.ThrottledDocuments {
+			throttledDocuments[i] = d.Redact(document)
+		}
+		e.ThrottledDocuments = throttledDocuments
+	}
+	return e
+}
+
+// RedactForLog - Redact object for logging if redact_logs is enabled
+func (d Destination) RedactForLog(object map[string]interface{}) map[string]interface{} {
+	if !d.Config.GetRedactLogs() {
+		return object
+	}
+	return d.Redact(object)
+}
+
+// RedactEventForLog - Redact event for logging if redact_logs is enabled
+// Log hooks and formatters could serialize the whole event, so payloads and documents are redacted as well.
+func (d Destination) RedactEventForLog(e IncomingEvent) IncomingEvent {
+	// Destination of errors could be empty, e.g. event failed before destination is resolved
+	if d.Config == nil || !d.Config.GetRedactLogs() {
+		return e
+	}
+	return d.RedactEvent(e)
+}
+
 func (d Destination) RequireDelay(evt interfaces.IncomingEventInterface) bool {
 	if d.Config.GetDelayValue() <= time.Duration(0) ||
 		evt.GetOutstandingDelaySeconds() == time.Duration(0) {
 		return false
 	}
 
+	// Delay is done by sender, e.g. SQS DelaySeconds
+	if d.Config.GetSqsNativeDelay() {
+		return false
+	}
+
 	return true
 }
 
+// RequireNativeDelay - Check if sender should delay event natively, e.g. SQS DelaySeconds
+func (d Destination) RequireNativeDelay(evt interfaces.IncomingEventInterface) bool {
+	return d.Config.GetSqsNativeDelay() &&
+		d.Config.GetDelayValue() > time.Duration(0) &&
+		evt.GetOutstandingDelaySeconds() != time.Duration(0)
+}
+
 func (d Destination) GetRetryBackoffSeconds(evt interfaces.IncomingEventInterface) int64 {
 	globalRetryBackoffSeconds := os.Getenv("APP_GLOBAL_RETRY_BACKOFF_SECONDS")
 	backoffConfig := trimArray(d.Config.GetRetryBackoff())
@@ -102,3 +214,16 @@ func trimArray(arr []string) []string {
 	}
 	return r
 }
+
+// Compress - Compress body by compression of destination, returns the algorithm used or empty if body is not compressed
+func (d Destination) Compress(body []byte) ([]byte, string, error) {
+	algorithm := d.Config.GetCompression()
+	if algorithm == "" || len(body) < d.Config.GetCompressionThreshold() {
+		return body, "", nil
+	}
+	compressed, err := helpers.Compress(body, algorithm)
+	if err != nil {
+		return nil, "", err
+	}
+	return compressed, algorithm, nil
+}
@@ -138,3 +138,10 @@ func (e IncomingEvent) ToMap() map[string]interface{} {
 
 	return out
 }
+
+// TemplateData - Event fields exposed to hook templates, keyed by JSON field names
+func (e IncomingEvent) TemplateData(hook string) map[string]interface{} {
+	data := e.ToMap()
+	data["hook"] = hook
+	return data
+}
@@ -0,0 +1,4 @@
+// Package eventpb - Protobuf envelope of events delivered by the grpc sender, for consumers to implement EventReceiver
+package eventpb
+
+//go:generate protoc --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. event.proto
@@ -0,0 +1,101 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package eventpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// EventReceiverClient is the client API for EventReceiver service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EventReceiverClient interface {
+	ReceiveEvent(ctx context.Context, in *Event, opts ...grpc.CallOption) (*ReceiveEventResponse, error)
+}
+
+type eventReceiverClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEventReceiverClient(cc grpc.ClientConnInterface) EventReceiverClient {
+	return &eventReceiverClient{cc}
+}
+
+func (c *eventReceiverClient) ReceiveEvent(ctx context.Context, in *Event, opts ...grpc.CallOption) (*ReceiveEventResponse, error) {
+	out := new(ReceiveEventResponse)
+	err := c.cc.Invoke(ctx, "/captin.v1.EventReceiver/ReceiveEvent", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EventReceiverServer is the server API for EventReceiver service.
+// All implementations must embed UnimplementedEventReceiverServer
+// for forward compatibility
+type EventReceiverServer interface {
+	ReceiveEvent(context.Context, *Event) (*ReceiveEventResponse, error)
+	mustEmbedUnimplementedEventReceiverServer()
+}
+
+// UnimplementedEventReceiverServer must be embedded to have forward compatible implementations.
+type UnimplementedEventReceiverServer struct {
+}
+
+func (UnimplementedEventReceiverServer) ReceiveEvent(context.Context, *Event) (*ReceiveEventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReceiveEvent not implemented")
+}
+func (UnimplementedEventReceiverServer) mustEmbedUnimplementedEventReceiverServer() {}
+
+// UnsafeEventReceiverServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EventReceiverServer will
+// result in compilation errors.
+type UnsafeEventReceiverServer interface {
+	mustEmbedUnimplementedEventReceiverServer()
+}
+
+func RegisterEventReceiverServer(s grpc.ServiceRegistrar, srv EventReceiverServer) {
+	s.RegisterService(&EventReceiver_ServiceDesc, srv)
+}
+
+func _EventReceiver_ReceiveEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Event)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventReceiverServer).ReceiveEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/captin.v1.EventReceiver/ReceiveEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventReceiverServer).ReceiveEvent(ctx, req.(*Event))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// EventReceiver_ServiceDesc is the grpc.ServiceDesc for EventReceiver service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EventReceiver_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "captin.v1.EventReceiver",
+	HandlerType: (*EventReceiverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReceiveEvent",
+			Handler:    _EventReceiver_ReceiveEvent_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "event.proto",
+}
@@ -0,0 +1,136 @@
+// Package webhooks signs and verifies webhooks sent by captin
+//
+// Two schemes are supported:
+//   - Standard Webhooks (https://www.standardwebhooks.com), with "webhook-id", "webhook-timestamp"
+//     and "webhook-signature" headers, signature is "v1,{base64 HMAC-SHA256 of id.timestamp.body}"
+//   - HMAC-SHA256 of body in a single header, signature is "sha256={hex digest}"
+//
+// Receivers verify webhooks with all active secrets, so that secrets could be rotated without downtime.
+// Signatures cover the body before compression, receivers should verify the decompressed body
+// of requests with Content-Encoding. Requests without body, e.g. GET and DELETE, carry the event
+// in query parameters and the signature covers CanonicalQuery of request URL instead.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Standard Webhooks headers
+const (
+	HeaderID        = "webhook-id"
+	HeaderTimestamp = "webhook-timestamp"
+	HeaderSignature = "webhook-signature"
+)
+
+// DefaultTolerance - Maximum age of webhook timestamp accepted by Verify, against replay attacks
+const DefaultTolerance = 5 * time.Minute
+
+// Prefix of Standard Webhooks secrets, the rest is base64 encoded key
+const secretPrefix = "whsec_"
+
+var (
+	ErrMissingHeaders   = errors.New("webhooks: missing signature headers")
+	ErrInvalidTimestamp = errors.New("webhooks: invalid timestamp")
+	ErrExpired          = errors.New("webhooks: timestamp outside tolerance")
+	ErrNoMatch          = errors.New("webhooks: no matching signature")
+)
+
+// Sign - Standard Webhooks signature of body, e.g. "v1,K5oZ..."
+func Sign(secret string, id string, timestamp time.Time, body []byte) (string, error) {
+	key, err := secretKey(secret)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(fmt.Sprintf("%s.%d.", id, timestamp.Unix())))
+	mac.Write(body)
+	return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SignHMAC - HMAC-SHA256 signature of body, e.g. "sha256=3f2a..."
+func SignHMAC(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify - Verify Standard Webhooks headers against any of the secrets
+// Tolerance limits the age of webhook timestamp, DefaultTolerance is used if it is zero.
+func Verify(secrets []string, header http.Header, body []byte, tolerance time.Duration) error {
+	id := header.Get(HeaderID)
+	ts := header.Get(HeaderTimestamp)
+	signatures := header.Get(HeaderSignature)
+	if id == "" || ts == "" || signatures == "" {
+		return ErrMissingHeaders
+	}
+
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+	timestamp := time.Unix(seconds, 0)
+	if age := time.Since(timestamp); age > tolerance || age < -tolerance {
+		return ErrExpired
+	}
+
+	for _, secret := range secrets {
+		expected, err := Sign(secret, id, timestamp, body)
+		if err != nil {
+			return err
+		}
+		// Header could carry multiple signatures separated by space, e.g. during rotation
+		for _, signature := range strings.Fields(signatures) {
+			if hmac.Equal([]byte(signature), []byte(expected)) {
+				return nil
+			}
+		}
+	}
+	return ErrNoMatch
+}
+
+// VerifyHMAC - Verify HMAC-SHA256 signature header value against any of the secrets
+func VerifyHMAC(secrets []string, signatures string, body []byte) error {
+	if signatures == "" {
+		return ErrMissingHeaders
+	}
+	for _, secret := range secrets {
+		expected := SignHMAC(secret, body)
+		for _, signature := range strings.Fields(signatures) {
+			if hmac.Equal([]byte(signature), []byte(expected)) {
+				return nil
+			}
+		}
+	}
+	return ErrNoMatch
+}
+
+// CanonicalQuery - Signed content of requests without body, query parameters encoded in order of keys
+// Receivers should verify it instead of the raw query string, which could be reordered by proxies.
+func CanonicalQuery(u *url.URL) []byte {
+	return []byte(u.Query().Encode())
+}
+
+// secretKey - Decode "whsec_" prefixed secrets, other secrets are used as raw bytes
+func secretKey(secret string) ([]byte, error) {
+	if !strings.HasPrefix(secret, secretPrefix) {
+		return []byte(secret), nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, secretPrefix))
+	if err != nil {
+		return nil, errors.New("webhooks: invalid whsec_ secret encoding")
+	}
+	return key, nil
+}
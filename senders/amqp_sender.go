@@ -0,0 +1,429 @@
+package senders
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var aLogger = log.WithFields(log.Fields{"class": "AmqpSender"})
+
+var _ interfaces.EventSenderInterface = &AmqpSender{}
+var _ interfaces.EventSenderCloserInterface = &AmqpSender{}
+
+// DefaultAmqpPoolSize - Number of channels publishing concurrently on the connection
+const DefaultAmqpPoolSize = 4
+
+// Buffer of confirms and returns of a channel, so that connection is not blocked while they are matched to publishes
+const amqpNotifyBuffer = 100
+
+var (
+	errAmqpSenderClosed  = errors.New("amqp sender is closed")
+	errAmqpChannelClosed = errors.New("amqp channel is closed before publish is confirmed")
+	errAmqpNack          = errors.New("amqp publish is nacked by broker")
+)
+
+// amqpReturnedError - Message is returned by broker as it is not routed to any queue
+type amqpReturnedError struct {
+	code uint16
+	text string
+}
+
+func (e amqpReturnedError) Error() string {
+	return fmt.Sprintf("amqp message is returned by broker: %d %s", e.code, e.text)
+}
+
+// AmqpConnection - Connection to AMQP broker, see DialAmqp
+type AmqpConnection interface {
+	Channel() (AmqpChannel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// AmqpChannel - Channel of AMQP connection, implemented by *amqp.Channel
+type AmqpChannel interface {
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	NotifyReturn(returns chan amqp.Return) chan amqp.Return
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+// DialAmqp - Dial AMQP broker with amqp091-go
+func DialAmqp(url string) (AmqpConnection, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return amqpConnection{conn}, nil
+}
+
+type amqpConnection struct {
+	*amqp.Connection
+}
+
+func (c amqpConnection) Channel() (AmqpChannel, error) {
+	return c.Connection.Channel()
+}
+
+// AmqpSender - Publish Event to AMQP exchange with publisher confirms
+// Messages are published as mandatory, so that messages not routed to any queue are failed and retried instead of dropped.
+// Connection and channels are long-lived, they are recreated on next send after being closed by broker or network.
+type AmqpSender struct {
+	URL      string
+	PoolSize int
+	Dial     func(url string) (AmqpConnection, error)
+
+	mu       sync.Mutex
+	closed   bool
+	conn     AmqpConnection
+	channels []*amqpConfirmChannel
+	next     int
+}
+
+func NewAmqpSender(url string) *AmqpSender {
+	return &AmqpSender{URL: url, PoolSize: DefaultAmqpPoolSize, Dial: DialAmqp}
+}
+
+// SendEvent - Publish incoming event to exchange and wait for confirm of broker
+func (s *AmqpSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
+	ctx, span := helpers.Tracer().Start(ctx, "captin.AmqpSender.SendEvent")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	e := ev.(models.IncomingEvent)
+	d := dv.(models.Destination)
+
+	e.DistributedTracingInfo.InjectContext(ctx)
+	payload, jsonErr := e.ToJson()
+	if jsonErr != nil {
+		aLogger.WithFields(log.Fields{"error": jsonErr}).Error("Failed to convert incoming event to json payload")
+		return jsonErr
+	}
+
+	exchange := d.Config.GetAmqpExchange()
+	routingKey, msg, err := newAmqpPublishing(payload, e, d)
+	if err != nil {
+		aLogger.WithFields(log.Fields{"error": err}).Error("Failed to prepare amqp message")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	span.SetAttributes(attribute.String("exchange", exchange), attribute.String("routingKey", routingKey))
+	aLogger.WithFields(log.Fields{"exchange": exchange, "routingKey": routingKey}).Debug("Send amqp event")
+
+	err = s.publish(ctx, exchange, routingKey, msg, d.Config.GetAmqpConfirmTimeoutValue())
+	if err != nil {
+		aLogger.WithFields(log.Fields{"error": err, "event": d.RedactEventForLog(e), "destination": d}).Error("Failed to send event with AMQP")
+		return &captin_errors.DispatcherError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	return nil
+}
+
+// Close - Close channels and connection, publishes waiting for confirm are failed
+func (s *AmqpSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, c := range s.channels {
+		if c != nil {
+			c.channel.Close()
+		}
+	}
+	s.channels = nil
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// newAmqpPublishing - Routing key and message of event with headers of hook
+func newAmqpPublishing(payload []byte, e models.IncomingEvent, d models.Destination) (string, amqp.Publishing, error) {
+	data := e.TemplateData(d.Config.GetName())
+	routingKey, err := helpers.RenderTemplate(d.Config.GetAmqpRoutingKey(), data)
+	if err != nil {
+		return "", amqp.Publishing{}, fmt.Errorf("unable to render amqp routing key: %s", err)
+	}
+
+	headers := amqp.Table{}
+	for key, value := range eventMessageAttributes(e) {
+		headers[key] = value
+	}
+	for name, value := range d.Config.GetAmqpHeaders() {
+		rendered, err := helpers.RenderTemplate(value, data)
+		if err != nil {
+			return "", amqp.Publishing{}, fmt.Errorf("unable to render amqp header %s: %s", name, err)
+		}
+		headers[name] = rendered
+	}
+
+	deliveryMode := amqp.Persistent
+	if d.Config.GetAmqpDeliveryMode() == models.AmqpDeliveryTransient {
+		deliveryMode = amqp.Transient
+	}
+
+	return routingKey, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  "application/json",
+		DeliveryMode: deliveryMode,
+		MessageId:    e.TraceId,
+		Timestamp:    time.Now(),
+		Body:         payload,
+	}, nil
+}
+
+func (s *AmqpSender) publish(ctx context.Context, exchange string, routingKey string, msg amqp.Publishing, timeout time.Duration) error {
+	c, err := s.getChannel()
+	if err != nil {
+		return err
+	}
+	result, err := c.publish(exchange, routingKey, msg)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err = <-result:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("amqp publish is not confirmed within %s", timeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getChannel - Pick channel of pool in turn, closed channels and connection are recreated
+func (s *AmqpSender) getChannel() (*amqpConfirmChannel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errAmqpSenderClosed
+	}
+
+	size := s.PoolSize
+	if size <= 0 {
+		size = DefaultAmqpPoolSize
+	}
+	if len(s.channels) != size {
+		s.channels = make([]*amqpConfirmChannel, size)
+	}
+	i := s.next % size
+	s.next = i + 1
+	if c := s.channels[i]; c != nil && !c.isClosed() {
+		return c, nil
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	c, err := newAmqpConfirmChannel(conn)
+	if err != nil {
+		// Channel could not be opened on a broken connection, dial again on next send
+		conn.Close()
+		s.conn = nil
+		return nil, err
+	}
+	s.channels[i] = c
+	return c, nil
+}
+
+// connect - Get connection, dial if it is not established or closed, lock should be held by caller
+func (s *AmqpSender) connect() (AmqpConnection, error) {
+	if s.conn != nil {
+		return s.conn, nil
+	}
+
+	dial := s.Dial
+	if dial == nil {
+		dial = DialAmqp
+	}
+	conn, err := dial(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+
+	closes := conn.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		if err, ok := <-closes; ok {
+			aLogger.WithFields(log.Fields{"error": err}).Warn("AMQP connection closed, reconnect on next send")
+		}
+		s.mu.Lock()
+		if s.conn == conn {
+			s.conn = nil
+		}
+		s.mu.Unlock()
+	}()
+	return conn, nil
+}
+
+// amqpConfirmChannel - Channel in confirm mode, confirms are matched to publishes by delivery tag
+// Returns carry no delivery tag, they are matched to pending publishes by message instead. Publishes of equal message
+// are routed alike, so any of them could take the return.
+type amqpConfirmChannel struct {
+	channel AmqpChannel
+	closed  atomic.Bool
+
+	// publishMu - Serializes publishes, so that delivery tags follow the order of messages sent
+	publishMu sync.Mutex
+	tag       uint64
+
+	// mu - Guards pending publishes, it is never held during network calls
+	mu      sync.Mutex
+	pending map[uint64]*amqpPendingPublish
+}
+
+// amqpPendingPublish - Publish waiting for confirm, returned is set when broker returns its message
+type amqpPendingPublish struct {
+	result     chan error
+	exchange   string
+	routingKey string
+	msg        amqp.Publishing
+	returned   error
+}
+
+func newAmqpConfirmChannel(conn AmqpConnection) (*amqpConfirmChannel, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return nil, err
+	}
+	c := &amqpConfirmChannel{channel: channel, pending: map[uint64]*amqpPendingPublish{}}
+	returns := channel.NotifyReturn(make(chan amqp.Return, amqpNotifyBuffer))
+	confirms := channel.NotifyPublish(make(chan amqp.Confirmation, amqpNotifyBuffer))
+	go c.watch(confirms, returns)
+	return c, nil
+}
+
+// publish - Publish message, the returned channel receives result of confirm
+func (c *amqpConfirmChannel) publish(exchange string, routingKey string, msg amqp.Publishing) (chan error, error) {
+	c.publishMu.Lock()
+	defer c.publishMu.Unlock()
+
+	// Tag is reserved before publishing, as confirm could arrive before Publish returns
+	// Delivery tags count publishes from 1 since channel is put in confirm mode
+	tag := c.tag + 1
+	result := make(chan error, 1)
+	c.mu.Lock()
+	if c.closed.Load() {
+		c.mu.Unlock()
+		return nil, errAmqpChannelClosed
+	}
+	c.pending[tag] = &amqpPendingPublish{result: result, exchange: exchange, routingKey: routingKey, msg: msg}
+	c.mu.Unlock()
+
+	if err := c.channel.Publish(exchange, routingKey, true, false, msg); err != nil {
+		// Message is not sent, so the tag is not taken by broker
+		c.mu.Lock()
+		delete(c.pending, tag)
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.tag = tag
+	return result, nil
+}
+
+func (c *amqpConfirmChannel) isClosed() bool {
+	return c.closed.Load()
+}
+
+// watch - Deliver confirms to pending publishes, until channel is closed
+func (c *amqpConfirmChannel) watch(confirms chan amqp.Confirmation, returns chan amqp.Return) {
+	for confirms != nil {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			c.markReturned(ret)
+		case confirm, ok := <-confirms:
+			if !ok {
+				confirms = nil
+				continue
+			}
+			// Return is sent before confirm of its publish, it is in buffer already if the confirm is picked first
+			for drained := false; !drained && returns != nil; {
+				select {
+				case ret, ok := <-returns:
+					if !ok {
+						returns = nil
+					} else {
+						c.markReturned(ret)
+					}
+				default:
+					drained = true
+				}
+			}
+			c.mu.Lock()
+			pending, ok := c.pending[confirm.DeliveryTag]
+			delete(c.pending, confirm.DeliveryTag)
+			c.mu.Unlock()
+			if !ok {
+				continue
+			}
+			if !confirm.Ack {
+				pending.result <- errAmqpNack
+			} else {
+				pending.result <- pending.returned
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed.Store(true)
+	for tag, pending := range c.pending {
+		pending.result <- errAmqpChannelClosed
+		delete(c.pending, tag)
+	}
+}
+
+// markReturned - Fail the earliest pending publish of returned message on its confirm
+func (c *amqpConfirmChannel) markReturned(ret amqp.Return) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var matched *amqpPendingPublish
+	var matchedTag uint64
+	for tag, pending := range c.pending {
+		if pending.returned == nil && (matched == nil || tag < matchedTag) && pending.isReturnOf(ret) {
+			matched, matchedTag = pending, tag
+		}
+	}
+	if matched == nil {
+		aLogger.WithFields(log.Fields{"exchange": ret.Exchange, "routing_key": ret.RoutingKey}).Warn("Returned AMQP message does not match any pending publish")
+		return
+	}
+	matched.returned = amqpReturnedError{code: ret.ReplyCode, text: ret.ReplyText}
+}
+
+func (p *amqpPendingPublish) isReturnOf(ret amqp.Return) bool {
+	return p.exchange == ret.Exchange &&
+		p.routingKey == ret.RoutingKey &&
+		p.msg.MessageId == ret.MessageId &&
+		bytes.Equal(p.msg.Body, ret.Body)
+}
@@ -0,0 +1,191 @@
+package senders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var chatLogger = log.WithFields(log.Fields{"class": "ChatSender"})
+
+var _ interfaces.EventSenderInterface = &ChatSender{}
+
+// Rate limits of chat webhooks
+const (
+	// ChatDefaultRetryAfter - Pause of webhook responding 429 without Retry-After
+	ChatDefaultRetryAfter = 30 * time.Second
+	// ChatMaxWait - Longest wait for a slot of webhook, events beyond it are left to retry of dispatcher
+	ChatMaxWait = 30 * time.Second
+)
+
+// ChatSender - Post human-readable message of Event to incoming webhook of chat service in callback_url
+// Text is rendered from chat_template of hook, and posted in payload of chat_format, e.g. Slack or Teams.
+// Messages to the same webhook are spaced by chat_min_interval, and the webhook is paused for Retry-After
+// of 429 responses, so that events are retried by dispatcher instead of hitting the rate limit again.
+type ChatSender struct {
+	// Clients - Pool of HTTP clients, shared pool of external destinations if nil
+	Clients *HTTPClientPool
+
+	mu       sync.Mutex
+	webhooks map[string]*chatWebhook
+}
+
+// chatWebhook - Rate limit state of webhook
+type chatWebhook struct {
+	// next - Earliest time of next message
+	next time.Time
+	// pausedUntil - Messages are not posted until then, after webhook responded 429
+	pausedUntil time.Time
+}
+
+// SendEvent - Render chat message of event and post it to webhook
+func (s *ChatSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
+	ctx, span := helpers.Tracer().Start(ctx, "captin.ChatSender.SendEvent")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	e := ev.(models.IncomingEvent)
+	d := dv.(models.Destination)
+
+	text, err := helpers.RenderTemplate(d.Config.GetChatTemplate(), e.TemplateData(d.Config.GetName()))
+	if err != nil {
+		chatLogger.WithFields(log.Fields{"error": err}).Error("Failed to render chat template")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	format := d.Config.GetChatFormat()
+	body, err := json.Marshal(newChatPayload(format, e.Key, text))
+	if err != nil {
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	span.SetAttributes(attribute.String("chat.format", format))
+
+	clients := s.Clients
+	if clients == nil {
+		clients = httpExternalClientPool
+	}
+	client, err := clients.Client(d)
+	if err != nil {
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	webhook := d.GetCallbackURL()
+	if err := s.wait(ctx, webhook, d.Config.GetChatMinIntervalValue()); err != nil {
+		chatLogger.WithFields(log.Fields{"error": err, "hook": d.Config.GetName()}).Warn("Chat webhook is rate limited")
+		return &captin_errors.DispatcherError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := decorateHTTPRequest(ctx, req, body, e, d, client); err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return &captin_errors.DispatcherError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		s.pause(webhook, parseRetryAfter(res.Header.Get("Retry-After"), time.Now()))
+	}
+	result, err := readHTTPResponse(res, e, d)
+	chatLogger.WithFields(log.Fields{"status": res.StatusCode, "result": string(result)}).Debug("Send chat message with result")
+	return err
+}
+
+// newChatPayload - Payload of incoming webhook in format
+func newChatPayload(format string, title string, text string) interface{} {
+	if format == models.ChatFormatTeams {
+		return map[string]interface{}{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  title,
+			"text":     text,
+		}
+	}
+	return map[string]interface{}{"text": text, "mrkdwn": true}
+}
+
+// wait - Wait for slot of webhook, fails if webhook is paused or the slot is too far away
+func (s *ChatSender) wait(ctx context.Context, webhook string, interval time.Duration) error {
+	now := time.Now()
+	s.mu.Lock()
+	if s.webhooks == nil {
+		s.webhooks = map[string]*chatWebhook{}
+	}
+	w, ok := s.webhooks[webhook]
+	if !ok {
+		w = &chatWebhook{}
+		s.webhooks[webhook] = w
+	}
+	if now.Before(w.pausedUntil) {
+		pausedUntil := w.pausedUntil
+		s.mu.Unlock()
+		return fmt.Errorf("chat webhook is paused until %s", pausedUntil.Format(time.RFC3339))
+	}
+	slot := w.next
+	if slot.Before(now) {
+		slot = now
+	}
+	delay := slot.Sub(now)
+	if delay > ChatMaxWait {
+		s.mu.Unlock()
+		return fmt.Errorf("chat webhook has no slot within %s", ChatMaxWait)
+	}
+	w.next = slot.Add(interval)
+	s.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pause - Stop posting to webhook until time
+func (s *ChatSender) pause(webhook string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok := s.webhooks[webhook]; ok && until.After(w.pausedUntil) {
+		w.pausedUntil = until
+	}
+}
+
+// parseRetryAfter - Time of Retry-After header in seconds or HTTP date, ChatDefaultRetryAfter from now if missing or invalid
+func parseRetryAfter(value string, now time.Time) time.Time {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date
+	}
+	return now.Add(ChatDefaultRetryAfter)
+}
@@ -24,7 +24,7 @@ func (c *ConsoleEventSender) SendEvent(ctx context.Context, ev interfaces.Incomi
 		"config_name":     d.Config.GetName(),
 		"target_id":       e.TargetId,
 		"target_type":     e.TargetType,
-		"target_document": e.TargetDocument,
+		"target_document": d.RedactForLog(e.TargetDocument),
 	}).Debug("Event sent")
 	return nil
 }
@@ -0,0 +1,217 @@
+package senders
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"sync"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	"github.com/shoplineapp/captin/v2/pkg/eventpb"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	otel_codes "go.opentelemetry.io/otel/codes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var gLogger = log.WithFields(log.Fields{"class": "GRPCSender"})
+
+var _ interfaces.EventSenderInterface = &GRPCSender{}
+var _ interfaces.EventSenderCloserInterface = &GRPCSender{}
+
+// GRPCSender - Send Event as eventpb.Event to ReceiveEvent of target in callback_url, e.g. "dns:///orders:50051"
+// Connections are cached per target and kept open until Close.
+type GRPCSender struct {
+	// DialOptions - Extra options of connections, e.g. interceptors
+	DialOptions []grpc.DialOption
+
+	mu    sync.Mutex
+	conns map[grpcConnKey]*grpc.ClientConn
+}
+
+type grpcConnKey struct {
+	target    string
+	plaintext bool
+}
+
+func NewGRPCSender(options ...grpc.DialOption) *GRPCSender {
+	return &GRPCSender{DialOptions: options, conns: map[grpcConnKey]*grpc.ClientConn{}}
+}
+
+// SendEvent - Call ReceiveEvent of target with deadline of hook
+func (s *GRPCSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
+	ctx, span := helpers.Tracer().Start(ctx, "captin.GRPCSender.SendEvent")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otel_codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	e := ev.(models.IncomingEvent)
+	d := dv.(models.Destination)
+
+	target := d.GetCallbackURL()
+	span.SetAttributes(attribute.String("target", target))
+	gLogger.WithFields(log.Fields{"target": target}).Debug("Send grpc event")
+
+	// Trace context is sent in envelope and metadata, e.g. traceparent
+	e.DistributedTracingInfo.InjectContext(ctx)
+	event, err := newGRPCEvent(e, d.Config.GetName())
+	if err != nil {
+		gLogger.WithFields(log.Fields{"error": err}).Error("Failed to convert incoming event to protobuf envelope")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	conn, err := s.getConn(target, d.Config.GetGRPCPlaintext())
+	if err != nil {
+		gLogger.WithFields(log.Fields{"error": err, "target": target}).Error("Failed to dial grpc target")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	for _, key := range e.DistributedTracingInfo.Keys() {
+		ctx = metadata.AppendToOutgoingContext(ctx, key, e.DistributedTracingInfo.Get(key))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.Config.GetGRPCTimeoutValue())
+	defer cancel()
+	_, err = eventpb.NewEventReceiverClient(conn).ReceiveEvent(ctx, event)
+	if err != nil {
+		gLogger.WithFields(log.Fields{"error": err, "event": d.RedactEventForLog(e), "destination": d}).Error("Failed to send event with gRPC")
+		if isGRPCRetryable(status.Code(err)) {
+			return &captin_errors.DispatcherError{Msg: err.Error(), Event: e, Destination: d}
+		}
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	return nil
+}
+
+// Close - Close cached connections
+func (s *GRPCSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var closeErr error
+	for key, conn := range s.conns {
+		if err := conn.Close(); err != nil {
+			closeErr = err
+		}
+		delete(s.conns, key)
+	}
+	return closeErr
+}
+
+// getConn - Get cached connection of target, connection is established in background by grpc
+func (s *GRPCSender) getConn(target string, plaintext bool) (*grpc.ClientConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := grpcConnKey{target: target, plaintext: plaintext}
+	if conn, ok := s.conns[key]; ok {
+		return conn, nil
+	}
+
+	options := append([]grpc.DialOption{}, s.DialOptions...)
+	if plaintext {
+		options = append(options, grpc.WithInsecure())
+	} else {
+		options = append(options, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+	}
+	conn, err := grpc.Dial(target, options...)
+	if err != nil {
+		return nil, err
+	}
+	if s.conns == nil {
+		s.conns = map[grpcConnKey]*grpc.ClientConn{}
+	}
+	s.conns[key] = conn
+	return conn, nil
+}
+
+// isGRPCRetryable - Check if call failed by transient errors, other codes are rejections of the event itself
+func isGRPCRetryable(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unknown, codes.Canceled:
+		return true
+	default:
+		return false
+	}
+}
+
+func newGRPCEvent(e models.IncomingEvent, hook string) (*eventpb.Event, error) {
+	event := &eventpb.Event{
+		TraceId:    e.TraceId,
+		EventKey:   e.Key,
+		Source:     e.Source,
+		TargetType: e.TargetType,
+		TargetId:   e.TargetId,
+		Hook:       hook,
+	}
+	var err error
+	if event.Payload, err = grpcStruct(e.Payload); err != nil {
+		return nil, err
+	}
+	if event.Control, err = grpcStruct(e.Control); err != nil {
+		return nil, err
+	}
+	if event.TargetDocument, err = grpcStruct(e.TargetDocument); err != nil {
+		return nil, err
+	}
+	if event.ThrottledPayloads, err = grpcStructs(e.ThrottledPayloads); err != nil {
+		return nil, err
+	}
+	if event.ThrottledDocuments, err = grpcStructs(e.ThrottledDocuments); err != nil {
+		return nil, err
+	}
+	if keys := e.DistributedTracingInfo.Keys(); len(keys) > 0 {
+		event.DistributedTracingInfo = make(map[string]string, len(keys))
+		for _, key := range keys {
+			event.DistributedTracingInfo[key] = e.DistributedTracingInfo.Get(key)
+		}
+	}
+	return event, nil
+}
+
+func grpcStructs(values []map[string]interface{}) ([]*structpb.Struct, error) {
+	if values == nil {
+		return nil, nil
+	}
+	result := make([]*structpb.Struct, len(values))
+	for i, value := range values {
+		converted, err := grpcStruct(value)
+		if err != nil {
+			return nil, err
+		}
+		// Lists could not hold nil messages
+		if converted == nil {
+			converted = &structpb.Struct{}
+		}
+		result[i] = converted
+	}
+	return result, nil
+}
+
+// grpcStruct - Convert map through json, so that values like time and object ID are encoded as in json body
+func grpcStruct(value map[string]interface{}) (*structpb.Struct, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	result := &structpb.Struct{}
+	if err := protojson.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
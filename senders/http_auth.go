@@ -0,0 +1,149 @@
+package senders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+)
+
+// Tokens are refreshed when they expire within the margin, so that they do not expire in flight
+const oauth2RefreshMargin = 30 * time.Second
+
+// oauth2Token - Cached access token of OAuth2 client credentials grant
+type oauth2Token struct {
+	accessToken string
+	expiresAt   time.Time
+}
+
+// oauth2TokenEntry - Token of a client, lock is held while requesting, so that concurrent events
+// of the same client wait for one request instead of requesting tokens at the same time
+type oauth2TokenEntry struct {
+	sync.Mutex
+	token oauth2Token
+}
+
+// OAuth2 tokens shared across events, keyed by token URL, client ID and scopes
+// Lock of map is only held for finding entries, so that slow token endpoints do not block other clients.
+var oauth2Tokens = struct {
+	sync.Mutex
+	tokens map[string]*oauth2TokenEntry
+}{tokens: map[string]*oauth2TokenEntry{}}
+
+// authorizeHTTPRequest - Set credentials of destination on request, secrets are never logged
+func authorizeHTTPRequest(ctx context.Context, req *http.Request, e models.IncomingEvent, d models.Destination, client *http.Client) error {
+	strategy := d.Config.GetHTTPAuth()
+	if strategy == "" {
+		return nil
+	}
+	secret, err := helpers.ResolveSecret(d.Config.GetHTTPAuthSecret())
+	if err != nil {
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	switch strategy {
+	case models.HTTPAuthAPIKey:
+		req.Header.Set(d.Config.GetHTTPAuthHeader(), secret)
+	case models.HTTPAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+secret)
+	case models.HTTPAuthBasic:
+		req.SetBasicAuth(d.Config.GetHTTPAuthUsername(), secret)
+	case models.HTTPAuthOAuth2:
+		token, err := oauth2AccessToken(ctx, d, secret, client)
+		if err != nil {
+			// Token endpoint could recover, keep the error retryable
+			return &captin_errors.DispatcherError{Msg: err.Error(), Event: e, Destination: d}
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	default:
+		return &captin_errors.UnretryableError{Msg: fmt.Sprintf("unknown http auth strategy %s", strategy), Event: e, Destination: d}
+	}
+	return nil
+}
+
+// forgetOAuth2Token - Drop cached token, e.g. destination responded 401 as token was revoked
+func forgetOAuth2Token(d models.Destination) {
+	if d.Config.GetHTTPAuth() != models.HTTPAuthOAuth2 {
+		return
+	}
+	entry := oauth2TokenEntryOf(oauth2TokenKey(d))
+	entry.Lock()
+	defer entry.Unlock()
+	entry.token = oauth2Token{}
+}
+
+func oauth2TokenEntryOf(key string) *oauth2TokenEntry {
+	oauth2Tokens.Lock()
+	defer oauth2Tokens.Unlock()
+	entry, ok := oauth2Tokens.tokens[key]
+	if !ok {
+		entry = &oauth2TokenEntry{}
+		oauth2Tokens.tokens[key] = entry
+	}
+	return entry
+}
+
+func oauth2TokenKey(d models.Destination) string {
+	return strings.Join([]string{d.Config.GetHTTPOAuth2TokenURL(), d.Config.GetHTTPOAuth2ClientID(), strings.Join(d.Config.GetHTTPOAuth2Scopes(), " ")}, "|")
+}
+
+func oauth2AccessToken(ctx context.Context, d models.Destination, clientSecret string, client *http.Client) (string, error) {
+	entry := oauth2TokenEntryOf(oauth2TokenKey(d))
+	entry.Lock()
+	defer entry.Unlock()
+	if time.Until(entry.token.expiresAt) > oauth2RefreshMargin {
+		return entry.token.accessToken, nil
+	}
+
+	token, err := requestOAuth2Token(ctx, d, clientSecret, client)
+	if err != nil {
+		return "", err
+	}
+	entry.token = token
+	return token.accessToken, nil
+}
+
+func requestOAuth2Token(ctx context.Context, d models.Destination, clientSecret string, client *http.Client) (oauth2Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if scopes := d.Config.GetHTTPOAuth2Scopes(); len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.Config.GetHTTPOAuth2TokenURL(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return oauth2Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(d.Config.GetHTTPOAuth2ClientID()), url.QueryEscape(clientSecret))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return oauth2Token{}, fmt.Errorf("unable to request oauth2 token: %s", err)
+	}
+	defer res.Body.Close()
+	body := readHTTPBody(res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return oauth2Token{}, fmt.Errorf("oauth2 token endpoint responded with status %d", res.StatusCode)
+	}
+
+	result := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(body, &result); err != nil || result.AccessToken == "" {
+		return oauth2Token{}, fmt.Errorf("invalid oauth2 token response")
+	}
+	token := oauth2Token{accessToken: result.AccessToken, expiresAt: time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)}
+	if result.ExpiresIn <= 0 {
+		// Token without expiry is kept for an hour
+		token.expiresAt = time.Now().Add(time.Hour)
+	}
+	return token, nil
+}
@@ -0,0 +1,200 @@
+package senders
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	statsd "github.com/joeycumines/statsd"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+)
+
+var hcpLogger = log.WithFields(log.Fields{"class": "HTTPClientPool"})
+
+// Idle connections are closed after timeout, so that connections of replaced clients are not kept forever
+const httpIdleConnTimeout = 90 * time.Second
+
+// HTTPClientPool - HTTP clients shared across events, one per destination
+// Clients are keyed by hook name, a changed configuration of hook replaces its client and
+// idle connections of the replaced one are closed.
+type HTTPClientPool struct {
+	// wrap the transport of clients, e.g. for tracing
+	wrap    func(http.RoundTripper) http.RoundTripper
+	mu      sync.Mutex
+	clients map[string]*httpPoolEntry
+	// statsd client reporting expiry of client certificates, guarded by its own lock as certificates are reloaded on handshakes
+	statsdMu     sync.RWMutex
+	statsdClient *statsd.Client
+}
+
+// httpPoolEntry - Client of hook created with HTTP settings of settings key
+type httpPoolEntry struct {
+	settings  string
+	client    *http.Client
+	transport *http.Transport
+	// client certificate of destination with mutual TLS, nil if not configured
+	certificate *clientCertificate
+}
+
+// NewHTTPClientPool - Create client pool with transport wrapper, nil for using transport as is
+func NewHTTPClientPool(wrap func(http.RoundTripper) http.RoundTripper) *HTTPClientPool {
+	return &HTTPClientPool{wrap: wrap, clients: map[string]*httpPoolEntry{}}
+}
+
+// Client - Get client of destination, created on first use and on change of HTTP settings of hook
+func (p *HTTPClientPool) Client(d models.Destination) (*http.Client, error) {
+	hook := d.Config.GetName()
+	settings := httpClientKey(d)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	previous, ok := p.clients[hook]
+	if ok && previous.settings == settings {
+		return previous.client, nil
+	}
+
+	transport, certificate, err := newHTTPTransport(d, func(notAfter time.Time) {
+		p.reportCertificateExpiry(hook, notAfter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var roundTripper http.RoundTripper = transport
+	if p.wrap != nil {
+		roundTripper = p.wrap(transport)
+	}
+	client := &http.Client{
+		Transport: roundTripper,
+		Timeout:   d.Config.GetHTTPTimeoutValue(),
+	}
+	p.clients[hook] = &httpPoolEntry{settings: settings, client: client, transport: transport, certificate: certificate}
+	if ok {
+		// Requests in flight complete on replaced client, their connections expire by idle timeout afterwards
+		previous.transport.CloseIdleConnections()
+		hcpLogger.WithFields(log.Fields{"hook_name": hook}).Debug("Replaced http client of changed configuration")
+	} else {
+		hcpLogger.WithFields(log.Fields{"hook_name": hook}).Debug("Created http client")
+	}
+	return client, nil
+}
+
+// SetStatsdClient - Report expiry of client certificates to statsd when they are loaded or reloaded
+// Certificates loaded already are reported when the client is changed.
+func (p *HTTPClientPool) SetStatsdClient(client *statsd.Client) {
+	p.statsdMu.Lock()
+	changed := p.statsdClient != client
+	p.statsdClient = client
+	p.statsdMu.Unlock()
+	if !changed {
+		return
+	}
+
+	p.mu.Lock()
+	certificates := []*clientCertificate{}
+	for _, entry := range p.clients {
+		if entry.certificate != nil {
+			certificates = append(certificates, entry.certificate)
+		}
+	}
+	p.mu.Unlock()
+	for _, certificate := range certificates {
+		certificate.report()
+	}
+}
+
+func (p *HTTPClientPool) reportCertificateExpiry(hook string, notAfter time.Time) {
+	p.statsdMu.RLock()
+	client := p.statsdClient
+	p.statsdMu.RUnlock()
+	if client == nil {
+		return
+	}
+	client.Gauge(fmt.Sprintf("hook.sender.http.certificate_expiry_seconds,metricname=%s,hook=%s", hook, hook), int64(time.Until(notAfter).Seconds()))
+}
+
+func httpClientKey(d models.Destination) string {
+	c := d.Config
+	return fmt.Sprintf(
+		"%s|%s|%s|%s|%s|%d|%d|%t|%s|%t|%s|%s|%s|%s",
+		c.GetName(),
+		c.GetHTTPTimeoutValue(),
+		c.GetHTTPConnectTimeoutValue(),
+		c.GetHTTPTLSTimeoutValue(),
+		c.GetHTTPKeepAliveValue(),
+		c.GetHTTPMaxIdleConns(),
+		c.GetHTTPMaxIdleConnsPerHost(),
+		c.GetHTTPInsecureSkipVerify(),
+		c.GetHTTPCABundle(),
+		c.GetHTTPEnableHTTP2(),
+		c.GetHTTPTLSCert(),
+		c.GetHTTPTLSKey(),
+		c.GetHTTPTLSServerName(),
+		strings.Join(c.GetHTTPTLSPins(), ","),
+	)
+}
+
+// newHTTPTransport - Create transport of destination, onLoad is called with expiry of client certificate on load and reload
+func newHTTPTransport(d models.Destination, onLoad func(notAfter time.Time)) (*http.Transport, *clientCertificate, error) {
+	c := d.Config
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.GetHTTPInsecureSkipVerify(),
+		ServerName:         c.GetHTTPTLSServerName(),
+	}
+	if path := c.GetHTTPCABundle(); path != "" {
+		pool, err := loadCABundle(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if pins := c.GetHTTPTLSPins(); len(pins) > 0 {
+		tlsConfig.VerifyConnection = verifyCertificatePins(pins)
+	}
+	var certificate *clientCertificate
+	if c.GetHTTPTLSCert() != "" {
+		var err error
+		if certificate, err = newClientCertificate(c.GetHTTPTLSCert(), c.GetHTTPTLSKey(), onLoad); err != nil {
+			return nil, nil, err
+		}
+		tlsConfig.GetClientCertificate = certificate.getClientCertificate
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   c.GetHTTPConnectTimeoutValue(),
+		KeepAlive: c.GetHTTPKeepAliveValue(),
+	}
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: c.GetHTTPTLSTimeoutValue(),
+		IdleConnTimeout:     httpIdleConnTimeout,
+		MaxIdleConns:        c.GetHTTPMaxIdleConns(),
+		MaxIdleConnsPerHost: c.GetHTTPMaxIdleConnsPerHost(),
+		// HTTP/2 is not attempted with custom TLS config unless forced
+		ForceAttemptHTTP2: c.GetHTTPEnableHTTP2(),
+	}, certificate, nil
+}
+
+// loadCABundle - Load CA certificates from PEM file in addition to system ones
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA bundle %s: %s", path, err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in CA bundle %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,159 @@
+package senders
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	models "github.com/shoplineapp/captin/v2/models"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var httpContentTypes = map[string]string{
+	models.HTTPEncodingJSON:    "application/json",
+	models.HTTPEncodingForm:    "application/x-www-form-urlencoded",
+	models.HTTPEncodingXML:     "application/xml",
+	models.HTTPEncodingMsgpack: "application/msgpack",
+}
+
+// encodeHTTPBody - Encode body in given encoding, returns encoded body and its content type
+// Body is JSON encoded in advance, other encodings convert it back to plain maps and slices.
+func encodeHTTPBody(body []byte, encoding string, root string) ([]byte, string, error) {
+	contentType, ok := httpContentTypes[encoding]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported http encoding %s", encoding)
+	}
+	if encoding == models.HTTPEncodingJSON {
+		return body, contentType, nil
+	}
+
+	// Keep numbers as they are, e.g. large integers are not formatted in exponent
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, "", err
+	}
+	switch encoding {
+	case models.HTTPEncodingForm:
+		return []byte(encodeHTTPForm(value).Encode()), contentType, nil
+	case models.HTTPEncodingXML:
+		buf := bytes.NewBufferString(xml.Header)
+		if err := writeXMLElement(buf, root, value); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), contentType, nil
+	default:
+		encoded, err := msgpack.Marshal(msgpackValue(value))
+		return encoded, contentType, err
+	}
+}
+
+// msgpackValue - Convert JSON numbers to integers or floats, so that they are not encoded as strings
+func msgpackValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = msgpackValue(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = msgpackValue(child)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+// encodeHTTPForm - Flatten value into form values with bracket notation, e.g. "payload[items][0][sku]"
+func encodeHTTPForm(value interface{}) url.Values {
+	values := url.Values{}
+	flattenHTTPForm(values, "", value)
+	return values
+}
+
+func flattenHTTPForm(values url.Values, prefix string, value interface{}) {
+	nestedKey := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "[" + key + "]"
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flattenHTTPForm(values, nestedKey(key), child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenHTTPForm(values, nestedKey(strconv.Itoa(i)), child)
+		}
+	case nil:
+		values.Set(prefix, "")
+	default:
+		values.Set(prefix, fmt.Sprint(v))
+	}
+}
+
+// writeXMLElement - Write value as XML element, map keys in sorted order and array elements as repeated "item" elements
+func writeXMLElement(buf *bytes.Buffer, name string, value interface{}) error {
+	name = xmlElementName(name)
+	buf.WriteString("<" + name + ">")
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := writeXMLElement(buf, key, v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if err := writeXMLElement(buf, "item", child); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := xml.EscapeText(buf, []byte(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("</" + name + ">")
+	return nil
+}
+
+// xmlElementName - Replace characters not allowed in XML names with underscores, e.g. "1st item" => "_1st_item"
+func xmlElementName(key string) string {
+	var name strings.Builder
+	for i, r := range key {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		case i == 0 && unicode.IsDigit(r):
+			name.WriteRune('_')
+		default:
+			r = '_'
+		}
+		name.WriteRune(r)
+	}
+	if name.Len() == 0 {
+		return "_"
+	}
+	return name.String()
+}
@@ -0,0 +1,42 @@
+package senders
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+)
+
+// decorateHTTPRequest - Set headers, credentials and signature of destination on request
+func decorateHTTPRequest(ctx context.Context, req *http.Request, body []byte, e models.IncomingEvent, d models.Destination, client *http.Client) error {
+	if err := setHTTPHeaders(req, e, d); err != nil {
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	if err := authorizeHTTPRequest(ctx, req, e, d, client); err != nil {
+		return err
+	}
+	if err := signHTTPRequest(req, body, e, d); err != nil {
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	return nil
+}
+
+// setHTTPHeaders - Set extra headers of destination, values are rendered with event
+func setHTTPHeaders(req *http.Request, e models.IncomingEvent, d models.Destination) error {
+	headers := d.Config.GetHTTPHeaders()
+	if len(headers) == 0 {
+		return nil
+	}
+	data := e.TemplateData(d.Config.GetName())
+	for name, value := range headers {
+		rendered, err := helpers.RenderTemplate(value, data)
+		if err != nil {
+			return fmt.Errorf("unable to render http header %s: %s", name, err)
+		}
+		req.Header.Set(name, rendered)
+	}
+	return nil
+}
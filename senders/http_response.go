@@ -0,0 +1,88 @@
+package senders
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+)
+
+// Maximum length of response body kept on delivery errors
+const httpErrorBodyLimit = 1024
+
+// Maximum length of response body read into memory
+const httpResponseBodyLimit = 64 * 1024
+
+// Maximum length of response body discarded after the read part, so that connection is reused
+// Connection of longer response is closed instead of reading it to the end.
+const httpResponseDrainLimit = 256 * 1024
+
+// Response headers kept on delivery errors
+var httpErrorHeaders = []string{"Retry-After", "Content-Type", "X-Request-Id"}
+
+// isHTTPSuccess - Check if status code counts as successful delivery, any 2xx unless overridden by hook
+func isHTTPSuccess(statusCode int, d models.Destination) bool {
+	codes := d.Config.GetHTTPSuccessCodes()
+	if len(codes) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	for _, code := range codes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// isHTTPRetryable - Timeout, rate limit and server errors are worth retrying, other client errors are not
+func isHTTPRetryable(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// readHTTPResponse - Read response body, returns error classified by status code if delivery failed
+func readHTTPResponse(res *http.Response, e models.IncomingEvent, d models.Destination) ([]byte, error) {
+	body := readHTTPBody(res.Body)
+	if isHTTPSuccess(res.StatusCode, d) {
+		return body, nil
+	}
+	retryable := isHTTPRetryable(res.StatusCode)
+	if res.StatusCode == http.StatusUnauthorized && d.Config.GetHTTPAuth() == models.HTTPAuthOAuth2 {
+		// Cached token may have been revoked, request a new one on retry
+		forgetOAuth2Token(d)
+		retryable = true
+	}
+
+	response := &captin_errors.HTTPResponse{
+		StatusCode: res.StatusCode,
+		Headers:    map[string]string{},
+		Body:       truncateHTTPBody(body),
+	}
+	for _, header := range httpErrorHeaders {
+		if value := res.Header.Get(header); value != "" {
+			response.Headers[header] = value
+		}
+	}
+
+	msg := fmt.Sprintf("Destination responded with status %d: %s", res.StatusCode, response.Body)
+	if retryable {
+		return body, &captin_errors.DispatcherError{Msg: msg, Event: e, Destination: d, Response: response}
+	}
+	return body, &captin_errors.UnretryableError{Msg: msg, Event: e, Destination: d, Response: response}
+}
+
+// readHTTPBody - Read body up to httpResponseBodyLimit and discard the rest up to httpResponseDrainLimit
+func readHTTPBody(r io.Reader) []byte {
+	body, _ := ioutil.ReadAll(io.LimitReader(r, httpResponseBodyLimit))
+	io.CopyN(ioutil.Discard, r, httpResponseDrainLimit)
+	return body
+}
+
+func truncateHTTPBody(body []byte) string {
+	if len(body) > httpErrorBodyLimit {
+		return string(body[:httpErrorBodyLimit]) + "...(truncated)"
+	}
+	return string(body)
+}
@@ -3,24 +3,60 @@ package senders
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
-	"io/ioutil"
+	"encoding/json"
+	"io"
 	"net/http"
+	"net/url"
 
+	statsd "github.com/joeycumines/statsd"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
 	interfaces "github.com/shoplineapp/captin/v2/interfaces"
 	"github.com/shoplineapp/captin/v2/internal/helpers"
 	models "github.com/shoplineapp/captin/v2/models"
+	"github.com/shoplineapp/captin/v2/pkg/webhooks"
 	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var hLogger = log.WithFields(log.Fields{"class": "HttpEventSender"})
 
 var _ interfaces.EventSenderInterface = &HTTPEventSender{}
 
+// Clients shared by HTTP senders, trace context is propagated to destinations receiving full events
+var httpClientPool = NewHTTPClientPool(func(t http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(t)
+})
+
+// Clients shared by HTTP senders for destinations receiving payload only
+// For external receivers, we don't want to propagate the trace context, while we still want to trace the event of sending the request
+// Therefore we use a CompositeTextMapPropagator with no base propagators as a no-op propagator
+var httpExternalClientPool = NewHTTPClientPool(func(t http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(t, otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()))
+})
+
 // HTTPEventSender - Send Event through HTTP
-type HTTPEventSender struct{}
+// Method, encoding and body of requests are configured per hook, see http_method, http_encoding and http_body,
+// full event is sent as JSON POST by default.
+type HTTPEventSender struct {
+	// Clients - Pool of HTTP clients, shared default pool if nil
+	Clients *HTTPClientPool
+	// DefaultBody - Body source of hooks without http_body, full event if empty
+	DefaultBody string
+	// StatsdClient - Client reporting metrics of sending, e.g. expiry of client certificates
+	StatsdClient *statsd.Client
+}
+
+// httpSendOptions - Options of HTTP senders sharing sendHTTPEvent
+type httpSendOptions struct {
+	clients      *HTTPClientPool
+	defaultBody  string
+	statsdClient *statsd.Client
+	logger       *log.Entry
+}
 
 func (c *HTTPEventSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
 	ctx, span := helpers.Tracer().Start(ctx, "captin.HTTPEventSender.SendEvent")
@@ -31,29 +67,97 @@ func (c *HTTPEventSender) SendEvent(ctx context.Context, ev interfaces.IncomingE
 		}
 		span.End()
 	}()
+	options := httpSendOptions{clients: c.Clients, defaultBody: c.DefaultBody, statsdClient: c.StatsdClient, logger: hLogger}
+	return sendHTTPEvent(ctx, span, options, ev.(models.IncomingEvent), dv.(models.Destination))
+}
 
-	e := ev.(models.IncomingEvent)
-	d := dv.(models.Destination)
-
-	url := d.GetCallbackURL()
-	e.DistributedTracingInfo.InjectContext(ctx)
-	payload, err := e.ToJson()
+func sendHTTPEvent(ctx context.Context, span trace.Span, options httpSendOptions, e models.IncomingEvent, d models.Destination) error {
+	source := d.Config.GetHTTPBody()
+	if source == "" {
+		source = options.defaultBody
+	}
+	clients := options.clients
 
+	var root string
+	var body []byte
+	var err error
+	if source == models.HTTPBodyPayload {
+		// clear the distributed tracing context before sending the request to external receivers
+		e.DistributedTracingInfo.ClearContext()
+		root = "payload"
+		body, err = json.Marshal(e.Payload)
+		if clients == nil {
+			clients = httpExternalClientPool
+		}
+	} else {
+		e.DistributedTracingInfo.InjectContext(ctx)
+		root = "event"
+		body, err = e.ToJson()
+		if clients == nil {
+			clients = httpClientPool
+		}
+	}
 	if err != nil {
 		return err
 	}
 
-	req, reqErr := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payload))
+	method := d.Config.GetHTTPMethod()
+	encoding := d.Config.GetHTTPEncoding()
+	span.SetAttributes(
+		attribute.String("http.method", method),
+		attribute.String("http.encoding", encoding),
+		attribute.String("http.body", source),
+	)
+
+	// Expiry of client certificates is reported by pool on load and reload
+	if options.statsdClient != nil {
+		clients.SetStatsdClient(options.statsdClient)
+	}
+	client, err := clients.Client(d)
+	if err != nil {
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	callbackURL := d.GetCallbackURL()
+	var reqBody io.Reader
+	var contentType, contentEncoding string
+	if method == "GET" || method == "DELETE" {
+		// Requests without body carry event in query parameters
+		query, err := encodeHTTPQuery(callbackURL, body)
+		if err != nil {
+			return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+		}
+		callbackURL = query
+		body = nil
+	} else {
+		body, contentType, err = encodeHTTPBody(body, encoding, root)
+		if err != nil {
+			return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+		}
+		compressed, algorithm, err := d.Compress(body)
+		if err != nil {
+			return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+		}
+		contentEncoding = algorithm
+		reqBody = bytes.NewBuffer(compressed)
+	}
+
+	req, reqErr := http.NewRequestWithContext(ctx, method, callbackURL, reqBody)
 	if reqErr != nil {
 		return reqErr
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{
-		// for tracing
-		Transport: otelhttp.NewTransport(&http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}),
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	// Signature covers body before compression, or query parameters of requests without body
+	if reqBody == nil {
+		body = webhooks.CanonicalQuery(req.URL)
+	}
+	if err := decorateHTTPRequest(ctx, req, body, e, d, client); err != nil {
+		return err
 	}
 
 	res, resErr := client.Do(req)
@@ -62,8 +166,28 @@ func (c *HTTPEventSender) SendEvent(ctx context.Context, ev interfaces.IncomingE
 	}
 	defer res.Body.Close()
 
-	body, _ := ioutil.ReadAll(res.Body)
-	hLogger.WithFields(log.Fields{"result": string(body)}).Debug("Send http event with result")
+	result, err := readHTTPResponse(res, e, d)
+	options.logger.WithFields(log.Fields{"status": res.StatusCode, "result": string(result)}).Debug("Send http event with result")
+
+	return err
+}
 
-	return nil
+// encodeHTTPQuery - Append JSON encoded body to query parameters of URL
+func encodeHTTPQuery(callbackURL string, body []byte) (string, error) {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return "", err
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for key, values := range encodeHTTPForm(value) {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
 }
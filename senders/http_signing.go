@@ -0,0 +1,58 @@
+package senders
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	"github.com/shoplineapp/captin/v2/pkg/webhooks"
+)
+
+// signHTTPRequest - Sign request body with active secrets of destination, see pkg/webhooks for verification
+// Secrets are resolved on every request, so that rotated secrets take effect without restart.
+func signHTTPRequest(req *http.Request, body []byte, e models.IncomingEvent, d models.Destination) error {
+	refs := d.Config.GetSigningSecrets()
+	if len(refs) == 0 {
+		return nil
+	}
+	secrets := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		secret, err := helpers.ResolveSecret(ref)
+		if err != nil {
+			return err
+		}
+		secrets = append(secrets, secret)
+	}
+
+	if d.Config.GetSigningScheme() == models.SigningSchemeHMAC {
+		signatures := make([]string, len(secrets))
+		for i, secret := range secrets {
+			signatures[i] = webhooks.SignHMAC(secret, body)
+		}
+		req.Header.Set(d.Config.GetSigningHeader(), strings.Join(signatures, " "))
+		return nil
+	}
+
+	// Trace ID is kept on retries, so that receivers could deduplicate by webhook-id
+	id := e.TraceId
+	if id == "" {
+		id = uuid.New().String()
+	}
+	timestamp := time.Now()
+	signatures := make([]string, len(secrets))
+	for i, secret := range secrets {
+		signature, err := webhooks.Sign(secret, id, timestamp, body)
+		if err != nil {
+			return err
+		}
+		signatures[i] = signature
+	}
+	req.Header.Set(webhooks.HeaderID, id)
+	req.Header.Set(webhooks.HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(webhooks.HeaderSignature, strings.Join(signatures, " "))
+	return nil
+}
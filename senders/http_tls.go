@@ -0,0 +1,114 @@
+package senders
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	log "github.com/sirupsen/logrus"
+)
+
+// clientCertificate - Client certificate of destination for mutual TLS, reloaded when its files change
+type clientCertificate struct {
+	certRef  string
+	keyRef   string
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+	// onLoad - Called with expiry of certificate on load and reload, e.g. for reporting it
+	onLoad func(notAfter time.Time)
+}
+
+func newClientCertificate(certRef string, keyRef string, onLoad func(notAfter time.Time)) (*clientCertificate, error) {
+	c := &clientCertificate{certRef: certRef, keyRef: keyRef, onLoad: onLoad}
+	if _, err := c.get(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// get - Get certificate, files are checked on every call, i.e. on every TLS handshake
+func (c *clientCertificate) get() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modTimes := [2]time.Time{fileModTime(c.certRef), fileModTime(c.keyRef)}
+	if c.cert != nil && modTimes == c.modTimes {
+		return c.cert, nil
+	}
+
+	cert, err := helpers.LoadCertificate(c.certRef, c.keyRef)
+	if err != nil {
+		if c.cert != nil {
+			// Files could be in the middle of being replaced, keep using the loaded one
+			hcpLogger.WithFields(log.Fields{"error": err}).Warn("Unable to reload client certificate")
+			return c.cert, nil
+		}
+		return nil, err
+	}
+	if c.cert != nil {
+		hcpLogger.WithFields(log.Fields{"not_after": cert.Leaf.NotAfter}).Info("Reloaded client certificate")
+	}
+	c.cert = &cert
+	c.modTimes = modTimes
+	if c.onLoad != nil {
+		c.onLoad(cert.Leaf.NotAfter)
+	}
+	return c.cert, nil
+}
+
+func (c *clientCertificate) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return c.get()
+}
+
+// report - Call onLoad with expiry of loaded certificate again, e.g. after reporting is enabled
+func (c *clientCertificate) report() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cert != nil && c.onLoad != nil {
+		c.onLoad(c.cert.Leaf.NotAfter)
+	}
+}
+
+// fileModTime - Get modification time of certificate file, zero for secret references other than files
+func fileModTime(ref string) time.Time {
+	path := helpers.CertificateFilePath(ref)
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// verifyCertificatePins - Require any certificate in the chain of server to match a pinned public key
+func verifyCertificatePins(pins []string) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		certs := state.PeerCertificates
+		for _, chain := range state.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+		for _, cert := range certs {
+			if matchCertificatePin(cert, pins) {
+				return nil
+			}
+		}
+		return fmt.Errorf("no certificate of %s matches pinned public keys", state.ServerName)
+	}
+}
+
+func matchCertificatePin(cert *x509.Certificate, pins []string) bool {
+	pin := helpers.CertificatePin(cert)
+	for _, p := range pins {
+		if p == pin {
+			return true
+		}
+	}
+	return false
+}
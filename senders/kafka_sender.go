@@ -0,0 +1,255 @@
+package senders
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var kLogger = log.WithFields(log.Fields{"class": "KafkaSender"})
+
+var _ interfaces.EventSenderInterface = &KafkaSender{}
+var _ interfaces.EventSenderCloserInterface = &KafkaSender{}
+
+var errKafkaSenderClosed = errors.New("kafka sender is closed")
+
+// KafkaSender - Send Event as record of kafka topic
+// Records are produced asynchronously and batched by linger and batch size of hook,
+// SendEvent returns when the delivery report of its record arrives.
+type KafkaSender struct {
+	Brokers []string
+	// NewProducer - Create producer with config of hook, default to sarama.NewAsyncProducer
+	NewProducer func(brokers []string, config *sarama.Config) (sarama.AsyncProducer, error)
+
+	mu        sync.RWMutex
+	closed    bool
+	producers map[kafkaProducerKey]*kafkaProducer
+}
+
+// kafkaProducerKey - Producer settings of hook, hooks with the same settings share a producer
+type kafkaProducerKey struct {
+	acks       string
+	idempotent bool
+	linger     time.Duration
+	batchSize  int
+}
+
+type kafkaProducer struct {
+	producer sarama.AsyncProducer
+	done     chan struct{}
+}
+
+func NewKafkaSender(brokers []string) *KafkaSender {
+	return &KafkaSender{
+		Brokers:     brokers,
+		NewProducer: sarama.NewAsyncProducer,
+		producers:   map[kafkaProducerKey]*kafkaProducer{},
+	}
+}
+
+// SendEvent - Produce incoming event to kafka topic and wait for delivery report
+func (s *KafkaSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
+	ctx, span := helpers.Tracer().Start(ctx, "captin.KafkaSender.SendEvent")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	e := ev.(models.IncomingEvent)
+	d := dv.(models.Destination)
+
+	topic := d.Config.GetKafkaTopic()
+	span.SetAttributes(attribute.String("topic", topic))
+	kLogger.WithFields(log.Fields{"topic": topic}).Debug("Send kafka event")
+
+	e.DistributedTracingInfo.InjectContext(ctx)
+	payload, jsonErr := e.ToJson()
+	if jsonErr != nil {
+		kLogger.WithFields(log.Fields{"error": jsonErr}).Error("Failed to convert incoming event to json payload")
+		return jsonErr
+	}
+
+	key, err := helpers.RenderTemplate(d.Config.GetKafkaPartitionKey(), e.TemplateData(d.Config.GetName()))
+	if err != nil {
+		kLogger.WithFields(log.Fields{"error": err}).Error("Failed to render kafka partition key")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	result := make(chan error, 1)
+	message := &sarama.ProducerMessage{
+		Topic:    topic,
+		Key:      sarama.StringEncoder(key),
+		Value:    sarama.ByteEncoder(payload),
+		Headers:  kafkaRecordHeaders(e),
+		Metadata: result,
+	}
+
+	err = s.produce(ctx, d, message)
+	if err == nil {
+		select {
+		case err = <-result:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	if err != nil {
+		kLogger.WithFields(log.Fields{"error": err, "event": d.RedactEventForLog(e), "destination": d}).Error("Failed to send event with Kafka")
+		return &captin_errors.DispatcherError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	return nil
+}
+
+// Close - Flush buffered records and wait for their delivery reports
+func (s *KafkaSender) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	producers := s.producers
+	s.producers = map[kafkaProducerKey]*kafkaProducer{}
+	s.mu.Unlock()
+
+	for _, p := range producers {
+		p.producer.AsyncClose()
+		<-p.done
+	}
+	return nil
+}
+
+// produce - Put message into input of producer, read lock is held so that producer is not closed meanwhile
+func (s *KafkaSender) produce(ctx context.Context, d models.Destination, message *sarama.ProducerMessage) error {
+	p, err := s.getProducer(d)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errKafkaSenderClosed
+	}
+	select {
+	case p.producer.Input() <- message:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *KafkaSender) getProducer(d models.Destination) (*kafkaProducer, error) {
+	key := kafkaProducerKey{
+		acks:       d.Config.GetKafkaAcks(),
+		idempotent: d.Config.GetKafkaIdempotent(),
+		linger:     d.Config.GetKafkaLingerValue(),
+		batchSize:  d.Config.GetKafkaBatchSize(),
+	}
+
+	s.mu.RLock()
+	p, ok := s.producers[key]
+	s.mu.RUnlock()
+	if ok {
+		return p, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errKafkaSenderClosed
+	}
+	if p, ok := s.producers[key]; ok {
+		return p, nil
+	}
+
+	newProducer := s.NewProducer
+	if newProducer == nil {
+		newProducer = sarama.NewAsyncProducer
+	}
+	producer, err := newProducer(s.Brokers, newKafkaConfig(key))
+	if err != nil {
+		return nil, err
+	}
+	p = &kafkaProducer{producer: producer, done: make(chan struct{})}
+	go p.report()
+
+	if s.producers == nil {
+		s.producers = map[kafkaProducerKey]*kafkaProducer{}
+	}
+	s.producers[key] = p
+	return p, nil
+}
+
+// report - Deliver reports of producer to the waiting SendEvent, until producer is closed
+func (p *kafkaProducer) report() {
+	defer close(p.done)
+	successes, errors := p.producer.Successes(), p.producer.Errors()
+	for successes != nil || errors != nil {
+		select {
+		case message, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			message.Metadata.(chan error) <- nil
+		case producerErr, ok := <-errors:
+			if !ok {
+				errors = nil
+				continue
+			}
+			producerErr.Msg.Metadata.(chan error) <- producerErr.Err
+		}
+	}
+}
+
+func newKafkaConfig(key kafkaProducerKey) *sarama.Config {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	config.Producer.Flush.Frequency = key.linger
+	config.Producer.Flush.Messages = key.batchSize
+
+	switch key.acks {
+	case models.KafkaAcksLeader:
+		config.Producer.RequiredAcks = sarama.WaitForLocal
+	case models.KafkaAcksNone:
+		config.Producer.RequiredAcks = sarama.NoResponse
+	default:
+		config.Producer.RequiredAcks = sarama.WaitForAll
+	}
+
+	if key.idempotent {
+		// Requirements of idempotent producer, see sarama.Config.Validate
+		config.Version = sarama.V0_11_0_0
+		config.Producer.Idempotent = true
+		config.Net.MaxOpenRequests = 1
+	}
+	return config
+}
+
+// kafkaRecordHeaders - Headers of record, sorted for stable order
+func kafkaRecordHeaders(e models.IncomingEvent) []sarama.RecordHeader {
+	attributes := eventMessageAttributes(e)
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	headers := make([]sarama.RecordHeader, 0, len(keys))
+	for _, key := range keys {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(attributes[key])})
+	}
+	return headers
+}
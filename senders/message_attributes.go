@@ -0,0 +1,49 @@
+package senders
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+)
+
+// Maximum length of message group and deduplication IDs of SQS and SNS
+const maxMessageIDLength = 128
+
+// eventMessageAttributes - Attributes of queue and topic messages, for consumers to route on without parsing body
+func eventMessageAttributes(e models.IncomingEvent) map[string]string {
+	attributes := map[string]string{
+		"event_key":   e.Key,
+		"source":      e.Source,
+		"target_type": e.TargetType,
+		"trace_id":    e.TraceId,
+	}
+	// Trace context, e.g. traceparent and tracestate
+	for _, key := range e.DistributedTracingInfo.Keys() {
+		attributes[key] = e.DistributedTracingInfo.Get(key)
+	}
+	// Empty attribute values are rejected by AWS
+	for key, value := range attributes {
+		if value == "" {
+			delete(attributes, key)
+		}
+	}
+	return attributes
+}
+
+// renderMessageID - Render message group or deduplication ID of hook, long IDs are hashed to fit in the limit
+func renderMessageID(tmpl string, e models.IncomingEvent, d models.Destination) (string, error) {
+	if tmpl == "" {
+		return "", nil
+	}
+	id, err := helpers.RenderTemplate(tmpl, e.TemplateData(d.Config.GetName()))
+	if err != nil {
+		return "", err
+	}
+	if len(id) > maxMessageIDLength {
+		sum := sha256.Sum256([]byte(id))
+		return hex.EncodeToString(sum[:]), nil
+	}
+	return id, nil
+}
@@ -0,0 +1,161 @@
+package senders
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var nLogger = log.WithFields(log.Fields{"class": "NatsSender"})
+
+var _ interfaces.EventSenderInterface = &NatsSender{}
+var _ interfaces.EventSenderCloserInterface = &NatsSender{}
+
+// NatsSender - Publish Event to NATS subject, or to JetStream when enabled by hook
+// The connection is established on first send and reconnected by the NATS client.
+type NatsSender struct {
+	URL     string
+	Options []nats.Option
+
+	mu   sync.Mutex
+	conn *nats.Conn
+	js   nats.JetStreamContext
+}
+
+func NewNatsSender(url string, options ...nats.Option) *NatsSender {
+	return &NatsSender{URL: url, Options: options}
+}
+
+// SendEvent - Publish incoming event to NATS subject rendered from event
+func (s *NatsSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
+	ctx, span := helpers.Tracer().Start(ctx, "captin.NatsSender.SendEvent")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	e := ev.(models.IncomingEvent)
+	d := dv.(models.Destination)
+
+	subject, err := helpers.RenderTemplate(d.Config.GetNatsSubject(), e.TemplateData(d.Config.GetName()))
+	if err != nil {
+		nLogger.WithFields(log.Fields{"error": err}).Error("Failed to render nats subject")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	span.SetAttributes(attribute.String("subject", subject))
+	nLogger.WithFields(log.Fields{"subject": subject}).Debug("Send nats event")
+
+	e.DistributedTracingInfo.InjectContext(ctx)
+	payload, jsonErr := e.ToJson()
+	if jsonErr != nil {
+		nLogger.WithFields(log.Fields{"error": jsonErr}).Error("Failed to convert incoming event to json payload")
+		return jsonErr
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = payload
+	for key, value := range eventMessageAttributes(e) {
+		msg.Header.Set(key, value)
+	}
+
+	if d.Config.GetNatsJetStream() {
+		err = s.publishJetStream(ctx, msg, e, d)
+	} else {
+		err = s.publish(msg)
+	}
+
+	if err != nil {
+		nLogger.WithFields(log.Fields{"error": err, "event": d.RedactEventForLog(e), "destination": d}).Error("Failed to send event with NATS")
+		if _, ok := err.(*captin_errors.UnretryableError); ok {
+			return err
+		}
+		return &captin_errors.DispatcherError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	return nil
+}
+
+// Close - Drain the connection, so that pending messages are flushed
+func (s *NatsSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Drain()
+	s.conn = nil
+	s.js = nil
+	return err
+}
+
+func (s *NatsSender) publish(msg *nats.Msg) error {
+	conn, _, err := s.connect()
+	if err != nil {
+		return err
+	}
+	return conn.PublishMsg(msg)
+}
+
+// publishJetStream - Publish and wait for ack of stream, message ID is set for deduplication by server
+func (s *NatsSender) publishJetStream(ctx context.Context, msg *nats.Msg, e models.IncomingEvent, d models.Destination) error {
+	_, js, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	msgID := e.TraceId
+	if d.Config.GetDeduplicationID() != "" {
+		if msgID, err = renderMessageID(d.Config.GetDeduplicationID(), e, d); err != nil {
+			return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+		}
+	}
+	options := []nats.PubOpt{}
+	if msgID != "" {
+		options = append(options, nats.MsgId(msgID))
+	}
+
+	// Request of JetStream requires a deadline
+	ctx, cancel := context.WithTimeout(ctx, d.Config.GetNatsAckTimeoutValue())
+	defer cancel()
+	options = append(options, nats.Context(ctx))
+
+	ack, err := js.PublishMsg(msg, options...)
+	if err != nil {
+		return err
+	}
+	if ack.Duplicate {
+		nLogger.WithFields(log.Fields{"stream": ack.Stream, "msgID": msgID}).Debug("Duplicated message ignored by stream")
+	}
+	return nil
+}
+
+func (s *NatsSender) connect() (*nats.Conn, nats.JetStreamContext, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn, s.js, nil
+	}
+
+	conn, err := nats.Connect(s.URL, s.Options...)
+	if err != nil {
+		return nil, nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	s.conn = conn
+	s.js = js
+	return conn, js, nil
+}
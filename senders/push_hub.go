@@ -0,0 +1,178 @@
+package senders
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Policies of push subscribers not reading as fast as events are published
+const (
+	PushPolicyDisconnect = "disconnect"  // close the connection, client reconnects and replays from Last-Event-ID
+	PushPolicyDropOldest = "drop_oldest" // discard the oldest buffered message
+	PushPolicyDropNewest = "drop_newest" // discard the new message
+)
+
+// pushMessage - Event published to push channel, ID is a sequence of the channel
+type pushMessage struct {
+	ID    string
+	Event string
+	Data  []byte
+
+	seq         uint64
+	publishedAt time.Time
+}
+
+// pushSubscriber - Connection listening to channel, messages are buffered until written
+type pushSubscriber struct {
+	messages chan pushMessage
+	// closed when subscriber is disconnected as slow consumer
+	dropped chan struct{}
+	once    sync.Once
+}
+
+func (s *pushSubscriber) drop() {
+	s.once.Do(func() { close(s.dropped) })
+}
+
+type pushChannel struct {
+	seq         uint64
+	history     []pushMessage
+	subscribers map[*pushSubscriber]struct{}
+}
+
+// pushHub - Channels with subscribers and bounded history for replay
+type pushHub struct {
+	bufferSize  int
+	policy      string
+	historySize int
+	historyTTL  time.Duration
+
+	mu        sync.Mutex
+	channels  map[string]*pushChannel
+	lastSweep time.Time
+}
+
+func newPushHub(bufferSize int, policy string, historySize int, historyTTL time.Duration) *pushHub {
+	return &pushHub{
+		bufferSize:  bufferSize,
+		policy:      policy,
+		historySize: historySize,
+		historyTTL:  historyTTL,
+		channels:    map[string]*pushChannel{},
+		lastSweep:   time.Now(),
+	}
+}
+
+// publish - Deliver message to subscribers of channel and keep it in history, returns number of subscribers
+func (h *pushHub) publish(name string, event string, data []byte) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.sweep(now)
+
+	c := h.channel(name)
+	c.seq++
+	message := pushMessage{ID: strconv.FormatUint(c.seq, 10), Event: event, Data: data, seq: c.seq, publishedAt: now}
+	if h.historySize > 0 {
+		c.history = append(c.history, message)
+		if len(c.history) > h.historySize {
+			c.history = c.history[len(c.history)-h.historySize:]
+		}
+	}
+
+	for subscriber := range c.subscribers {
+		h.deliver(c, subscriber, message)
+	}
+	return len(c.subscribers)
+}
+
+// subscribe - Register subscriber of channel, messages after lastEventID in history are returned for replay
+func (h *pushHub) subscribe(name string, lastEventID string) (*pushSubscriber, []pushMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := h.channel(name)
+	subscriber := &pushSubscriber{messages: make(chan pushMessage, h.bufferSize), dropped: make(chan struct{})}
+	c.subscribers[subscriber] = struct{}{}
+
+	replay := []pushMessage{}
+	if lastEventID == "" {
+		return subscriber, replay
+	}
+	// Unknown ID replays the whole history, e.g. the history was lost on restart
+	last, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil || last > c.seq {
+		last = 0
+	}
+	cutoff := time.Now().Add(-h.historyTTL)
+	for _, message := range c.history {
+		if message.seq > last && message.publishedAt.After(cutoff) {
+			replay = append(replay, message)
+		}
+	}
+	return subscriber, replay
+}
+
+func (h *pushHub) unsubscribe(name string, subscriber *pushSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if c, ok := h.channels[name]; ok {
+		delete(c.subscribers, subscriber)
+	}
+}
+
+// deliver - Buffer message for subscriber, applying slow consumer policy when buffer is full
+func (h *pushHub) deliver(c *pushChannel, subscriber *pushSubscriber, message pushMessage) {
+	select {
+	case subscriber.messages <- message:
+		return
+	default:
+	}
+
+	switch h.policy {
+	case PushPolicyDropNewest:
+		pLogger.WithField("id", message.ID).Warn("Push subscriber is slow, new message dropped")
+	case PushPolicyDropOldest:
+		pLogger.WithField("id", message.ID).Warn("Push subscriber is slow, oldest message dropped")
+		select {
+		case <-subscriber.messages:
+		default:
+		}
+		select {
+		case subscriber.messages <- message:
+		default:
+		}
+	default:
+		pLogger.WithField("id", message.ID).Warn("Push subscriber is slow, disconnected")
+		delete(c.subscribers, subscriber)
+		subscriber.drop()
+	}
+}
+
+func (h *pushHub) channel(name string) *pushChannel {
+	c, ok := h.channels[name]
+	if !ok {
+		c = &pushChannel{subscribers: map[*pushSubscriber]struct{}{}}
+		h.channels[name] = c
+	}
+	return c
+}
+
+// sweep - Remove channels without subscribers and unexpired history, so that memory is bounded by recent channels
+func (h *pushHub) sweep(now time.Time) {
+	if now.Sub(h.lastSweep) < h.historyTTL {
+		return
+	}
+	h.lastSweep = now
+	cutoff := now.Add(-h.historyTTL)
+	for name, c := range h.channels {
+		if len(c.subscribers) > 0 {
+			continue
+		}
+		if len(c.history) == 0 || c.history[len(c.history)-1].publishedAt.Before(cutoff) {
+			delete(h.channels, name)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package senders
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var pLogger = log.WithFields(log.Fields{"class": "PushSender"})
+
+var _ interfaces.EventSenderInterface = &PushSender{}
+var _ http.Handler = &PushSender{}
+
+// Query parameters of push endpoints
+const (
+	PushChannelParam     = "channel"
+	PushLastEventIDParam = "last_event_id" // for clients unable to set Last-Event-ID header, e.g. browser WebSocket
+)
+
+// PushSender - Push Event to subscribers connected to captin, keyed by push_channel of hook
+// Subscribers connect to the SSE endpoint served by ServeHTTP, or the WebSocket endpoint of WebSocketHandler,
+// with the channel in query, e.g. "/events?channel=merchant:1".
+type PushSender struct {
+	// Authorize - Check if request could subscribe to channel, all requests are allowed if nil
+	Authorize func(r *http.Request, channel string) bool
+	// KeepAlive - Interval of keep alive comments and pings to subscribers
+	KeepAlive time.Duration
+
+	hub *pushHub
+}
+
+// NewPushSender - Create push sender
+//   - bufferSize: number of messages buffered per connection
+//   - policy: slow consumer policy when buffer is full, e.g. PushPolicyDisconnect
+//   - historySize and historyTTL: messages kept per channel for replay from Last-Event-ID
+func NewPushSender(bufferSize int, policy string, historySize int, historyTTL time.Duration) *PushSender {
+	return &PushSender{
+		KeepAlive: 15 * time.Second,
+		hub:       newPushHub(bufferSize, policy, historySize, historyTTL),
+	}
+}
+
+// SendEvent - Publish incoming event to push channel, subscribers connected later could replay it from history
+func (s *PushSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
+	ctx, span := helpers.Tracer().Start(ctx, "captin.PushSender.SendEvent")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	e := ev.(models.IncomingEvent)
+	d := dv.(models.Destination)
+
+	channel, err := helpers.RenderTemplate(d.Config.GetPushChannel(), e.TemplateData(d.Config.GetName()))
+	if err != nil {
+		pLogger.WithFields(log.Fields{"error": err}).Error("Failed to render push channel")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	e.DistributedTracingInfo.InjectContext(ctx)
+	payload, jsonErr := e.ToJson()
+	if jsonErr != nil {
+		pLogger.WithFields(log.Fields{"error": jsonErr}).Error("Failed to convert incoming event to json payload")
+		return jsonErr
+	}
+
+	subscribers := s.hub.publish(channel, e.Key, payload)
+	span.SetAttributes(attribute.String("channel", channel), attribute.Int("subscribers", subscribers))
+	pLogger.WithFields(log.Fields{"channel": channel, "subscribers": subscribers}).Debug("Push event")
+	return nil
+}
+
+// ServeHTTP - Stream events of channel with Server-Sent Events
+func (s *PushSender) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	channel, ok := s.channel(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	subscriber, replay := s.hub.subscribe(channel, lastEventID(r))
+	defer s.hub.unsubscribe(channel, subscriber)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, message := range replay {
+		writeSSE(w, message)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(s.KeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case message := <-subscriber.messages:
+			writeSSE(w, message)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-subscriber.dropped:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// channel - Get channel of subscription request, responds error if it is missing or not authorized
+func (s *PushSender) channel(w http.ResponseWriter, r *http.Request) (string, bool) {
+	channel := r.URL.Query().Get(PushChannelParam)
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return "", false
+	}
+	if s.Authorize != nil && !s.Authorize(r, channel) {
+		http.Error(w, "channel is forbidden", http.StatusForbidden)
+		return "", false
+	}
+	return channel, true
+}
+
+func lastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get(PushLastEventIDParam)
+}
+
+// Line breaks end fields of event stream, they are removed from event names given by callers
+var sseLineBreaks = strings.NewReplacer("\r", "", "\n", "")
+
+// writeSSE - Write message as event of stream, json data has no line breaks so it fits in a single data field
+func writeSSE(w http.ResponseWriter, message pushMessage) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", message.ID, sseLineBreaks.Replace(message.Event), message.Data)
+}
@@ -0,0 +1,83 @@
+package senders
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+// Timeout of writing a frame to WebSocket subscriber
+const pushWriteTimeout = 10 * time.Second
+
+// pushFrame - Message of WebSocket subscribers, fields are the same as SSE events
+type pushFrame struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// WebSocketHandler - Handler streaming events of channel over WebSocket, messages are json frames of id, event and data
+// upgrader decides allowed origins, the same origin is required by default.
+func (s *PushSender) WebSocketHandler(upgrader websocket.Upgrader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		channel, ok := s.channel(w, r)
+		if !ok {
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrader has responded the error
+			pLogger.WithFields(log.Fields{"error": err}).Debug("Failed to upgrade push connection")
+			return
+		}
+		defer conn.Close()
+
+		subscriber, replay := s.hub.subscribe(channel, lastEventID(r))
+		defer s.hub.unsubscribe(channel, subscriber)
+
+		// Read until the client closes, messages from client are ignored
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for _, message := range replay {
+			if err := writePushFrame(conn, message); err != nil {
+				return
+			}
+		}
+
+		keepAlive := time.NewTicker(s.KeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case message := <-subscriber.messages:
+				if err := writePushFrame(conn, message); err != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pushWriteTimeout)); err != nil {
+					return
+				}
+			case <-subscriber.dropped:
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer"), time.Now().Add(pushWriteTimeout))
+				return
+			case <-closed:
+				return
+			}
+		}
+	})
+}
+
+func writePushFrame(conn *websocket.Conn, message pushMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(pushWriteTimeout))
+	return conn.WriteJSON(pushFrame{ID: message.ID, Event: message.Event, Data: message.Data})
+}
@@ -0,0 +1,84 @@
+package senders
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var rsLogger = log.WithFields(log.Fields{"class": "RedisStreamSender"})
+
+var _ interfaces.EventSenderInterface = &RedisStreamSender{}
+
+// RedisStreamBodyField - Field of stream entry holding the event json
+const RedisStreamBodyField = "body"
+
+// RedisStreamSender - Add Event as entry of redis stream with XADD
+type RedisStreamSender struct {
+	Client redis.UniversalClient
+}
+
+func NewRedisStreamSender(client redis.UniversalClient) *RedisStreamSender {
+	return &RedisStreamSender{Client: client}
+}
+
+// SendEvent - Add incoming event to redis stream of hook
+func (s *RedisStreamSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
+	ctx, span := helpers.Tracer().Start(ctx, "captin.RedisStreamSender.SendEvent")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	e := ev.(models.IncomingEvent)
+	d := dv.(models.Destination)
+
+	stream, err := helpers.RenderTemplate(d.Config.GetRedisStream(), e.TemplateData(d.Config.GetName()))
+	if err != nil {
+		rsLogger.WithFields(log.Fields{"error": err}).Error("Failed to render redis stream")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	span.SetAttributes(attribute.String("stream", stream))
+	rsLogger.WithFields(log.Fields{"stream": stream}).Debug("Send redis stream event")
+
+	e.DistributedTracingInfo.InjectContext(ctx)
+	payload, jsonErr := e.ToJson()
+	if jsonErr != nil {
+		rsLogger.WithFields(log.Fields{"error": jsonErr}).Error("Failed to convert incoming event to json payload")
+		return jsonErr
+	}
+
+	// Fields for consumers to filter entries without parsing body
+	values := map[string]interface{}{}
+	for key, value := range eventMessageAttributes(e) {
+		values[key] = value
+	}
+	if e.TargetId != "" {
+		values["target_id"] = e.TargetId
+	}
+	values[RedisStreamBodyField] = string(payload)
+
+	args := &redis.XAddArgs{Stream: stream, Values: values}
+	if maxLen := d.Config.GetRedisStreamMaxLen(); maxLen > 0 {
+		args.MaxLen = int64(maxLen)
+		args.Approx = !d.Config.GetRedisStreamTrimExact()
+	}
+
+	id, err := s.Client.XAdd(ctx, args).Result()
+	if err != nil {
+		rsLogger.WithFields(log.Fields{"error": err, "event": d.RedactEventForLog(e), "destination": d}).Error("Failed to send event with Redis stream")
+		return &captin_errors.DispatcherError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	rsLogger.WithFields(log.Fields{"stream": stream, "id": id}).Debug("Redis stream entry added")
+	return nil
+}
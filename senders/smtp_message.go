@@ -0,0 +1,136 @@
+package senders
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// smtpMessage - Email of event, body is multipart/mixed with the payload attached as JSON file
+type smtpMessage struct {
+	From       *mail.Address
+	To         []*mail.Address
+	Subject    string
+	TextBody   string
+	HTMLBody   string
+	Attachment string
+	Payload    []byte
+}
+
+// Bytes - Encode message as RFC 5322 email with CRLF line endings
+func (m smtpMessage) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	mixed := multipart.NewWriter(&buf)
+
+	to := make([]string, len(m.To))
+	for i, address := range m.To {
+		to[i] = address.String()
+	}
+	headers := []string{
+		"From: " + m.From.String(),
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", m.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: " + smtpMessageID(m.From.Address),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + mixed.Boundary(),
+	}
+	header := strings.Join(headers, "\r\n") + "\r\n\r\n"
+
+	if err := m.writeBody(mixed); err != nil {
+		return nil, err
+	}
+	attachment, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType("application/json", map[string]string{"name": m.Attachment})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": m.Attachment})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64Lines(attachment, m.Payload); err != nil {
+		return nil, err
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return append([]byte(header), buf.Bytes()...), nil
+}
+
+// writeBody - Write plain-text body, or alternative plain-text and HTML bodies
+func (m smtpMessage) writeBody(mixed *multipart.Writer) error {
+	if m.HTMLBody == "" {
+		return writeQuotedPrintablePart(mixed, "text/plain; charset=utf-8", m.TextBody)
+	}
+
+	var buf bytes.Buffer
+	alternative := multipart.NewWriter(&buf)
+	if err := writeQuotedPrintablePart(alternative, "text/plain; charset=utf-8", m.TextBody); err != nil {
+		return err
+	}
+	if err := writeQuotedPrintablePart(alternative, "text/html; charset=utf-8", m.HTMLBody); err != nil {
+		return err
+	}
+	if err := alternative.Close(); err != nil {
+		return err
+	}
+	part, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + alternative.Boundary()},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(buf.Bytes())
+	return err
+}
+
+func writeQuotedPrintablePart(w *multipart.Writer, contentType string, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64Lines - Write base64 of data in lines of 76 characters, the limit of MIME
+func writeBase64Lines(w interface{ Write([]byte) (int, error) }, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := 76
+		if len(encoded) < n {
+			n = len(encoded)
+		}
+		if _, err := fmt.Fprintf(w, "%s\r\n", encoded[:n]); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// smtpMessageID - Unique Message-ID in domain of sender address
+func smtpMessageID(from string) string {
+	domain := "captin"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain)
+}
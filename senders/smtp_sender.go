@@ -0,0 +1,248 @@
+package senders
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var smtpLogger = log.WithFields(log.Fields{"class": "SMTPSender"})
+
+var _ interfaces.EventSenderInterface = &SMTPSender{}
+
+// SMTPSender - Send Event as email through SMTP server in callback_url, e.g. "smtp.example.com:587"
+// Recipients, subject and bodies are rendered from smtp_* templates of hook, and the payload is attached as JSON file.
+// Permanent failures of server (5xx) are not retried, transient failures (4xx) and network errors are.
+type SMTPSender struct {
+	// From - Sender address of hooks without smtp_from
+	From string
+	// TLSConfig - Base TLS config of connections, e.g. root CAs, server name is set to host of server
+	TLSConfig *tls.Config
+	// LocalName - Host name sent in EHLO, "localhost" if empty
+	LocalName string
+}
+
+// SendEvent - Render email of event and deliver it to SMTP server
+func (s *SMTPSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
+	ctx, span := helpers.Tracer().Start(ctx, "captin.SMTPSender.SendEvent")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	e := ev.(models.IncomingEvent)
+	d := dv.(models.Destination)
+
+	msg, err := s.newMessage(e, d)
+	if err != nil {
+		smtpLogger.WithFields(log.Fields{"error": err}).Error("Failed to prepare email")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	data, err := msg.Bytes()
+	if err != nil {
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	addr := d.GetCallbackURL()
+	span.SetAttributes(attribute.String("smtp.server", addr), attribute.Int("smtp.recipients", len(msg.To)))
+	smtpLogger.WithFields(log.Fields{"server": addr, "recipients": len(msg.To)}).Debug("Send smtp event")
+
+	if err := s.deliver(ctx, addr, e, d, msg, data); err != nil {
+		smtpLogger.WithFields(log.Fields{"error": err, "event": d.RedactEventForLog(e), "destination": d}).Error("Failed to send event with SMTP")
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+			return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+		}
+		if _, ok := err.(*captin_errors.UnretryableError); ok {
+			return err
+		}
+		return &captin_errors.DispatcherError{Msg: err.Error(), Event: e, Destination: d}
+	}
+	return nil
+}
+
+// newMessage - Render email of event with templates of hook
+func (s *SMTPSender) newMessage(e models.IncomingEvent, d models.Destination) (smtpMessage, error) {
+	data := e.TemplateData(d.Config.GetName())
+
+	from := d.Config.GetSMTPFrom()
+	if from == "" {
+		from = s.From
+	}
+	if from == "" {
+		return smtpMessage{}, fmt.Errorf("smtp sender address is not set")
+	}
+	fromAddress, err := mail.ParseAddress(from)
+	if err != nil {
+		return smtpMessage{}, fmt.Errorf("invalid smtp sender address: %s", err)
+	}
+
+	to, err := helpers.RenderTemplate(d.Config.GetSMTPTo(), data)
+	if err != nil {
+		return smtpMessage{}, fmt.Errorf("unable to render smtp recipients: %s", err)
+	}
+	if strings.TrimSpace(to) == "" {
+		return smtpMessage{}, fmt.Errorf("no smtp recipients")
+	}
+	toAddresses, err := mail.ParseAddressList(to)
+	if err != nil {
+		return smtpMessage{}, fmt.Errorf("invalid smtp recipients: %s", err)
+	}
+
+	subject, err := helpers.RenderTemplate(d.Config.GetSMTPSubject(), data)
+	if err != nil {
+		return smtpMessage{}, fmt.Errorf("unable to render smtp subject: %s", err)
+	}
+	text, err := helpers.RenderTemplate(d.Config.GetSMTPTextBody(), data)
+	if err != nil {
+		return smtpMessage{}, fmt.Errorf("unable to render smtp text body: %s", err)
+	}
+	var html string
+	if source := d.Config.GetSMTPHTMLBody(); source != "" {
+		if html, err = helpers.RenderHTMLTemplate(source, data); err != nil {
+			return smtpMessage{}, fmt.Errorf("unable to render smtp html body: %s", err)
+		}
+	}
+
+	// Attachment is read as file, characters escaped for embedding JSON in HTML are kept as they are
+	var payload bytes.Buffer
+	encoder := json.NewEncoder(&payload)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(e.Payload); err != nil {
+		return smtpMessage{}, err
+	}
+	attachment := "payload.json"
+	if e.Key != "" {
+		attachment = e.Key + ".json"
+	}
+
+	return smtpMessage{
+		From:       fromAddress,
+		To:         toAddresses,
+		Subject:    subject,
+		TextBody:   text,
+		HTMLBody:   html,
+		Attachment: attachment,
+		Payload:    payload.Bytes(),
+	}, nil
+}
+
+// deliver - Send email in a new SMTP session, the session is aborted when context is done or timeout is reached
+func (s *SMTPSender) deliver(ctx context.Context, addr string, e models.IncomingEvent, d models.Destination, msg smtpMessage, data []byte) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return &captin_errors.UnretryableError{Msg: fmt.Sprintf("invalid smtp server %s: %s", addr, err), Event: e, Destination: d}
+	}
+
+	deadline := time.Now().Add(d.Config.GetSMTPTimeoutValue())
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+
+	// Commands block on the connection, closing it unblocks them when context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	tlsMode := d.Config.GetSMTPTLS()
+	if tlsMode == models.SMTPTLSImplicit {
+		conn = tls.Client(conn, s.tlsConfig(host))
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.LocalName != "" {
+		if err := client.Hello(s.LocalName); err != nil {
+			return err
+		}
+	}
+	if tlsMode == models.SMTPTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return &captin_errors.UnretryableError{Msg: fmt.Sprintf("smtp server %s does not support STARTTLS", addr), Event: e, Destination: d}
+		}
+		if err := client.StartTLS(s.tlsConfig(host)); err != nil {
+			return err
+		}
+	}
+	if username := d.Config.GetSMTPUsername(); username != "" {
+		password, err := helpers.ResolveSecret(d.Config.GetSMTPPassword())
+		if err != nil {
+			return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+		}
+		if err := client.Auth(smtp.PlainAuth("", username, password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(msg.From.Address); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	// Email is accepted by server on end of data, failure of quit should not send it again on retry
+	if err := client.Quit(); err != nil {
+		smtpLogger.WithFields(log.Fields{"error": err, "server": addr}).Warn("Failed to quit smtp session")
+	}
+	return nil
+}
+
+func (s *SMTPSender) tlsConfig(host string) *tls.Config {
+	config := &tls.Config{}
+	if s.TLSConfig != nil {
+		config = s.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	return config
+}
@@ -0,0 +1,166 @@
+package senders
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	aws "github.com/aws/aws-sdk-go/aws"
+	aws_credentials "github.com/aws/aws-sdk-go/aws/credentials"
+	aws_session "github.com/aws/aws-sdk-go/aws/session"
+	aws_sns "github.com/aws/aws-sdk-go/service/sns"
+	aws_snsiface "github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+var snsLogger = log.WithFields(log.Fields{"class": "SnsSender"})
+
+var _ interfaces.EventSenderInterface = &SnsSender{}
+
+// SnsContentEncodingAttribute - Message attribute marking compressed message, e.g. "gzip+base64"
+const SnsContentEncodingAttribute = "content_encoding"
+
+// SnsSender - Publish Event to AWS SNS topic, the topic ARN is taken from callback_url
+// The zero value uses default AWS config from environment.
+type SnsSender struct {
+	DefaultClient        aws_snsiface.SNSAPI
+	DestinationClientMap map[string]aws_snsiface.SNSAPI
+
+	mu sync.Mutex
+}
+
+func NewSnsSender(defaultAwsConfig aws.Config) *SnsSender {
+	defaultSession := aws_session.Must(aws_session.NewSession(&defaultAwsConfig))
+	return &SnsSender{
+		DefaultClient:        aws_sns.New(defaultSession),
+		DestinationClientMap: map[string]aws_snsiface.SNSAPI{},
+	}
+}
+
+// SendEvent - Publish incoming event to SNS topic
+func (s *SnsSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
+	ctx, span := helpers.Tracer().Start(ctx, "captin.SnsSender.SendEvent")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	e := ev.(models.IncomingEvent)
+	d := dv.(models.Destination)
+
+	topicArn := d.GetCallbackURL()
+	span.SetAttributes(attribute.String("topicArn", topicArn))
+	snsLogger.WithFields(log.Fields{"topicArn": topicArn}).Debug("Send sns event")
+
+	e.DistributedTracingInfo.InjectContext(ctx)
+	payload, jsonErr := e.ToJson()
+	if jsonErr != nil {
+		snsLogger.WithFields(log.Fields{"error": jsonErr}).Error("Failed to convert incoming event to json payload")
+		return jsonErr
+	}
+
+	input, err := newSnsPublishInput(topicArn, payload, e, d)
+	if err != nil {
+		snsLogger.WithFields(log.Fields{"error": err}).Error("Failed to prepare sns message")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	_, err = s.GetClient(dv).PublishWithContext(ctx, input)
+	if err != nil {
+		snsLogger.WithFields(log.Fields{"error": err, "event": d.RedactEventForLog(e), "destination": d}).Error("Failed to send event with SNS")
+	}
+
+	return err
+}
+
+// newSnsPublishInput - Message of event with attributes and FIFO IDs of destination
+func newSnsPublishInput(topicArn string, payload []byte, e models.IncomingEvent, d models.Destination) (*aws_sns.PublishInput, error) {
+	input := &aws_sns.PublishInput{
+		Message:           aws.String(string(payload)),
+		TopicArn:          aws.String(topicArn),
+		MessageAttributes: map[string]*aws_sns.MessageAttributeValue{},
+	}
+	for key, value := range eventMessageAttributes(e) {
+		input.MessageAttributes[key] = snsStringAttribute(value)
+	}
+
+	groupID, err := renderMessageID(d.Config.GetMessageGroupID(), e, d)
+	if err != nil {
+		return nil, err
+	}
+	if groupID != "" {
+		input.MessageGroupId = aws.String(groupID)
+	}
+	deduplicationID, err := renderMessageID(d.Config.GetDeduplicationID(), e, d)
+	if err != nil {
+		return nil, err
+	}
+	if deduplicationID != "" {
+		input.MessageDeduplicationId = aws.String(deduplicationID)
+	}
+
+	// Compressed message is base64 encoded as SNS accepts text only, same as SQS
+	compressed, algorithm, err := d.Compress(payload)
+	if err != nil {
+		return nil, err
+	}
+	if algorithm != "" {
+		input.Message = aws.String(base64.StdEncoding.EncodeToString(compressed))
+		input.MessageAttributes[SnsContentEncodingAttribute] = snsStringAttribute(algorithm + "+base64")
+	}
+	return input, nil
+}
+
+func snsStringAttribute(value string) *aws_sns.MessageAttributeValue {
+	return &aws_sns.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+func (s *SnsSender) GetClient(dv interfaces.DestinationInterface) aws_snsiface.SNSAPI {
+	d := dv.(models.Destination)
+	destName := d.Config.GetName()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dv.GetSnsSenderConfig("USE_CUSTOM_CONFIG") == "true" {
+		if s.DestinationClientMap == nil {
+			s.DestinationClientMap = map[string]aws_snsiface.SNSAPI{}
+		}
+		_, topicInitialized := s.DestinationClientMap[destName]
+		if !topicInitialized {
+			awsConfig := aws.Config{}
+
+			if dv.GetSnsSenderConfig("AWS_ENDPOINT") != "" {
+				awsConfig.Endpoint = aws.String(dv.GetSnsSenderConfig("AWS_ENDPOINT"))
+			}
+
+			if dv.GetSnsSenderConfig("AWS_REGION") != "" {
+				awsConfig.Region = aws.String(dv.GetSnsSenderConfig("AWS_REGION"))
+			}
+
+			if dv.GetSnsSenderConfig("AWS_ACCESS_KEY_ID") != "" && dv.GetSnsSenderConfig("AWS_SECRET_ACCESS_KEY") != "" {
+				awsConfig.Credentials = aws_credentials.NewStaticCredentials(dv.GetSnsSenderConfig("AWS_ACCESS_KEY_ID"), dv.GetSnsSenderConfig("AWS_SECRET_ACCESS_KEY"), "")
+			}
+
+			session := aws_session.Must(aws_session.NewSession(&awsConfig))
+			s.DestinationClientMap[destName] = aws_sns.New(session)
+		}
+
+		return s.DestinationClientMap[destName]
+	}
+
+	if s.DefaultClient == nil {
+		s.DefaultClient = aws_sns.New(aws_session.Must(aws_session.NewSession()))
+	}
+	return s.DefaultClient
+}
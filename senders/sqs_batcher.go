@@ -0,0 +1,200 @@
+package senders
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	aws "github.com/aws/aws-sdk-go/aws"
+	aws_sqs "github.com/aws/aws-sdk-go/service/sqs"
+	aws_sqsiface "github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	log "github.com/sirupsen/logrus"
+)
+
+// Limits of SendMessageBatch
+const (
+	sqsMaxBatchSize  = 10
+	sqsMaxBatchBytes = 256 * 1024
+)
+
+// sqsBatchEntryError - Failure of a single entry of SendMessageBatch
+type sqsBatchEntryError struct {
+	Code        string
+	Message     string
+	SenderFault bool
+}
+
+func (e sqsBatchEntryError) Error() string {
+	return fmt.Sprintf("SQS batch entry failed with %s: %s", e.Code, e.Message)
+}
+
+type sqsBatchEntry struct {
+	input  *aws_sqs.SendMessageInput
+	result chan error
+}
+
+type sqsBatch struct {
+	key      string
+	client   aws_sqsiface.SQSAPI
+	queueURL string
+	entries  []sqsBatchEntry
+	bytes    int
+	timer    *time.Timer
+}
+
+// sqsBatcher - Collect messages per client and queue URL, send them with SendMessageBatch
+// when batch is full or window elapsed. Senders wait for result of their own entry.
+type sqsBatcher struct {
+	size    int
+	window  time.Duration
+	mu      sync.Mutex
+	batches map[string]*sqsBatch
+	closed  bool
+	flushes sync.WaitGroup
+}
+
+func newSqsBatcher(size int, window time.Duration) *sqsBatcher {
+	if size <= 0 || size > sqsMaxBatchSize {
+		size = sqsMaxBatchSize
+	}
+	return &sqsBatcher{size: size, window: window, batches: map[string]*sqsBatch{}}
+}
+
+// send - Add message to batch and wait for its result, message is sent alone after batcher is closed
+func (b *sqsBatcher) send(ctx context.Context, client aws_sqsiface.SQSAPI, input *aws_sqs.SendMessageInput) error {
+	entry := sqsBatchEntry{input: input, result: make(chan error, 1)}
+	bytes := sqsMessageBytes(input)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		_, err := client.SendMessageWithContext(ctx, input)
+		return err
+	}
+	key := fmt.Sprintf("%p|%s", client, aws.StringValue(input.QueueUrl))
+	batch, exists := b.batches[key]
+	if exists && batch.bytes+bytes > sqsMaxBatchBytes {
+		b.detach(batch)
+		b.flushAsync(batch)
+		exists = false
+	}
+	if !exists {
+		batch = &sqsBatch{key: key, client: client, queueURL: aws.StringValue(input.QueueUrl)}
+		b.batches[key] = batch
+		batch.timer = time.AfterFunc(b.window, func() { b.flushKey(batch) })
+	}
+	batch.entries = append(batch.entries, entry)
+	batch.bytes += bytes
+	if len(batch.entries) >= b.size {
+		b.detach(batch)
+		b.flushAsync(batch)
+	}
+	b.mu.Unlock()
+
+	select {
+	case err := <-entry.result:
+		return err
+	case <-ctx.Done():
+		// Entry stays in its batch, as the batch may already be in flight
+		return ctx.Err()
+	}
+}
+
+// sqsMessageBytes - Size of message counted towards batch limit, body with name, type and value of attributes
+func sqsMessageBytes(input *aws_sqs.SendMessageInput) int {
+	bytes := len(aws.StringValue(input.MessageBody))
+	for name, attribute := range input.MessageAttributes {
+		bytes += len(name) + len(aws.StringValue(attribute.DataType)) + len(aws.StringValue(attribute.StringValue)) + len(attribute.BinaryValue)
+	}
+	return bytes
+}
+
+// close - Flush pending batches and send further messages alone
+func (b *sqsBatcher) close() {
+	b.mu.Lock()
+	b.closed = true
+	pending := []*sqsBatch{}
+	for _, batch := range b.batches {
+		pending = append(pending, batch)
+	}
+	for _, batch := range pending {
+		b.detach(batch)
+		b.flushAsync(batch)
+	}
+	b.mu.Unlock()
+	b.flushes.Wait()
+}
+
+// detach - Remove batch from pending batches, caller should hold the lock
+func (b *sqsBatcher) detach(batch *sqsBatch) {
+	batch.timer.Stop()
+	if b.batches[batch.key] == batch {
+		delete(b.batches, batch.key)
+	}
+}
+
+// flushAsync - Send batch in background, caller should hold the lock
+func (b *sqsBatcher) flushAsync(batch *sqsBatch) {
+	b.flushes.Add(1)
+	go func() {
+		defer b.flushes.Done()
+		b.flush(batch)
+	}()
+}
+
+func (b *sqsBatcher) flushKey(batch *sqsBatch) {
+	b.mu.Lock()
+	if b.batches[batch.key] != batch {
+		// Already flushed as it was full
+		b.mu.Unlock()
+		return
+	}
+	b.detach(batch)
+	b.flushAsync(batch)
+	b.mu.Unlock()
+}
+
+func (b *sqsBatcher) flush(batch *sqsBatch) {
+	input := &aws_sqs.SendMessageBatchInput{QueueUrl: aws.String(batch.queueURL)}
+	for i, entry := range batch.entries {
+		input.Entries = append(input.Entries, &aws_sqs.SendMessageBatchRequestEntry{
+			Id:                     aws.String(strconv.Itoa(i)),
+			MessageBody:            entry.input.MessageBody,
+			MessageAttributes:      entry.input.MessageAttributes,
+			MessageGroupId:         entry.input.MessageGroupId,
+			MessageDeduplicationId: entry.input.MessageDeduplicationId,
+			DelaySeconds:           entry.input.DelaySeconds,
+		})
+	}
+
+	sLogger.WithFields(log.Fields{"queueURL": batch.queueURL, "size": len(batch.entries)}).Debug("Send sqs batch")
+	// Entries come from different dispatches, none of their contexts owns the batch
+	output, err := batch.client.SendMessageBatchWithContext(context.Background(), input)
+	if err != nil {
+		for _, entry := range batch.entries {
+			entry.result <- err
+		}
+		return
+	}
+
+	results := map[string]error{}
+	for _, success := range output.Successful {
+		results[aws.StringValue(success.Id)] = nil
+	}
+	for _, failed := range output.Failed {
+		results[aws.StringValue(failed.Id)] = sqsBatchEntryError{
+			Code:        aws.StringValue(failed.Code),
+			Message:     aws.StringValue(failed.Message),
+			SenderFault: aws.BoolValue(failed.SenderFault),
+		}
+	}
+	for i, entry := range batch.entries {
+		result, ok := results[strconv.Itoa(i)]
+		if !ok {
+			result = fmt.Errorf("SQS batch entry %d has no result", i)
+		}
+		entry.result <- result
+	}
+}
@@ -2,7 +2,10 @@ package senders
 
 import (
 	"context"
+	"encoding/base64"
+	"time"
 
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
 	interfaces "github.com/shoplineapp/captin/v2/interfaces"
 	"github.com/shoplineapp/captin/v2/internal/helpers"
 	models "github.com/shoplineapp/captin/v2/models"
@@ -20,11 +23,17 @@ import (
 var sLogger = log.WithFields(log.Fields{"class": "SqsSender"})
 
 var _ interfaces.EventSenderInterface = &SqsSender{}
+var _ interfaces.EventSenderCloserInterface = &SqsSender{}
+
+// SqsContentEncodingAttribute - Message attribute marking compressed body, e.g. "gzip+base64"
+const SqsContentEncodingAttribute = "content_encoding"
 
 // SqsSender - Send Event to AWS SQS
 type SqsSender struct {
 	DefaultClient        aws_sqsiface.SQSAPI
 	DestinationClientMap map[string]aws_sqsiface.SQSAPI
+
+	batcher *sqsBatcher
 }
 
 func NewSqsSender(defaultAwsConfig aws.Config) *SqsSender {
@@ -35,6 +44,20 @@ func NewSqsSender(defaultAwsConfig aws.Config) *SqsSender {
 	}
 }
 
+// SetBatching - Send messages with SendMessageBatch, collecting up to size messages per queue for at most window
+// Close should be called on shutdown to flush pending batches.
+func (s *SqsSender) SetBatching(size int, window time.Duration) {
+	s.batcher = newSqsBatcher(size, window)
+}
+
+// Close - Flush pending batches, messages are sent one by one afterwards
+func (s *SqsSender) Close() error {
+	if s.batcher != nil {
+		s.batcher.close()
+	}
+	return nil
+}
+
 // SendEvent - Send incoming event into SQS queue
 func (s *SqsSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventInterface, dv interfaces.DestinationInterface) (err error) {
 	ctx, span := helpers.Tracer().Start(ctx, "captin.SqsSender.SendEvent")
@@ -60,18 +83,75 @@ func (s *SqsSender) SendEvent(ctx context.Context, ev interfaces.IncomingEventIn
 		return jsonErr
 	}
 
-	_, err = s.GetClient(dv).SendMessageWithContext(ctx, &aws_sqs.SendMessageInput{
-		MessageBody: aws.String(string(payload)),
-		QueueUrl:    &queueURL,
-	})
+	input, err := newSqsMessageInput(queueURL, payload, e, d)
+	if err != nil {
+		sLogger.WithFields(log.Fields{"error": err}).Error("Failed to prepare sqs message")
+		return &captin_errors.UnretryableError{Msg: err.Error(), Event: e, Destination: d}
+	}
+
+	if s.batcher != nil {
+		err = s.batcher.send(ctx, s.GetClient(dv), input)
+		if entryErr, ok := err.(sqsBatchEntryError); ok && entryErr.SenderFault {
+			// Message is rejected as it is, retrying will not help
+			err = &captin_errors.UnretryableError{Msg: entryErr.Error(), Event: e, Destination: d}
+		}
+	} else {
+		_, err = s.GetClient(dv).SendMessageWithContext(ctx, input)
+	}
 
 	if err != nil {
-		sLogger.WithFields(log.Fields{"error": err, "event": e, "destination": d}).Error("Failed to send event with SQS")
+		sLogger.WithFields(log.Fields{"error": err, "event": d.RedactEventForLog(e), "destination": d}).Error("Failed to send event with SQS")
 	}
 
 	return err
 }
 
+// newSqsMessageInput - Message of event with attributes, FIFO IDs and delay of destination
+func newSqsMessageInput(queueURL string, payload []byte, e models.IncomingEvent, d models.Destination) (*aws_sqs.SendMessageInput, error) {
+	input := &aws_sqs.SendMessageInput{
+		MessageBody:       aws.String(string(payload)),
+		QueueUrl:          aws.String(queueURL),
+		MessageAttributes: map[string]*aws_sqs.MessageAttributeValue{},
+	}
+	for key, value := range eventMessageAttributes(e) {
+		input.MessageAttributes[key] = sqsStringAttribute(value)
+	}
+
+	groupID, err := renderMessageID(d.Config.GetMessageGroupID(), e, d)
+	if err != nil {
+		return nil, err
+	}
+	if groupID != "" {
+		input.MessageGroupId = aws.String(groupID)
+	}
+	deduplicationID, err := renderMessageID(d.Config.GetDeduplicationID(), e, d)
+	if err != nil {
+		return nil, err
+	}
+	if deduplicationID != "" {
+		input.MessageDeduplicationId = aws.String(deduplicationID)
+	}
+
+	if d.RequireNativeDelay(e) {
+		input.DelaySeconds = aws.Int64(int64(d.Config.GetDelayValue() / time.Second))
+	}
+
+	// Compressed body is base64 encoded as SQS accepts text only, and marked by the content_encoding attribute
+	compressed, algorithm, err := d.Compress(payload)
+	if err != nil {
+		return nil, err
+	}
+	if algorithm != "" {
+		input.MessageBody = aws.String(base64.StdEncoding.EncodeToString(compressed))
+		input.MessageAttributes[SqsContentEncodingAttribute] = sqsStringAttribute(algorithm + "+base64")
+	}
+	return input, nil
+}
+
+func sqsStringAttribute(value string) *aws_sqs.MessageAttributeValue {
+	return &aws_sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
 func (s *SqsSender) GetClient(dv interfaces.DestinationInterface) aws_sqsiface.SQSAPI {
 	d := dv.(models.Destination)
 	destName := d.Config.GetName()
@@ -2,14 +2,19 @@ package models_test
 
 import (
 	"context"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
+	statsd "github.com/joeycumines/statsd"
 	"github.com/stretchr/testify/assert"
 
 	. "github.com/shoplineapp/captin/v2/core"
 	captin_errors "github.com/shoplineapp/captin/v2/errors"
 	interfaces "github.com/shoplineapp/captin/v2/interfaces"
 	models "github.com/shoplineapp/captin/v2/models"
+	senders "github.com/shoplineapp/captin/v2/senders"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -33,6 +38,11 @@ func TestNewCaptin(t *testing.T) {
 	if captin.SenderMapping["http"] == nil {
 		t.Errorf("Expected Captin to have a default http sender")
 	}
+	// It has a default sns sender
+	assert.NotNil(t, captin.SenderMapping["sns"])
+	assert.NotNil(t, captin.SenderMapping["grpc"])
+	assert.NotNil(t, captin.SenderMapping["chat"])
+	assert.NotNil(t, captin.SenderMapping["smtp"])
 }
 
 func TestExecute(t *testing.T) {
@@ -66,3 +76,36 @@ func TestSetDocumentStoreMapping(t *testing.T) {
 	captin.SetDocumentStoreMapping(storeMapping)
 	assert.Equal(t, captin.DocumentStoreMapping["mock"], mockStore)
 }
+
+func TestSetStatsdClient(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+	client, err := statsd.New(statsd.Address(conn.LocalAddr().String()))
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Hook sampling out every event
+	rate := 0.0
+	configMapper := models.NewConfigurationMapper([]interfaces.ConfigurationInterface{
+		models.Configuration{Name: "sampled_service", Actions: []string{"product.update"}, SampleRate: &rate},
+	})
+	captin := NewCaptin(*configMapper)
+	captin.SetStatsdClient(client)
+	assert.Equal(t, client, captin.SenderMapping["http"].(*senders.HTTPEventSender).StatsdClient)
+	_, errors := captin.Execute(context.Background(), models.IncomingEvent{Key: "product.update", Source: "core", TargetType: "Product", TargetId: "product_id"})
+	assert.Empty(t, errors)
+	client.Flush()
+
+	received := ""
+	buffer := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for !strings.Contains(received, "hook.filter.sampling.skipped") {
+		n, _, err := conn.ReadFrom(buffer)
+		if !assert.Nil(t, err) {
+			break
+		}
+		received += string(buffer[:n])
+	}
+	assert.Contains(t, received, "hook.filter.sampling.skipped,metricname=sampled_service,hook=sampled_service")
+}
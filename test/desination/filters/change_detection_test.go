@@ -0,0 +1,119 @@
+package destination_filters_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/shoplineapp/captin/v2/destinations/filters"
+	interfaces "github.com/shoplineapp/captin/v2/interfaces"
+	helpers "github.com/shoplineapp/captin/v2/internal/helpers"
+	stores "github.com/shoplineapp/captin/v2/internal/stores"
+	models "github.com/shoplineapp/captin/v2/models"
+)
+
+func TestChangeDetectionFilterRun(t *testing.T) {
+	filter := ChangeDetectionFilter{Store: stores.NewMemoryStore()}
+	destination := models.Destination{Config: models.Configuration{
+		Name:               "price_sync",
+		WatchPayloadAttrs:  []string{"price"},
+		WatchDocumentAttrs: []string{"stock"},
+	}}
+	event := models.IncomingEvent{
+		Key:            "product.update",
+		TargetType:     "Product",
+		TargetId:       "product_id",
+		Payload:        map[string]interface{}{"price": 100, "title": "foo"},
+		TargetDocument: map[string]interface{}{"stock": 1, "title": "foo"},
+	}
+
+	// Nothing delivered yet
+	assert.Equal(t, true, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+	filter.OnDelivered(context.Background(), event, destination)
+
+	// Unwatched fields changed
+	event.Payload = map[string]interface{}{"price": 100, "title": "bar"}
+	event.TargetDocument = map[string]interface{}{"stock": 1, "title": "bar"}
+	assert.Equal(t, false, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+
+	// Hash is shared across event keys of the same target
+	event.Key = "product.create"
+	assert.Equal(t, false, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+
+	// Watched document field changed
+	event.TargetDocument = map[string]interface{}{"stock": 0, "title": "bar"}
+	assert.Equal(t, true, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+
+	// Another target
+	event.TargetDocument = map[string]interface{}{"stock": 1, "title": "bar"}
+	event.TargetId = "another_product_id"
+	assert.Equal(t, true, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+}
+
+func TestChangeDetectionFilterRun_NotDelivered(t *testing.T) {
+	filter := ChangeDetectionFilter{Store: stores.NewMemoryStore()}
+	destination := models.Destination{Config: models.Configuration{Name: "price_sync", WatchPayloadAttrs: []string{"price"}}}
+	event := models.IncomingEvent{TargetType: "Product", TargetId: "product_id", Payload: map[string]interface{}{"price": 100}}
+
+	// Hash is claimed while event is in flight
+	assert.Equal(t, true, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+	assert.Equal(t, false, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+
+	// Claim is released on failure, so that failed events are not skipped on retry
+	filter.OnFailed(context.Background(), event, destination)
+	assert.Equal(t, true, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+}
+
+func TestChangeDetectionFilterRun_Concurrent(t *testing.T) {
+	filter := ChangeDetectionFilter{Store: stores.NewMemoryStore()}
+	destination := models.Destination{Config: models.Configuration{Name: "price_sync", WatchPayloadAttrs: []string{"price"}}}
+
+	// Only one of concurrent events with the same watched fields passes, whatever hash it replaces
+	for _, price := range []int{100, 200, 100} {
+		event := models.IncomingEvent{TargetType: "Product", TargetId: "product_id", Payload: map[string]interface{}{"price": price}}
+		var passed int32
+		wg := sync.WaitGroup{}
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if helpers.Tuples(filter.Run(context.Background(), event, destination))[0].(bool) {
+					atomic.AddInt32(&passed, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.EqualValues(t, 1, passed)
+		filter.OnDelivered(context.Background(), event, destination)
+	}
+}
+
+// Store without compare and set
+type plainStore struct {
+	interfaces.StoreInterface
+}
+
+func TestChangeDetectionFilterRun_NotClaimable(t *testing.T) {
+	filter := ChangeDetectionFilter{Store: plainStore{stores.NewMemoryStore()}}
+	destination := models.Destination{Config: models.Configuration{Name: "price_sync", WatchPayloadAttrs: []string{"price"}}}
+	event := models.IncomingEvent{TargetType: "Product", TargetId: "product_id", Payload: map[string]interface{}{"price": 100}}
+
+	// Hash is remembered only after delivery
+	assert.Equal(t, true, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+	assert.Equal(t, true, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+	filter.OnFailed(context.Background(), event, destination)
+	filter.OnDelivered(context.Background(), event, destination)
+	assert.Equal(t, false, helpers.Tuples(filter.Run(context.Background(), event, destination))[0])
+}
+
+func TestChangeDetectionFilterApplicable(t *testing.T) {
+	event := models.IncomingEvent{}
+	store := stores.NewMemoryStore()
+	assert.Equal(t, false, ChangeDetectionFilter{Store: store}.Applicable(context.Background(), event, models.Destination{Config: models.Configuration{}}))
+	assert.Equal(t, false, ChangeDetectionFilter{}.Applicable(context.Background(), event, models.Destination{Config: models.Configuration{WatchPayloadAttrs: []string{"price"}}}))
+	assert.Equal(t, true, ChangeDetectionFilter{Store: store}.Applicable(context.Background(), event, models.Destination{Config: models.Configuration{WatchPayloadAttrs: []string{"price"}}}))
+	assert.Equal(t, true, ChangeDetectionFilter{Store: store}.Applicable(context.Background(), event, models.Destination{Config: models.Configuration{WatchDocumentAttrs: []string{"stock"}}}))
+}
@@ -0,0 +1,75 @@
+package destination_filters_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/shoplineapp/captin/v2/destinations/filters"
+	helpers "github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+)
+
+func sampleRate(rate float64) *float64 {
+	return &rate
+}
+
+func TestSamplingFilterRunDeterministic(t *testing.T) {
+	destination := models.Destination{Config: models.Configuration{Name: "sampled_service", SampleRate: sampleRate(0.5)}}
+	for i := 0; i < 20; i++ {
+		event := models.IncomingEvent{TargetId: fmt.Sprintf("product_%d", i), TraceId: fmt.Sprintf("trace_%d", i)}
+		first := helpers.Tuples(SamplingFilter{}.Run(context.Background(), event, destination))[0]
+		event.TraceId = "another_trace"
+		second := helpers.Tuples(SamplingFilter{}.Run(context.Background(), event, destination))[0]
+		assert.Equal(t, first, second)
+	}
+}
+
+func TestSamplingFilterRunRate(t *testing.T) {
+	countSampled := func(d models.Destination) int {
+		count := 0
+		for i := 0; i < 1000; i++ {
+			event := models.IncomingEvent{TargetId: fmt.Sprintf("product_%d", i)}
+			if helpers.Tuples(SamplingFilter{}.Run(context.Background(), event, d))[0] == true {
+				count++
+			}
+		}
+		return count
+	}
+
+	assert.Equal(t, 0, countSampled(models.Destination{Config: models.Configuration{Name: "sampled_service", SampleRate: sampleRate(0)}}))
+	assert.Equal(t, 1000, countSampled(models.Destination{Config: models.Configuration{Name: "sampled_service", SampleRate: sampleRate(1)}}))
+	assert.InDelta(t, 100, countSampled(models.Destination{Config: models.Configuration{Name: "sampled_service", SampleRate: sampleRate(0.1)}}), 40)
+}
+
+func TestSamplingFilterRunGradualRollout(t *testing.T) {
+	defer os.Unsetenv("HOOK_SAMPLED_SERVICE_SAMPLE_RATE")
+	destination := models.Destination{Config: models.Configuration{Name: "sampled_service", SampleRate: sampleRate(0.1)}}
+
+	// Targets sampled in at a lower rate stay sampled in when rate increases
+	sampledIn := []models.IncomingEvent{}
+	for i := 0; i < 100; i++ {
+		event := models.IncomingEvent{TargetId: fmt.Sprintf("product_%d", i)}
+		if helpers.Tuples(SamplingFilter{}.Run(context.Background(), event, destination))[0] == true {
+			sampledIn = append(sampledIn, event)
+		}
+	}
+
+	os.Setenv("HOOK_SAMPLED_SERVICE_SAMPLE_RATE", "0.5")
+	for _, event := range sampledIn {
+		assert.Equal(t, true, helpers.Tuples(SamplingFilter{}.Run(context.Background(), event, destination))[0])
+	}
+}
+
+func TestSamplingFilterApplicable(t *testing.T) {
+	defer os.Unsetenv("HOOK_SAMPLED_SERVICE_SAMPLE_RATE")
+	event := models.IncomingEvent{}
+	assert.Equal(t, false, SamplingFilter{}.Applicable(context.Background(), event, models.Destination{Config: models.Configuration{Name: "sampled_service"}}))
+	assert.Equal(t, true, SamplingFilter{}.Applicable(context.Background(), event, models.Destination{Config: models.Configuration{Name: "sampled_service", SampleRate: sampleRate(0.1)}}))
+
+	os.Setenv("HOOK_SAMPLED_SERVICE_SAMPLE_RATE", "1")
+	assert.Equal(t, false, SamplingFilter{}.Applicable(context.Background(), event, models.Destination{Config: models.Configuration{Name: "sampled_service", SampleRate: sampleRate(0.1)}}))
+}
@@ -35,3 +35,17 @@ func TestTrackGoRoutine(t *testing.T) {
 	assert.EqualValues(t, 0, dispatcher.PendingJobCount())
 
 }
+
+func TestTrackHeldEvents(t *testing.T) {
+	dispatcher.TrackHeldEvents("service_one", 2)
+	dispatcher.TrackHeldEvents("service_two", 1)
+
+	assert.Equal(t, map[string]int64{"service_one": 2, "service_two": 1}, dispatcher.HeldEventCounts())
+	assert.EqualValues(t, 3, dispatcher.HeldEventCount())
+
+	dispatcher.TrackHeldEvents("service_one", -2)
+	dispatcher.TrackHeldEvents("service_two", -1)
+
+	assert.Equal(t, map[string]int64{}, dispatcher.HeldEventCounts())
+	assert.EqualValues(t, 0, dispatcher.HeldEventCount())
+}
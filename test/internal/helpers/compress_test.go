@@ -0,0 +1,29 @@
+package helpers_test
+
+import (
+	"strings"
+	"testing"
+
+	helpers "github.com/shoplineapp/captin/v2/internal/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress_RoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat(`{"title":"foo"}`, 100))
+	for _, algorithm := range []string{helpers.CompressionGzip, helpers.CompressionZstd} {
+		compressed, err := helpers.Compress(data, algorithm)
+		assert.Nil(t, err)
+		assert.Less(t, len(compressed), len(data))
+
+		decompressed, err := helpers.Decompress(compressed, algorithm)
+		assert.Nil(t, err)
+		assert.Equal(t, data, decompressed)
+	}
+}
+
+func TestCompress_Unsupported(t *testing.T) {
+	_, err := helpers.Compress([]byte("data"), "brotli")
+	assert.Error(t, err)
+	assert.Error(t, helpers.VerifyCompression("brotli"))
+	assert.Nil(t, helpers.VerifyCompression("zstd"))
+}
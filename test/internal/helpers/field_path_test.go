@@ -0,0 +1,115 @@
+package helpers_test
+
+import (
+	"testing"
+
+	helpers "github.com/shoplineapp/captin/v2/internal/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func productFixture() map[string]interface{} {
+	return map[string]interface{}{
+		"title": "foo",
+		"variants": []interface{}{
+			map[string]interface{}{"sku": "sku_1", "price": 100, "stock": 1},
+			map[string]interface{}{"sku": "sku_2", "price": 200, "stock": 0},
+		},
+		"metadata": map[string]interface{}{
+			"order_id":    "order_1",
+			"customer_id": "customer_1",
+			"channel":     "web",
+			"a.b":         "dotted",
+		},
+		"tags": []interface{}{"a", "b", "c"},
+	}
+}
+
+func TestIncludeFields_ArrayWildcard(t *testing.T) {
+	result := helpers.IncludeFields(productFixture(), []string{"variants[*].sku", "variants[*].price"})
+	assert.Equal(t, map[string]interface{}{
+		"variants": []interface{}{
+			map[string]interface{}{"sku": "sku_1", "price": 100},
+			map[string]interface{}{"sku": "sku_2", "price": 200},
+		},
+	}, result)
+
+	// Same as implicit array behaviour
+	assert.Equal(t, result, helpers.IncludeFields(productFixture(), []string{"variants.sku", "variants.price"}))
+}
+
+func TestIncludeFields_ArrayIndex(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"variants": []interface{}{map[string]interface{}{"sku": "sku_2"}},
+		"tags":     []interface{}{"a"},
+	}, helpers.IncludeFields(productFixture(), []string{"variants[1].sku", "tags[0]"}))
+
+	// Index out of range selects nothing
+	assert.Equal(t, map[string]interface{}{
+		"tags": []interface{}{},
+	}, helpers.IncludeFields(productFixture(), []string{"tags[5]"}))
+
+	// Index on non-array value selects nothing
+	assert.Equal(t, map[string]interface{}{}, helpers.IncludeFields(productFixture(), []string{"title[0]"}))
+}
+
+func TestIncludeFields_GlobKeys(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{"order_id": "order_1", "customer_id": "customer_1"},
+	}, helpers.IncludeFields(productFixture(), []string{"metadata.*_id"}))
+
+	assert.Equal(t, map[string]interface{}{
+		"metadata": productFixture()["metadata"],
+	}, helpers.IncludeFields(productFixture(), []string{"metadata.*"}))
+}
+
+func TestIncludeFields_EscapedDot(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{"a.b": "dotted"},
+	}, helpers.IncludeFields(productFixture(), []string{`metadata.a\.b`}))
+}
+
+func TestIncludeFields_MergeOverlappingPaths(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{"order_id": "order_1", "customer_id": "customer_1", "channel": "web"},
+	}, helpers.IncludeFields(productFixture(), []string{"metadata.*_id", "metadata.channel"}))
+}
+
+func TestExcludeFields_ArrayWildcardAndIndex(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"title": "foo",
+		"variants": []interface{}{
+			map[string]interface{}{"sku": "sku_1", "price": 100},
+			map[string]interface{}{"sku": "sku_2"},
+		},
+		"metadata": productFixture()["metadata"],
+		"tags":     []interface{}{"b", "c"},
+	}, helpers.ExcludeFields(productFixture(), []string{"variants[*].stock", "variants[1].price", "tags[0]"}))
+}
+
+func TestExcludeFields_GlobKeys(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"title":    "foo",
+		"variants": productFixture()["variants"],
+		"metadata": map[string]interface{}{"channel": "web", "a.b": "dotted"},
+		"tags":     productFixture()["tags"],
+	}, helpers.ExcludeFields(productFixture(), []string{"metadata.*_id"}))
+}
+
+func TestIncludeFields_DoesNotModifyFields(t *testing.T) {
+	fields := []string{"variants.sku", "title"}
+	helpers.IncludeFields(productFixture(), fields)
+	assert.Equal(t, []string{"variants.sku", "title"}, fields)
+}
+
+func TestVerifyFieldPath(t *testing.T) {
+	assert.Nil(t, helpers.VerifyFieldPath("items[*].sku"))
+	assert.Nil(t, helpers.VerifyFieldPath("items[0][1]"))
+	assert.Nil(t, helpers.VerifyFieldPath(`metadata.*_id`))
+	assert.Nil(t, helpers.VerifyFieldPath(`metadata.a\.b`))
+	assert.Error(t, helpers.VerifyFieldPath(""))
+	assert.Error(t, helpers.VerifyFieldPath("items..sku"))
+	assert.Error(t, helpers.VerifyFieldPath("items[0"))
+	assert.Error(t, helpers.VerifyFieldPath("items[first]"))
+	assert.Error(t, helpers.VerifyFieldPath("items[0]sku"))
+	assert.Error(t, helpers.VerifyFieldPath(`items\`))
+}
@@ -0,0 +1,49 @@
+package helpers_test
+
+import (
+	"testing"
+
+	helpers "github.com/shoplineapp/captin/v2/internal/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFieldValue(t *testing.T) {
+	object := map[string]interface{}{"order": map[string]interface{}{"items": []interface{}{"a", "b"}}, "foo": "bar"}
+
+	value, exists := helpers.GetFieldValue(object, "foo")
+	assert.Equal(t, true, exists)
+	assert.Equal(t, "bar", value)
+
+	value, exists = helpers.GetFieldValue(object, "order.items")
+	assert.Equal(t, true, exists)
+	assert.Equal(t, []interface{}{"a", "b"}, value)
+
+	_, exists = helpers.GetFieldValue(object, "order.missing")
+	assert.Equal(t, false, exists)
+
+	_, exists = helpers.GetFieldValue(object, "foo.bar")
+	assert.Equal(t, false, exists)
+
+	// Keys are parsed as field paths, escaped dots are part of key
+	value, exists = helpers.GetFieldValue(map[string]interface{}{"order.items": "dotted"}, `order\.items`)
+	assert.Equal(t, true, exists)
+	assert.Equal(t, "dotted", value)
+
+	_, exists = helpers.GetFieldValue(object, "order.items[0]")
+	assert.Equal(t, false, exists)
+	assert.Error(t, helpers.VerifyFieldKeyPath("order.items[*]"))
+	assert.Error(t, helpers.VerifyFieldKeyPath("order.*"))
+	assert.Nil(t, helpers.VerifyFieldKeyPath(`order.a\.b`))
+}
+
+func TestSetFieldValue(t *testing.T) {
+	object := map[string]interface{}{"order": map[string]interface{}{"items": []interface{}{"a", "b"}}}
+
+	helpers.SetFieldValue(object, "order.items", "a")
+	helpers.SetFieldValue(object, "customer.name", "foo")
+	helpers.SetFieldValue(object, `customer.a\.b`, "dotted")
+	assert.Equal(t, map[string]interface{}{
+		"order":    map[string]interface{}{"items": "a"},
+		"customer": map[string]interface{}{"name": "foo", "a.b": "dotted"},
+	}, object)
+}
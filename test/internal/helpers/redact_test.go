@@ -0,0 +1,88 @@
+package helpers_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	helpers "github.com/shoplineapp/captin/v2/internal/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	object := map[string]interface{}{
+		"customer": map[string]interface{}{
+			"email":   "john@example.com",
+			"name":    "John",
+			"phone":   "+886912345678",
+			"address": "No. 1, Taipei City",
+			"note":    "secret",
+		},
+		"items": []interface{}{
+			map[string]interface{}{"sku": "sku_1", "owner": "Mary"},
+			map[string]interface{}{"sku": "sku_2", "owner": "Peter"},
+		},
+	}
+	rules := map[string]string{
+		"customer.email":   "mask_email",
+		"customer.name":    "mask",
+		"customer.phone":   "hash",
+		"customer.address": "truncate:5",
+		"customer.note":    "remove",
+		"items.owner":      "mask",
+		"missing.field":    "mask",
+	}
+
+	sum := sha256.Sum256([]byte("salt+886912345678"))
+	result := helpers.Redact(object, rules, "salt")
+	assert.Equal(t, map[string]interface{}{
+		"customer": map[string]interface{}{
+			"email":   "j***@example.com",
+			"name":    "J***",
+			"phone":   hex.EncodeToString(sum[:]),
+			"address": "No. 1",
+		},
+		"items": []interface{}{
+			map[string]interface{}{"sku": "sku_1", "owner": "M***"},
+			map[string]interface{}{"sku": "sku_2", "owner": "P***"},
+		},
+	}, result)
+
+	// org object not modified
+	assert.Equal(t, "john@example.com", object["customer"].(map[string]interface{})["email"])
+
+	// Values are not hashed without salt
+	result = helpers.Redact(object, map[string]string{"customer.phone": "hash"}, "")
+	assert.Equal(t, "***", result["customer"].(map[string]interface{})["phone"])
+}
+
+func TestVerifyRedactStrategy(t *testing.T) {
+	assert.Nil(t, helpers.VerifyRedactStrategy("mask"))
+	assert.Nil(t, helpers.VerifyRedactStrategy("mask_email"))
+	assert.Nil(t, helpers.VerifyRedactStrategy("hash"))
+	assert.Nil(t, helpers.VerifyRedactStrategy("truncate:10"))
+	assert.Nil(t, helpers.VerifyRedactStrategy("remove"))
+	assert.Error(t, helpers.VerifyRedactStrategy("truncate"))
+	assert.Error(t, helpers.VerifyRedactStrategy("encrypt"))
+}
+
+func TestRedact_FieldPaths(t *testing.T) {
+	object := map[string]interface{}{
+		"contacts": []interface{}{
+			map[string]interface{}{"email": "john@example.com"},
+			map[string]interface{}{"email": "mary@example.com"},
+		},
+		"metadata": map[string]interface{}{"home_phone": "0212345678", "work_phone": "0287654321", "channel": "web"},
+	}
+	result := helpers.Redact(object, map[string]string{
+		"contacts[0].email": "mask_email",
+		"contacts[1]":       "remove",
+		"metadata.*_phone":  "truncate:2",
+	}, "")
+	assert.Equal(t, map[string]interface{}{
+		"contacts": []interface{}{
+			map[string]interface{}{"email": "j***@example.com"},
+		},
+		"metadata": map[string]interface{}{"home_phone": "02", "work_phone": "02", "channel": "web"},
+	}, result)
+}
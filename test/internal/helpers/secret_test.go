@@ -0,0 +1,49 @@
+package helpers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	helpers "github.com/shoplineapp/captin/v2/internal/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSecret_Env(t *testing.T) {
+	os.Setenv("TEST_RESOLVE_SECRET", "s3cret")
+	defer os.Unsetenv("TEST_RESOLVE_SECRET")
+
+	value, err := helpers.ResolveSecret("env:TEST_RESOLVE_SECRET")
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cret", value)
+
+	_, err = helpers.ResolveSecret("env:TEST_RESOLVE_SECRET_MISSING")
+	assert.Error(t, err)
+}
+
+func TestResolveSecret_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	ioutil.WriteFile(path, []byte("s3cret\n"), 0600)
+
+	value, err := helpers.ResolveSecret("file:" + path)
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cret", value)
+
+	_, err = helpers.ResolveSecret("file:" + path + ".missing")
+	assert.Error(t, err)
+}
+
+func TestResolveSecret_Unsupported(t *testing.T) {
+	_, err := helpers.ResolveSecret("plain-secret")
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "plain-secret")
+	}
+}
+
+func TestVerifySecretRef(t *testing.T) {
+	assert.Nil(t, helpers.VerifySecretRef("env:SECRET"))
+	assert.Nil(t, helpers.VerifySecretRef("file:/run/secrets/partner"))
+	assert.Error(t, helpers.VerifySecretRef("env:"))
+	assert.Error(t, helpers.VerifySecretRef("plain-secret"))
+}
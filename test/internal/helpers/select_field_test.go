@@ -37,3 +37,10 @@ func TestNestedExcludeFields(t *testing.T) {
   result := helpers.ExcludeFields(object, fields)
   assert.Equal(t, map[string]interface {}{"foo":map[string]interface {}{"deepfoo":"deepbar"}, "foo2":[]interface {}{map[string]interface {}{"arrayfoo1":"arraybar1"}, map[string]interface {}{"arrayfoo2":"arraybar2"}}}, result)
 }
+
+func TestNestedIncludeFields_PrimitiveParent(t *testing.T) {
+  fields := []string{"foo.deepfoo", "foo2.common", "tags.name"}
+  object := map[string]interface{}{"foo": "bar", "foo2": map[string]interface{}{"common": "a", "else": "useless"}, "tags": []interface{}{"a", "b"}}
+  result := helpers.IncludeFields(object, fields)
+  assert.Equal(t, map[string]interface {}{"foo2":map[string]interface {}{"common":"a"}, "tags":[]interface {}{"a", "b"}}, result)
+}
@@ -0,0 +1,42 @@
+package helpers_test
+
+import (
+	"testing"
+
+	helpers "github.com/shoplineapp/captin/v2/internal/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	data := map[string]interface{}{
+		"target_id": "product_id",
+		"payload":   map[string]interface{}{"title": "foo", "tags": []interface{}{"a", "b"}},
+	}
+
+	result, err := helpers.RenderTemplate(`{"id": {{ json .target_id }}, "name": {{ json (upper .payload.title) }}, "tags": "{{ join "," .payload.tags }}", "price": {{ default 0 .payload.price }}}`, data)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id": "product_id", "name": "FOO", "tags": "a,b", "price": 0}`, result)
+
+	_, err = helpers.RenderTemplate(`{{ .target_id `, data)
+	assert.Error(t, err)
+}
+
+func TestCompileTemplate_Reused(t *testing.T) {
+	first, err := helpers.CompileTemplate(`{{ .target_id }}`)
+	assert.Nil(t, err)
+	second, _ := helpers.CompileTemplate(`{{ .target_id }}`)
+	assert.Same(t, first, second)
+}
+
+func TestRenderHTMLTemplate(t *testing.T) {
+	data := map[string]interface{}{
+		"payload": map[string]interface{}{"title": "<b>foo</b>"},
+	}
+
+	result, err := helpers.RenderHTMLTemplate(`<p>{{ upper .payload.title }}</p>`, data)
+	assert.Nil(t, err)
+	assert.Equal(t, `<p>&lt;B&gt;FOO&lt;/B&gt;</p>`, result)
+
+	_, err = helpers.CompileHTMLTemplate(`<p>{{ .payload.title </p>`)
+	assert.Error(t, err)
+}
@@ -10,6 +10,8 @@ import (
 	"testing"
 	"time"
 
+	destination_filters "github.com/shoplineapp/captin/v2/destinations/filters"
+	captin_dispatcher "github.com/shoplineapp/captin/v2/dispatcher"
 	delayers "github.com/shoplineapp/captin/v2/dispatcher/delayers"
 	captin_errors "github.com/shoplineapp/captin/v2/errors"
 	interfaces "github.com/shoplineapp/captin/v2/interfaces"
@@ -17,6 +19,8 @@ import (
 	stores "github.com/shoplineapp/captin/v2/internal/stores"
 	models "github.com/shoplineapp/captin/v2/models"
 	mocks "github.com/shoplineapp/captin/v2/test/mocks"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -682,3 +686,458 @@ func TestDispatchEvents_DelaySend_NotExist(t *testing.T) {
 	assert.IsType(t, &captin_errors.UnretryableError{}, dispatcher.GetErrors()[0])
 	sender.AssertNumberOfCalls(t, "SendEvent", 0)
 }
+
+func TestDispatchEvents_OutsideDeliveryWindow_HoldEvent(t *testing.T) {
+	_, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+	store := stores.NewMemoryStore()
+
+	// Blackout for the whole day, event is held until tomorrow
+	today := time.Now().UTC().Format("2006-01-02")
+	destinations := []models.Destination{{Config: models.Configuration{
+		Name:              "service_one",
+		Sender:            "mock",
+		DeliveryBlackouts: []string{fmt.Sprintf("%s 00:00-24:00", today)},
+	}}}
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	pendingJobs := captin_dispatcher.PendingJobCount()
+	dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"field1": 1},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	heldEvents, exists, _, _ := store.GetQueue(context.Background(), "product.update.service_one.product_id-held_events")
+	assert.Equal(t, true, exists)
+	assert.Equal(t, 1, len(heldEvents))
+	sender.AssertNumberOfCalls(t, "SendEvent", 0)
+	throttler.AssertNumberOfCalls(t, "CanTrigger", 0)
+	// Held events do not keep shutdown waiting
+	assert.LessOrEqual(t, captin_dispatcher.PendingJobCount(), pendingJobs)
+}
+
+func TestDispatchEvents_LongBlackout_HoldEvent(t *testing.T) {
+	_, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+	store := stores.NewMemoryStore()
+
+	// Blackout beyond look ahead of delivery window, event is held instead of being sent
+	blackouts := []string{}
+	for day := 0; day < 20; day++ {
+		blackouts = append(blackouts, time.Now().UTC().AddDate(0, 0, day).Format("2006-01-02")+" 00:00-24:00")
+	}
+	destinations := []models.Destination{{Config: models.Configuration{
+		Name:              "service_frozen",
+		Sender:            "mock",
+		DeliveryTimezone:  "UTC",
+		DeliveryBlackouts: blackouts,
+	}}}
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	heldEvents, _, _, _ := store.GetQueue(context.Background(), "product.update.service_frozen.product_id-held_events")
+	assert.Equal(t, 1, len(heldEvents))
+	sender.AssertNumberOfCalls(t, "SendEvent", 0)
+}
+
+func TestDispatchEvents_RestoreHeldEvents(t *testing.T) {
+	_, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+	store := stores.NewMemoryStore()
+
+	today := time.Now().UTC().Format("2006-01-02")
+	held := models.Configuration{
+		Name:              "service_restore",
+		Actions:           []string{"product.update"},
+		Sender:            "mock",
+		DeliveryBlackouts: []string{fmt.Sprintf("%s 00:00-24:00", today)},
+	}
+	senderMapping := map[string]interfaces.EventSenderInterface{"mock": sender}
+	dispatcher := outgoing.NewDispatcherWithDestinations([]models.Destination{{Config: held}}, senderMapping)
+
+	// Events of the same target are held in one queue
+	for i := 0; i < 5; i++ {
+		go dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+			Key:        "product.update",
+			Source:     "core",
+			Payload:    map[string]interface{}{"field1": i},
+			TargetType: "Product",
+			TargetId:   "product_id",
+		}, store, throttler, documentStores)
+	}
+	queueKey := "product.update.service_restore.product_id-held_events"
+	assert.Eventually(t, func() bool {
+		heldEvents, _, _, _ := store.GetQueue(context.Background(), queueKey)
+		return len(heldEvents) == 5
+	}, time.Second, 10*time.Millisecond)
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+	// Release is detached from context of restoring
+	detached := mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil })
+	sender.On("SendEvent", detached, mock.Anything, mock.Anything).Return(nil)
+
+	// Blackout is removed on restart, events are released through throttle
+	released := held
+	released.DeliveryBlackouts = nil
+	configMap := models.NewConfigurationMapper([]interfaces.ConfigurationInterface{released})
+	restorer := outgoing.NewDispatcherWithDestinations([]models.Destination{}, senderMapping)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	restored, err := restorer.RestoreHeldEvents(ctx, configMap, store, throttler, documentStores)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, restored)
+
+	time.Sleep(100 * time.Millisecond)
+	_, exists, _, _ := store.GetQueue(context.Background(), queueKey)
+	assert.False(t, exists)
+	sender.AssertNumberOfCalls(t, "SendEvent", 5)
+	throttler.AssertNumberOfCalls(t, "CanTrigger", 5)
+	assert.EqualValues(t, 0, captin_dispatcher.HeldEventCounts()["service_restore"])
+}
+
+func TestDispatchEvents_ChangeDetectionFilter(t *testing.T) {
+	_, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+	store := stores.NewMemoryStore()
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	destinations := []models.Destination{{Config: models.Configuration{
+		Name:              "service_one",
+		Sender:            "mock",
+		WatchPayloadAttrs: []string{"price"},
+	}}}
+	dispatch := func(payload map[string]interface{}) {
+		dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+		dispatcher.SetFilters([]destination_filters.DestinationFilterInterface{destination_filters.ChangeDetectionFilter{Store: store}})
+		dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+			Key:        "product.update",
+			Source:     "core",
+			Payload:    payload,
+			TargetType: "Product",
+			TargetId:   "product_id",
+		}, store, throttler, documentStores)
+	}
+
+	dispatch(map[string]interface{}{"price": 100, "title": "foo"})
+	dispatch(map[string]interface{}{"price": 100, "title": "bar"})
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+
+	dispatch(map[string]interface{}{"price": 200, "title": "bar"})
+	sender.AssertNumberOfCalls(t, "SendEvent", 2)
+}
+
+func TestDispatchEvents_ChangeDetectionFilter_Failed(t *testing.T) {
+	_, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+	store := stores.NewMemoryStore()
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("Mock Error")).Once()
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	destinations := []models.Destination{{Config: models.Configuration{
+		Name:              "service_one",
+		Sender:            "mock",
+		WatchPayloadAttrs: []string{"price"},
+	}}}
+	dispatch := func() {
+		dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+		dispatcher.SetFilters([]destination_filters.DestinationFilterInterface{destination_filters.ChangeDetectionFilter{Store: store}})
+		dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+			Key:        "product.update",
+			Source:     "core",
+			Payload:    map[string]interface{}{"price": 100},
+			TargetType: "Product",
+			TargetId:   "product_id",
+		}, store, throttler, documentStores)
+	}
+
+	// Hash claimed by failed event is released, so that retry is delivered
+	dispatch()
+	dispatch()
+	sender.AssertNumberOfCalls(t, "SendEvent", 2)
+
+	dispatch()
+	sender.AssertNumberOfCalls(t, "SendEvent", 2)
+}
+
+func TestDispatchEvents_ChangeDetectionFilter_RedactAndTransform(t *testing.T) {
+	_, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+	store := stores.NewMemoryStore()
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	// Watched field is redacted and payload is transformed before sending
+	destinations := []models.Destination{{Config: models.Configuration{
+		Name:              "service_one",
+		Sender:            "mock",
+		WatchPayloadAttrs: []string{"price", "email"},
+		RedactRules:       map[string]string{"email": "mask_email"},
+		Transform:         `{"id": {{ json .target_id }}}`,
+	}}}
+	dispatch := func(payload map[string]interface{}) {
+		dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+		dispatcher.SetFilters([]destination_filters.DestinationFilterInterface{destination_filters.ChangeDetectionFilter{Store: store}})
+		dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+			Key:        "product.update",
+			Source:     "core",
+			Payload:    payload,
+			TargetType: "Product",
+			TargetId:   "product_id",
+		}, store, throttler, documentStores)
+	}
+
+	dispatch(map[string]interface{}{"price": 100, "email": "foo@example.com"})
+	dispatch(map[string]interface{}{"price": 100, "email": "foo@example.com"})
+	sender.AssertNumberOfCalls(t, "SendEvent", 1)
+
+	dispatch(map[string]interface{}{"price": 100, "email": "bar@example.com"})
+	sender.AssertNumberOfCalls(t, "SendEvent", 2)
+}
+
+func TestDispatchEvents_SplitOn(t *testing.T) {
+	store, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	destinations := []models.Destination{
+		{Config: models.Configuration{Name: "per_item", Sender: "mock", SplitOn: "order.items", SplitTargetIdField: "sku"}},
+		{Config: models.Configuration{Name: "whole_order", Sender: "mock"}},
+	}
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+	dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+		TraceId:    "parent_trace",
+		Key:        "order.update",
+		Source:     "core",
+		TargetType: "Order",
+		TargetId:   "order_id",
+		Payload: map[string]interface{}{
+			"order": map[string]interface{}{
+				"status": "paid",
+				"items": []interface{}{
+					map[string]interface{}{"sku": "sku_1"},
+					map[string]interface{}{"sku": "sku_2"},
+				},
+			},
+		},
+	}, store, throttler, documentStores)
+
+	sender.AssertNumberOfCalls(t, "SendEvent", 3)
+	for i, sku := range []string{"sku_1", "sku_2"} {
+		index, sku := i, sku
+		sender.AssertCalled(t, "SendEvent", mock.Anything, mock.MatchedBy(func(e models.IncomingEvent) bool {
+			return e.TargetId == sku &&
+				e.TraceId == fmt.Sprintf("parent_trace-%d", index) &&
+				e.Control["parent_trace_id"] == "parent_trace" &&
+				reflect.DeepEqual(e.Payload, map[string]interface{}{
+					"order": map[string]interface{}{"status": "paid", "items": map[string]interface{}{"sku": sku}},
+				})
+		}), mock.Anything)
+	}
+	sender.AssertCalled(t, "SendEvent", mock.Anything, mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return e.TargetId == "order_id" && e.TraceId == "parent_trace"
+	}), mock.Anything)
+
+	// Each split event has its own throttle key
+	throttler.AssertCalled(t, "CanTrigger", mock.Anything, "order.update.per_item.sku_1", mock.Anything)
+	throttler.AssertCalled(t, "CanTrigger", mock.Anything, "order.update.per_item.sku_2", mock.Anything)
+	throttler.AssertCalled(t, "CanTrigger", mock.Anything, "order.update.whole_order.order_id", mock.Anything)
+}
+
+func TestDispatchEvents_SplitOn_DocumentAndThrottle(t *testing.T) {
+	store, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+	mockDocumentStore := new(mocks.DocumentStoreMock)
+	documentStores["default"] = mockDocumentStore
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockDocumentStore.On("GetDocument", mock.Anything, mock.Anything).Return(map[string]interface{}{"status": "paid"})
+
+	destinations := []models.Destination{
+		{Config: models.Configuration{Name: "per_item", Sender: "mock", SplitOn: "order.items", SplitTargetIdField: "sku", IncludeDocument: true}},
+	}
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+	dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+		TraceId:    "parent_trace",
+		Key:        "order.update",
+		Source:     "core",
+		TargetType: "Order",
+		TargetId:   "order_id",
+		Payload: map[string]interface{}{
+			"order": map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"sku": "sku_1"},
+					map[string]interface{}{"name": "item without sku"},
+				},
+			},
+		},
+	}, store, throttler, documentStores)
+
+	sender.AssertNumberOfCalls(t, "SendEvent", 2)
+
+	// Document of the parent order is loaded once for all split events
+	mockDocumentStore.AssertNumberOfCalls(t, "GetDocument", 1)
+	mockDocumentStore.AssertCalled(t, "GetDocument", mock.Anything, mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return e.TargetType == "Order" && e.TargetId == "order_id"
+	}))
+
+	// Split event without target ID of its own is throttled by split index
+	throttler.AssertCalled(t, "CanTrigger", mock.Anything, "order.update.per_item.sku_1", mock.Anything)
+	throttler.AssertCalled(t, "CanTrigger", mock.Anything, "order.update.per_item.order_id.1", mock.Anything)
+}
+
+func TestDispatchEvents_Transform(t *testing.T) {
+	store, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	destinations := []models.Destination{{Config: models.Configuration{
+		Name:      "partner",
+		Sender:    "mock",
+		Transform: `{"data": {"external_id": {{ json .target_id }}, "name": {{ json .payload.title }}, "hook": {{ json .hook }}}, "version": 2}`,
+	}}}
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+	dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"title": "foo"},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	assert.Equal(t, 0, len(dispatcher.GetErrors()))
+	sender.AssertCalled(t, "SendEvent", mock.Anything, mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return reflect.DeepEqual(e.Payload, map[string]interface{}{
+			"data":    map[string]interface{}{"external_id": "product_id", "name": "foo", "hook": "partner"},
+			"version": float64(2),
+		})
+	}), mock.Anything)
+}
+
+func TestDispatchEvents_Transform_Error(t *testing.T) {
+	store, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	destinations := []models.Destination{{Config: models.Configuration{
+		Name:      "partner",
+		Sender:    "mock",
+		Transform: `not a json {{ .target_id }}`,
+	}}}
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+	dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"title": "foo"},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	errors := dispatcher.GetErrors()
+	if assert.Equal(t, 1, len(errors)) {
+		assert.IsType(t, &captin_errors.UnretryableError{}, errors[0])
+	}
+	sender.AssertNumberOfCalls(t, "SendEvent", 0)
+}
+
+func TestDispatchEvents_RedactLogs(t *testing.T) {
+	store, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	level := log.GetLevel()
+	log.SetLevel(log.DebugLevel)
+	defer log.SetLevel(level)
+
+	// Throttle and sender failures log the event before and after redaction
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(false, time.Duration(0), errors.New("throttle unavailable"))
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+
+	destinations := []models.Destination{{Config: models.Configuration{
+		Name:        "partner",
+		Sender:      "mock",
+		RedactRules: map[string]string{"email": "remove"},
+		RedactLogs:  true,
+	}}}
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+	dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"email": "john@example.com", "title": "foo"},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	assert.Equal(t, 1, len(dispatcher.GetErrors()))
+	assert.NotEmpty(t, hook.AllEntries())
+	for _, entry := range hook.AllEntries() {
+		assert.NotContains(t, fmt.Sprintf("%#v", entry.Data), "john@example.com", entry.Message)
+	}
+}
+
+func TestDispatchEvents_RedactRules(t *testing.T) {
+	store, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+	mockDocumentStore := new(mocks.DocumentStoreMock)
+	documentStores["default"] = mockDocumentStore
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockDocumentStore.On("GetDocument", mock.Anything, mock.Anything).Return(map[string]interface{}{"phone": "0912345678", "id": "user_id"})
+
+	destinations := []models.Destination{{Config: models.Configuration{
+		Name:            "partner",
+		Sender:          "mock",
+		IncludeDocument: true,
+		RedactRules:     map[string]string{"email": "mask_email", "phone": "remove"},
+	}}}
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+	dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+		Key:        "user.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"email": "john@example.com"},
+		TargetType: "User",
+		TargetId:   "user_id",
+	}, store, throttler, documentStores)
+
+	sender.AssertCalled(t, "SendEvent", mock.Anything, mock.MatchedBy(func(e models.IncomingEvent) bool {
+		return reflect.DeepEqual(e.Payload, map[string]interface{}{"email": "j***@example.com"}) &&
+			reflect.DeepEqual(e.TargetDocument, map[string]interface{}{"id": "user_id"})
+	}), mock.Anything)
+}
+
+func TestDispatchEvents_SendEvent_KeepSenderErrors(t *testing.T) {
+	store, documentStores, sender, _, throttler := setup("fixtures/config.single.json")
+
+	throttler.On("CanTrigger", mock.Anything, mock.Anything, mock.Anything).Return(true, time.Duration(0), nil)
+	response := &captin_errors.HTTPResponse{StatusCode: 503, Body: "unavailable"}
+	sender.On("SendEvent", mock.Anything, mock.Anything, mock.Anything).Return(&captin_errors.DispatcherError{Msg: "Destination responded with status 503", Response: response})
+
+	destinations := []models.Destination{{Config: models.Configuration{Name: "partner", Sender: "mock"}}}
+	dispatcher := outgoing.NewDispatcherWithDestinations(destinations, map[string]interfaces.EventSenderInterface{"mock": sender})
+	dispatcher.Dispatch(context.Background(), models.IncomingEvent{
+		Key:        "product.update",
+		Source:     "core",
+		Payload:    map[string]interface{}{"title": "foo"},
+		TargetType: "Product",
+		TargetId:   "product_id",
+	}, store, throttler, documentStores)
+
+	errors := dispatcher.GetErrors()
+	if assert.Equal(t, 1, len(errors)) {
+		assert.Equal(t, response, errors[0].(*captin_errors.DispatcherError).Response)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	helpers "github.com/shoplineapp/captin/v2/internal/helpers"
 	stores "github.com/shoplineapp/captin/v2/internal/stores"
 	"github.com/stretchr/testify/assert"
 )
@@ -22,3 +23,36 @@ func TestStoreWithTTL(t *testing.T) {
 	time.Sleep(300 * time.Millisecond)
 	assert.Equal(t, 0, ms.Len())
 }
+
+func TestStoreKeys(t *testing.T) {
+	ms := stores.NewMemoryStore()
+	ms.Set(context.Background(), "product.update.service_one.p1-data", "{}", time.Minute)
+	ms.Enqueue(context.Background(), "product.update.service_one.p1-held_events", "{}", time.Minute)
+	ms.Enqueue(context.Background(), "product.update.service_two.p2-held_events", "{}", time.Minute)
+
+	keys, err := ms.Keys(context.Background(), "-held_events")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"product.update.service_one.p1-held_events", "product.update.service_two.p2-held_events"}, keys)
+}
+
+func TestStoreCompareAndSet(t *testing.T) {
+	ms := stores.NewMemoryStore()
+	ctx := context.Background()
+
+	// Empty old means key should not exist
+	assert.Equal(t, true, helpers.Tuples(ms.CompareAndSet(ctx, "key", "", "a", time.Minute))[0])
+	assert.Equal(t, false, helpers.Tuples(ms.CompareAndSet(ctx, "key", "", "b", time.Minute))[0])
+	assert.Equal(t, false, helpers.Tuples(ms.CompareAndSet(ctx, "key", "b", "c", time.Minute))[0])
+	assert.Equal(t, true, helpers.Tuples(ms.CompareAndSet(ctx, "key", "a", "c", time.Minute))[0])
+	value, _, _, _ := ms.Get(ctx, "key")
+	assert.Equal(t, "c", value)
+
+	// Empty value removes key
+	assert.Equal(t, true, helpers.Tuples(ms.CompareAndSet(ctx, "key", "c", "", 0))[0])
+	_, exists, _, _ := ms.Get(ctx, "key")
+	assert.False(t, exists)
+
+	// Queue is never the expected value
+	ms.Enqueue(ctx, "queue", "{}", time.Minute)
+	assert.Equal(t, false, helpers.Tuples(ms.CompareAndSet(ctx, "queue", "", "a", time.Minute))[0])
+}
@@ -95,3 +95,10 @@ func TestConfigsForKey(t *testing.T) {
 	assert.Contains(t, names, "0")
 	assert.Contains(t, names, "1")
 }
+
+func TestReadLocalFile_InvalidConfig(t *testing.T) {
+	pwd, _ := os.Getwd()
+	absPath := filepath.Join(pwd, "fixtures/config_invalid_transform.json")
+
+	assert.Panics(t, func() { NewConfigurationMapperFromPath(absPath) })
+}
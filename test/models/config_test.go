@@ -3,6 +3,7 @@ package models_test
 import (
 	"encoding/json"
 	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
@@ -65,3 +66,211 @@ func TestConfiguration_GetDocumentStore(t *testing.T) {
 	subject.DocumentStore = "another"
 	assert.Equal(t, subject.DocumentStore, "another")
 }
+
+func TestConfiguration_Verify(t *testing.T) {
+	subject := Configuration{Name: "valid", Transform: `{"id": {{ json .target_id }}}`, DeliveryWindows: []string{"mon-fri 09:00-18:00"}}
+	assert.Nil(t, subject.Verify())
+
+	subject = Configuration{Name: "invalid_transform", Transform: `{"id": {{ .target_id }`}
+	assert.Error(t, subject.Verify())
+
+	subject = Configuration{Name: "invalid_delivery_window", DeliveryWindows: []string{"mon-fri"}}
+	assert.Error(t, subject.Verify())
+
+	// Windows which never open again are rejected, long dated blackouts are not
+	subject = Configuration{Name: "always_blackout", DeliveryBlackouts: []string{"* 00:00-24:00"}}
+	assert.Error(t, subject.Verify())
+	subject = Configuration{Name: "past_window", DeliveryWindows: []string{"2020-12-25 09:00-18:00"}}
+	assert.Error(t, subject.Verify())
+	blackouts := []string{}
+	for day := 0; day < 20; day++ {
+		blackouts = append(blackouts, time.Now().UTC().AddDate(0, 0, day).Format("2006-01-02")+" 00:00-24:00")
+	}
+	subject = Configuration{Name: "long_blackout", DeliveryTimezone: "UTC", DeliveryBlackouts: blackouts}
+	assert.Nil(t, subject.Verify())
+}
+
+func TestConfiguration_Verify_AttributePaths(t *testing.T) {
+	subject := Configuration{Name: "valid_paths", IncludePayloadAttrs: []string{"items[*].sku", `metadata.*_id`}}
+	assert.Nil(t, subject.Verify())
+
+	subject = Configuration{Name: "invalid_paths", ExcludeDocumentAttrs: []string{"items[0"}}
+	assert.Error(t, subject.Verify())
+
+	subject = Configuration{Name: "invalid_redact_path", RedactRules: map[string]string{"items[x]": "mask"}}
+	assert.Error(t, subject.Verify())
+}
+
+func TestConfiguration_Verify_RedactSalt(t *testing.T) {
+	subject := Configuration{Name: "hashed", RedactRules: map[string]string{"customer.phone": "hash"}}
+	assert.Error(t, subject.Verify())
+
+	os.Setenv("HOOK_HASHED_REDACT_SALT", "salt")
+	defer os.Unsetenv("HOOK_HASHED_REDACT_SALT")
+	assert.Nil(t, subject.Verify())
+}
+
+func TestConfiguration_Verify_Split(t *testing.T) {
+	assert.Nil(t, Configuration{Name: "per_item", SplitOn: "order.items", SplitTargetIdField: "sku"}.Verify())
+	assert.Error(t, Configuration{Name: "per_item", SplitOn: "order.items"}.Verify())
+	assert.Error(t, Configuration{Name: "per_item", SplitOn: "order.items[0", SplitTargetIdField: "sku"}.Verify())
+	assert.Nil(t, Configuration{Name: "per_item", SplitOn: `order\.items`, SplitTargetIdField: "sku"}.Verify())
+	// Split fields are single fields, indexes and globs are rejected
+	assert.Error(t, Configuration{Name: "per_item", SplitOn: "orders[*].items", SplitTargetIdField: "sku"}.Verify())
+	assert.Error(t, Configuration{Name: "per_item", SplitOn: "order.items[0]", SplitTargetIdField: "sku"}.Verify())
+	assert.Error(t, Configuration{Name: "per_item", SplitOn: "order.items", SplitTargetIdField: "*_id"}.Verify())
+}
+
+func TestConfiguration_Verify_HTTPSuccessCodes(t *testing.T) {
+	subject := Configuration{Name: "valid_codes", HTTPSuccessCodes: []int{200, 409}}
+	assert.Nil(t, subject.Verify())
+
+	subject = Configuration{Name: "invalid_codes", HTTPSuccessCodes: []int{200, 42}}
+	assert.Error(t, subject.Verify())
+}
+
+func TestConfiguration_Verify_Signing(t *testing.T) {
+	subject := Configuration{Name: "signing", SigningSecrets: []string{"env:SECRET", "file:/run/secrets/next"}, SigningScheme: SigningSchemeHMAC}
+	assert.Nil(t, subject.Verify())
+
+	subject = Configuration{Name: "signing", SigningSecrets: []string{"env:A", "env:B", "env:C"}}
+	assert.Error(t, subject.Verify())
+
+	subject = Configuration{Name: "signing", SigningSecrets: []string{"plain-secret"}}
+	assert.Error(t, subject.Verify())
+
+	subject = Configuration{Name: "signing", SigningScheme: "md5"}
+	assert.Error(t, subject.Verify())
+}
+
+func TestConfiguration_Verify_HTTPAuth(t *testing.T) {
+	subject := Configuration{Name: "auth", HTTPAuth: HTTPAuthBearer, HTTPAuthSecret: "env:TOKEN", HTTPHeaders: map[string]string{"X-Target": "{{ .target_id }}"}}
+	assert.Nil(t, subject.Verify())
+
+	subject = Configuration{Name: "auth", HTTPAuth: HTTPAuthBearer, HTTPAuthSecret: "plain-token"}
+	assert.Error(t, subject.Verify())
+
+	subject = Configuration{Name: "auth", HTTPAuth: HTTPAuthBasic, HTTPAuthSecret: "env:PASSWORD"}
+	assert.Error(t, subject.Verify())
+
+	subject = Configuration{Name: "auth", HTTPAuth: HTTPAuthOAuth2, HTTPAuthSecret: "env:CLIENT_SECRET"}
+	assert.Error(t, subject.Verify())
+
+	subject = Configuration{Name: "auth", HTTPAuth: "digest", HTTPAuthSecret: "env:PASSWORD"}
+	assert.Error(t, subject.Verify())
+
+	subject = Configuration{Name: "auth", HTTPHeaders: map[string]string{"X-Target": "{{ .target_id"}}
+	assert.Error(t, subject.Verify())
+}
+
+func TestConfiguration_Verify_HTTPRequest(t *testing.T) {
+	subject := Configuration{Name: "request", HTTPMethod: "patch", HTTPEncoding: HTTPEncodingForm, HTTPBody: HTTPBodyPayload}
+	assert.Nil(t, subject.Verify())
+	assert.Equal(t, "PATCH", subject.GetHTTPMethod())
+
+	assert.Error(t, Configuration{Name: "request", HTTPMethod: "TRACE"}.Verify())
+	assert.Error(t, Configuration{Name: "request", HTTPEncoding: "yaml"}.Verify())
+	assert.Error(t, Configuration{Name: "request", HTTPBody: "document"}.Verify())
+}
+
+func TestConfiguration_Verify_Compression(t *testing.T) {
+	assert.Nil(t, Configuration{Name: "compression", Compression: "zstd"}.Verify())
+	assert.Error(t, Configuration{Name: "compression", Compression: "brotli"}.Verify())
+	assert.Equal(t, 1024, Configuration{}.GetCompressionThreshold())
+}
+
+func TestConfiguration_Verify_MessageIDs(t *testing.T) {
+	assert.Nil(t, Configuration{Name: "fifo", MessageGroupID: "{{ .target_id }}", DeduplicationID: "{{ .trace_id }}-{{ .hook }}"}.Verify())
+	assert.Error(t, Configuration{Name: "fifo", MessageGroupID: "{{ .target_id"}.Verify())
+	assert.Nil(t, Configuration{Name: "delayed", Sender: SenderSqs, Delay: "15m", SqsNativeDelay: true}.Verify())
+	assert.Error(t, Configuration{Name: "delayed", Sender: SenderSqs, Delay: "16m", SqsNativeDelay: true}.Verify())
+	// Native delay is only supported by SQS standard queues
+	assert.Error(t, Configuration{Name: "delayed", Sender: "sns", Delay: "15m", SqsNativeDelay: true}.Verify())
+	assert.Error(t, Configuration{Name: "delayed", Sender: SenderSqs, Delay: "15m", SqsNativeDelay: true, MessageGroupID: "{{ .target_id }}"}.Verify())
+	assert.False(t, Configuration{Name: "delayed", Sender: "sns", SqsNativeDelay: true}.GetSqsNativeDelay())
+}
+
+func TestConfiguration_Verify_Kafka(t *testing.T) {
+	assert.Nil(t, Configuration{Name: "kafka", KafkaPartitionKey: "{{ .target_type }}-{{ .target_id }}", KafkaAcks: KafkaAcksLeader}.Verify())
+	assert.Nil(t, Configuration{Name: "kafka", KafkaIdempotent: true}.Verify())
+	assert.Error(t, Configuration{Name: "kafka", KafkaPartitionKey: "{{ .target_id"}.Verify())
+	assert.Error(t, Configuration{Name: "kafka", KafkaAcks: "some"}.Verify())
+	assert.Error(t, Configuration{Name: "kafka", KafkaAcks: KafkaAcksNone, KafkaIdempotent: true}.Verify())
+
+	config := Configuration{CallbackURL: "events"}
+	assert.Equal(t, "events", config.GetKafkaTopic())
+	assert.Equal(t, DefaultKafkaPartitionKey, config.GetKafkaPartitionKey())
+	assert.Equal(t, KafkaAcksAll, config.GetKafkaAcks())
+}
+
+func TestConfiguration_Verify_Nats(t *testing.T) {
+	assert.Nil(t, Configuration{Name: "nats", NatsSubject: "captin.{{ .event_key }}"}.Verify())
+	assert.Error(t, Configuration{Name: "nats", NatsSubject: "captin.{{ .event_key"}.Verify())
+
+	config := Configuration{CallbackURL: "captin.events"}
+	assert.Equal(t, "captin.events", config.GetNatsSubject())
+	assert.Equal(t, 5*time.Second, config.GetNatsAckTimeoutValue())
+}
+
+func TestConfiguration_Verify_Amqp(t *testing.T) {
+	assert.Nil(t, Configuration{Name: "amqp", AmqpRoutingKey: "{{ .event_key }}", AmqpHeaders: map[string]string{"x-hook": "{{ .hook }}"}}.Verify())
+	assert.Error(t, Configuration{Name: "amqp", AmqpRoutingKey: "{{ .event_key"}.Verify())
+	assert.Error(t, Configuration{Name: "amqp", AmqpHeaders: map[string]string{"x-hook": "{{ .hook"}}.Verify())
+	assert.Error(t, Configuration{Name: "amqp", AmqpDeliveryMode: "some"}.Verify())
+	assert.Equal(t, AmqpDeliveryPersistent, Configuration{}.GetAmqpDeliveryMode())
+}
+
+func TestConfiguration_Verify_RedisStream(t *testing.T) {
+	assert.Nil(t, Configuration{Name: "stream", RedisStream: "captin:{{ .hook }}", RedisStreamMaxLen: 1000}.Verify())
+	assert.Error(t, Configuration{Name: "stream", RedisStream: "captin:{{ .hook"}.Verify())
+	assert.Error(t, Configuration{Name: "stream", RedisStreamMaxLen: -1}.Verify())
+	assert.Equal(t, DefaultRedisStream, Configuration{}.GetRedisStream())
+}
+
+func TestConfiguration_Verify_PushChannel(t *testing.T) {
+	assert.Nil(t, Configuration{Name: "dashboard", PushChannel: "merchant:{{ .control.merchant_id }}"}.Verify())
+	assert.Error(t, Configuration{Name: "dashboard", PushChannel: "merchant:{{ .control.merchant_id"}.Verify())
+	assert.Equal(t, DefaultPushChannel, Configuration{}.GetPushChannel())
+}
+
+func TestConfiguration_Verify_Chat(t *testing.T) {
+	assert.Nil(t, Configuration{Name: "ops", ChatFormat: ChatFormatTeams, ChatTemplate: "Refund of order {{ .target_id }} failed"}.Verify())
+	assert.Error(t, Configuration{Name: "ops", ChatTemplate: "Refund of order {{ .target_id"}.Verify())
+	assert.Error(t, Configuration{Name: "ops", ChatFormat: "irc"}.Verify())
+	assert.Equal(t, ChatFormatSlack, Configuration{}.GetChatFormat())
+	assert.Equal(t, DefaultChatTemplate, Configuration{}.GetChatTemplate())
+	assert.Equal(t, time.Second, Configuration{}.GetChatMinIntervalValue())
+}
+
+func TestConfiguration_Verify_SMTP(t *testing.T) {
+	config := Configuration{
+		Name:         "partner_refunds",
+		SMTPFrom:     "Captin <captin@example.com>",
+		SMTPTo:       "{{ .payload.email }}",
+		SMTPHTMLBody: "<p>{{ .payload.reason }}</p>",
+		SMTPUsername: "captin",
+		SMTPPassword: "env:SMTP_PASSWORD",
+		SMTPTLS:      SMTPTLSImplicit,
+	}
+	assert.Nil(t, config.Verify())
+
+	invalid := config
+	invalid.SMTPTo = "{{ .payload.email"
+	assert.Error(t, invalid.Verify())
+	invalid = config
+	invalid.SMTPHTMLBody = "<p>{{ .payload.reason </p>"
+	assert.Error(t, invalid.Verify())
+	invalid = config
+	invalid.SMTPFrom = "not an address"
+	assert.Error(t, invalid.Verify())
+	invalid = config
+	invalid.SMTPTLS = "ssl"
+	assert.Error(t, invalid.Verify())
+	invalid = config
+	invalid.SMTPPassword = "smtp-secret"
+	assert.Error(t, invalid.Verify())
+
+	assert.Equal(t, SMTPTLSStartTLS, Configuration{}.GetSMTPTLS())
+	assert.Equal(t, DefaultSMTPSubject, Configuration{}.GetSMTPSubject())
+	assert.Equal(t, 30*time.Second, Configuration{}.GetSMTPTimeoutValue())
+}
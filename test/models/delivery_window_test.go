@@ -0,0 +1,153 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/shoplineapp/captin/v2/models"
+)
+
+func TestDeliveryWindow_IsOpen(t *testing.T) {
+	window, err := NewDeliveryWindow("Asia/Taipei", []string{"mon-fri 09:00-18:00"}, []string{"wed 12:00-13:00", "2020-12-25 00:00-24:00"})
+	assert.Nil(t, err)
+
+	taipei, _ := time.LoadLocation("Asia/Taipei")
+	// 2020-12-21 is a Monday
+	assert.Equal(t, true, window.IsOpen(time.Date(2020, 12, 21, 9, 0, 0, 0, taipei)))
+	assert.Equal(t, false, window.IsOpen(time.Date(2020, 12, 21, 8, 59, 0, 0, taipei)))
+	assert.Equal(t, false, window.IsOpen(time.Date(2020, 12, 21, 18, 0, 0, 0, taipei)))
+	assert.Equal(t, false, window.IsOpen(time.Date(2020, 12, 23, 12, 30, 0, 0, taipei)))
+	assert.Equal(t, false, window.IsOpen(time.Date(2020, 12, 25, 10, 0, 0, 0, taipei)))
+	assert.Equal(t, false, window.IsOpen(time.Date(2020, 12, 26, 10, 0, 0, 0, taipei)))
+
+	// Time is converted into window timezone
+	assert.Equal(t, true, window.IsOpen(time.Date(2020, 12, 21, 1, 0, 0, 0, time.UTC)))
+}
+
+func TestDeliveryWindow_IsOpen_WrapMidnight(t *testing.T) {
+	window, err := NewDeliveryWindow("", nil, []string{"fri 22:00-02:00"})
+	assert.Nil(t, err)
+
+	// 2020-12-25 is a Friday
+	assert.Equal(t, true, window.IsOpen(time.Date(2020, 12, 25, 21, 59, 0, 0, time.UTC)))
+	assert.Equal(t, false, window.IsOpen(time.Date(2020, 12, 25, 23, 0, 0, 0, time.UTC)))
+	assert.Equal(t, false, window.IsOpen(time.Date(2020, 12, 26, 1, 59, 0, 0, time.UTC)))
+	assert.Equal(t, true, window.IsOpen(time.Date(2020, 12, 26, 2, 0, 0, 0, time.UTC)))
+	assert.Equal(t, true, window.IsOpen(time.Date(2020, 12, 20, 1, 0, 0, 0, time.UTC)))
+}
+
+func TestDeliveryWindow_NextOpen(t *testing.T) {
+	window, _ := NewDeliveryWindow("", []string{"mon-fri 09:00-18:00"}, nil)
+
+	wait, err := window.NextOpen(time.Date(2020, 12, 21, 10, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+
+	wait, err = window.NextOpen(time.Date(2020, 12, 21, 8, 30, 15, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, 29*time.Minute+45*time.Second, wait)
+
+	// Friday evening waits until Monday morning
+	wait, err = window.NextOpen(time.Date(2020, 12, 25, 18, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, 63*time.Hour, wait)
+
+	closed, _ := NewDeliveryWindow("", nil, []string{"* 00:00-24:00"})
+	_, err = closed.NextOpen(time.Date(2020, 12, 21, 10, 0, 0, 0, time.UTC))
+	assert.Error(t, err)
+}
+
+func TestDeliveryWindow_NextOpen_Boundaries(t *testing.T) {
+	// Window opens when blackout wrapping midnight ends
+	window, _ := NewDeliveryWindow("", nil, []string{"fri 22:00-02:00"})
+	wait, err := window.NextOpen(time.Date(2020, 12, 26, 1, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, wait)
+
+	// Dated blackout over allowed days, in timezone of window
+	window, _ = NewDeliveryWindow("Asia/Taipei", []string{"mon-fri 09:00-18:00"}, []string{"2020-12-24 00:00-24:00", "2020-12-25 00:00-24:00"})
+	taipei, _ := time.LoadLocation("Asia/Taipei")
+	wait, err = window.NextOpen(time.Date(2020, 12, 23, 18, 0, 0, 0, taipei))
+	assert.Nil(t, err)
+	assert.Equal(t, (24*4+15)*time.Hour, wait)
+
+	// Same result as checking every minute
+	windows := [][2][]string{
+		{{"mon-fri 09:00-18:00"}, {"wed 12:00-13:00"}},
+		{{"sat,sun 22:00-06:00", "tue 10:30-10:45"}, {"2020-12-27 00:00-24:00"}},
+		{nil, {"* 08:00-20:00", "2020-12-22 20:00-08:00"}},
+	}
+	for _, ranges := range windows {
+		window, err := NewDeliveryWindow("Europe/London", ranges[0], ranges[1])
+		assert.Nil(t, err)
+		for at := time.Date(2020, 12, 20, 0, 7, 30, 0, time.UTC); at.Before(time.Date(2020, 12, 29, 0, 0, 0, 0, time.UTC)); at = at.Add(37 * time.Minute) {
+			wait, err := window.NextOpen(at)
+			assert.Nil(t, err)
+			assert.Equal(t, nextOpenByMinute(*window, at), wait, "%v at %s", ranges, at)
+		}
+	}
+}
+
+func nextOpenByMinute(window DeliveryWindow, t time.Time) time.Duration {
+	if window.IsOpen(t) {
+		return 0
+	}
+	next := t.Truncate(time.Minute)
+	for {
+		next = next.Add(time.Minute)
+		if window.IsOpen(next) {
+			return next.Sub(t)
+		}
+	}
+}
+
+func TestDeliveryWindow_NeverOpens(t *testing.T) {
+	now := time.Date(2020, 12, 21, 10, 0, 0, 0, time.UTC)
+
+	window, _ := NewDeliveryWindow("", nil, []string{"* 00:00-24:00"})
+	assert.True(t, window.NeverOpens(now))
+	window, _ = NewDeliveryWindow("", []string{"mon-fri 09:00-18:00"}, []string{"mon-fri 00:00-24:00"})
+	assert.True(t, window.NeverOpens(now))
+	window, _ = NewDeliveryWindow("", []string{"2020-12-01 09:00-18:00"}, nil)
+	assert.True(t, window.NeverOpens(now))
+
+	// Window opening beyond look ahead
+	window, _ = NewDeliveryWindow("", []string{"2021-03-01 09:00-18:00"}, nil)
+	assert.False(t, window.NeverOpens(now))
+	_, err := window.NextOpen(now)
+	assert.Error(t, err)
+}
+
+func TestNewDeliveryWindow_Invalid(t *testing.T) {
+	var err error
+	_, err = NewDeliveryWindow("Mars/Olympus", nil, nil)
+	assert.Error(t, err)
+	_, err = NewDeliveryWindow("", []string{"09:00-18:00"}, nil)
+	assert.Error(t, err)
+	_, err = NewDeliveryWindow("", []string{"someday 09:00-18:00"}, nil)
+	assert.Error(t, err)
+	_, err = NewDeliveryWindow("", []string{"mon 9am-6pm"}, nil)
+	assert.Error(t, err)
+	_, err = NewDeliveryWindow("", []string{"mon 09:00-09:00"}, nil)
+	assert.Error(t, err)
+}
+
+func TestDestination_GetDeliveryWait(t *testing.T) {
+	var subject Destination
+
+	subject = Destination{Config: Configuration{Name: "always_open"}}
+	wait, err := subject.GetDeliveryWait(time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+
+	subject = Destination{Config: Configuration{Name: "business_hours", DeliveryTimezone: "UTC", DeliveryWindows: []string{"mon-fri 09:00-18:00"}}}
+	wait, err = subject.GetDeliveryWait(time.Date(2020, 12, 26, 9, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, 48*time.Hour, wait)
+
+	subject = Destination{Config: Configuration{Name: "invalid", DeliveryWindows: []string{"invalid"}}}
+	_, err = subject.GetDeliveryWait(time.Now())
+	assert.Error(t, err)
+}
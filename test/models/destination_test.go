@@ -114,3 +114,44 @@ func TestDestination_GetRetryBackoffSeconds(t *testing.T) {
 	event = IncomingEvent{Control: map[string]interface{}{"retry_count": float64(100)}}
 	assert.Equal(t, int64(600), subject.GetRetryBackoffSeconds(event))
 }
+
+func TestDestination_RedactEvent(t *testing.T) {
+	config := Configuration{Name: "redacted", RedactRules: map[string]string{"email": "mask_email"}}
+	subject := Destination{Config: config}
+	event := IncomingEvent{
+		Payload:            map[string]interface{}{"email": "john@example.com"},
+		TargetDocument:     map[string]interface{}{"email": "john@example.com", "id": "1"},
+		ThrottledPayloads:  []map[string]interface{}{{"email": "mary@example.com"}},
+		ThrottledDocuments: []map[string]interface{}{{"email": "mary@example.com"}},
+	}
+
+	result := subject.RedactEvent(event)
+	assert.Equal(t, map[string]interface{}{"email": "j***@example.com"}, result.Payload)
+	assert.Equal(t, map[string]interface{}{"email": "j***@example.com", "id": "1"}, result.TargetDocument)
+	assert.Equal(t, []map[string]interface{}{{"email": "m***@example.com"}}, result.ThrottledPayloads)
+	assert.Equal(t, []map[string]interface{}{{"email": "m***@example.com"}}, result.ThrottledDocuments)
+	assert.Equal(t, "john@example.com", event.Payload["email"])
+}
+
+func TestDestination_RedactForLog(t *testing.T) {
+	object := map[string]interface{}{"email": "john@example.com"}
+
+	subject := Destination{Config: Configuration{Name: "redacted", RedactRules: map[string]string{"email": "mask_email"}}}
+	assert.Equal(t, object, subject.RedactForLog(object))
+
+	subject = Destination{Config: Configuration{Name: "redacted", RedactRules: map[string]string{"email": "mask_email"}, RedactLogs: true}}
+	assert.Equal(t, map[string]interface{}{"email": "j***@example.com"}, subject.RedactForLog(object))
+}
+
+func TestDestination_RedactEventForLog(t *testing.T) {
+	event := IncomingEvent{Key: "user.update", Payload: map[string]interface{}{"email": "john@example.com"}}
+
+	subject := Destination{Config: Configuration{Name: "redacted", RedactRules: map[string]string{"email": "mask_email"}}}
+	assert.Equal(t, event, subject.RedactEventForLog(event))
+
+	subject = Destination{Config: Configuration{Name: "redacted", RedactRules: map[string]string{"email": "mask_email"}, RedactLogs: true}}
+	assert.Equal(t, map[string]interface{}{"email": "j***@example.com"}, subject.RedactEventForLog(event).Payload)
+
+	// Destination of errors could be empty
+	assert.Equal(t, event, Destination{}.RedactEventForLog(event))
+}
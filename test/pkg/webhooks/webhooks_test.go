@@ -0,0 +1,90 @@
+package webhooks_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shoplineapp/captin/v2/pkg/webhooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func signedHeader(t *testing.T, secret string, id string, timestamp time.Time, body []byte) http.Header {
+	signature, err := webhooks.Sign(secret, id, timestamp, body)
+	assert.Nil(t, err)
+	header := http.Header{}
+	header.Set(webhooks.HeaderID, id)
+	header.Set(webhooks.HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(webhooks.HeaderSignature, signature)
+	return header
+}
+
+func TestSign_StandardWebhooksVector(t *testing.T) {
+	// Test vector from Standard Webhooks reference implementations
+	secret := "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	body := []byte(`{"test": 2432232314}`)
+	signature, err := webhooks.Sign(secret, "msg_p5jXN8AQM9LWM0D4loKWxJek", time.Unix(1614265330, 0), body)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE=", signature)
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	header := signedHeader(t, "secret", "msg_1", time.Now(), body)
+
+	assert.Nil(t, webhooks.Verify([]string{"secret"}, header, body, 0))
+	assert.Equal(t, webhooks.ErrNoMatch, webhooks.Verify([]string{"other"}, header, body, 0))
+	assert.Equal(t, webhooks.ErrNoMatch, webhooks.Verify([]string{"secret"}, header, []byte(`{"id":2}`), 0))
+	assert.Equal(t, webhooks.ErrMissingHeaders, webhooks.Verify([]string{"secret"}, http.Header{}, body, 0))
+}
+
+func TestVerify_Rotation(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+	header := signedHeader(t, "old", "msg_1", now, body)
+	next, _ := webhooks.Sign("new", "msg_1", now, body)
+	header.Set(webhooks.HeaderSignature, header.Get(webhooks.HeaderSignature)+" "+next)
+
+	// Receivers still on either secret accept the webhook
+	assert.Nil(t, webhooks.Verify([]string{"old"}, header, body, 0))
+	assert.Nil(t, webhooks.Verify([]string{"new"}, header, body, 0))
+}
+
+func TestVerify_Timestamp(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	header := signedHeader(t, "secret", "msg_1", time.Now().Add(-10*time.Minute), body)
+	assert.Equal(t, webhooks.ErrExpired, webhooks.Verify([]string{"secret"}, header, body, 0))
+	assert.Nil(t, webhooks.Verify([]string{"secret"}, header, body, time.Hour))
+
+	header.Set(webhooks.HeaderTimestamp, "yesterday")
+	assert.Equal(t, webhooks.ErrInvalidTimestamp, webhooks.Verify([]string{"secret"}, header, body, 0))
+}
+
+func TestSign_WhsecSecret(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("raw key"))
+	now := time.Now()
+	encoded, _ := webhooks.Sign("whsec_"+key, "msg_1", now, []byte("body"))
+	raw, _ := webhooks.Sign("raw key", "msg_1", now, []byte("body"))
+	assert.Equal(t, raw, encoded)
+
+	_, err := webhooks.Sign("whsec_!!!", "msg_1", now, []byte("body"))
+	assert.Error(t, err)
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	signature := webhooks.SignHMAC("secret", body)
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", signature)
+
+	assert.Nil(t, webhooks.VerifyHMAC([]string{"next", "secret"}, signature, body))
+	assert.Equal(t, webhooks.ErrNoMatch, webhooks.VerifyHMAC([]string{"next"}, signature, body))
+	assert.Equal(t, webhooks.ErrMissingHeaders, webhooks.VerifyHMAC([]string{"secret"}, "", body))
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	u, _ := url.Parse("https://example.com/hooks?b=2&a=1&a=0")
+	assert.Equal(t, "a=1&a=0&b=2", string(webhooks.CanonicalQuery(u)))
+}
@@ -0,0 +1,320 @@
+package senders_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+type amqpPublished struct {
+	Exchange   string
+	RoutingKey string
+	Mandatory  bool
+	Msg        amqp.Publishing
+}
+
+// Routing key of messages returned by mock channel as unroutable when published as mandatory
+const amqpUnroutableKey = "unroutable"
+
+// amqpChannelMock - Channel confirming publishes by confirm function, nil result leaves publish unconfirmed
+type amqpChannelMock struct {
+	mu        sync.Mutex
+	closed    bool
+	tag       uint64
+	confirms  chan amqp.Confirmation
+	returns   chan amqp.Return
+	confirm   func(msg amqp.Publishing) *bool
+	published []amqpPublished
+}
+
+func (c *amqpChannelMock) Confirm(noWait bool) error {
+	return nil
+}
+
+func (c *amqpChannelMock) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	c.confirms = confirm
+	return confirm
+}
+
+func (c *amqpChannelMock) NotifyReturn(returns chan amqp.Return) chan amqp.Return {
+	c.returns = returns
+	return returns
+}
+
+func (c *amqpChannelMock) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return amqp.ErrClosed
+	}
+	c.tag++
+	c.published = append(c.published, amqpPublished{Exchange: exchange, RoutingKey: key, Mandatory: mandatory, Msg: msg})
+	if mandatory && key == amqpUnroutableKey {
+		// Broker returns message before confirming it
+		c.returns <- amqp.Return{ReplyCode: amqp.NoRoute, ReplyText: "NO_ROUTE", Exchange: exchange, RoutingKey: key, MessageId: msg.MessageId, Body: msg.Body}
+		c.confirms <- amqp.Confirmation{DeliveryTag: c.tag, Ack: true}
+		return nil
+	}
+	if ack := c.confirm(msg); ack != nil {
+		c.confirms <- amqp.Confirmation{DeliveryTag: c.tag, Ack: *ack}
+	}
+	return nil
+}
+
+func (c *amqpChannelMock) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.confirms)
+		close(c.returns)
+	}
+	return nil
+}
+
+type amqpConnectionMock struct {
+	mu       sync.Mutex
+	closes   chan *amqp.Error
+	channels []*amqpChannelMock
+	confirm  func(msg amqp.Publishing) *bool
+}
+
+func (c *amqpConnectionMock) Channel() (AmqpChannel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	channel := &amqpChannelMock{confirm: c.confirm}
+	c.channels = append(c.channels, channel)
+	return channel, nil
+}
+
+func (c *amqpConnectionMock) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	c.closes = receiver
+	return receiver
+}
+
+// Close - Close connection and its channels like the broker does
+func (c *amqpConnectionMock) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, channel := range c.channels {
+		channel.Close()
+	}
+	if c.closes != nil {
+		c.closes <- amqp.ErrClosed
+		close(c.closes)
+		c.closes = nil
+	}
+	return nil
+}
+
+func (c *amqpConnectionMock) published() []amqpPublished {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	published := []amqpPublished{}
+	for _, channel := range c.channels {
+		channel.mu.Lock()
+		published = append(published, channel.published...)
+		channel.mu.Unlock()
+	}
+	return published
+}
+
+func amqpConfirmWith(ack bool) func(msg amqp.Publishing) *bool {
+	return func(msg amqp.Publishing) *bool { return &ack }
+}
+
+// newAmqpMockSender - Sender dialing mock connections, which are returned in the order of dialing
+func newAmqpMockSender(confirm func(msg amqp.Publishing) *bool) (*AmqpSender, *[]*amqpConnectionMock) {
+	sender := NewAmqpSender("amqp://localhost")
+	connections := []*amqpConnectionMock{}
+	sender.Dial = func(url string) (AmqpConnection, error) {
+		conn := &amqpConnectionMock{confirm: confirm}
+		connections = append(connections, conn)
+		return conn, nil
+	}
+	return sender, &connections
+}
+
+func TestAmqpSender_SendEvent_Success(t *testing.T) {
+	sender, connections := newAmqpMockSender(amqpConfirmWith(true))
+	defer sender.Close()
+
+	config := models.Configuration{
+		Name:           "amqp",
+		AmqpExchange:   "events",
+		AmqpRoutingKey: "{{ .target_type }}.{{ .event_key }}",
+		AmqpHeaders:    map[string]string{"x-hook": "{{ .hook }}"},
+	}
+	event := models.IncomingEvent{TraceId: "trace_1", Key: "product.update", Source: "core", TargetType: "Product"}
+	event.DistributedTracingInfo.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	assert.Nil(t, sender.SendEvent(context.Background(), event, models.Destination{Config: config}))
+
+	published := (*connections)[0].published()
+	assert.Len(t, published, 1)
+	assert.Equal(t, "events", published[0].Exchange)
+	assert.Equal(t, "Product.product.update", published[0].RoutingKey)
+	assert.True(t, published[0].Mandatory)
+	assert.Equal(t, amqp.Persistent, published[0].Msg.DeliveryMode)
+	assert.Equal(t, "application/json", published[0].Msg.ContentType)
+	assert.Equal(t, "trace_1", published[0].Msg.MessageId)
+	assert.Equal(t, "amqp", published[0].Msg.Headers["x-hook"])
+	assert.Equal(t, "product.update", published[0].Msg.Headers["event_key"])
+	assert.NotEmpty(t, published[0].Msg.Headers["traceparent"])
+	assert.Contains(t, string(published[0].Msg.Body), `"event_key":"product.update"`)
+}
+
+func TestAmqpSender_SendEvent_Transient(t *testing.T) {
+	sender, connections := newAmqpMockSender(amqpConfirmWith(true))
+	defer sender.Close()
+
+	config := models.Configuration{Name: "amqp", AmqpDeliveryMode: models.AmqpDeliveryTransient}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update"}, models.Destination{Config: config}))
+	published := (*connections)[0].published()
+	assert.Equal(t, amqp.Transient, published[0].Msg.DeliveryMode)
+	assert.Equal(t, "product.update", published[0].RoutingKey)
+}
+
+func TestAmqpSender_SendEvent_Nack(t *testing.T) {
+	sender, _ := newAmqpMockSender(amqpConfirmWith(false))
+	defer sender.Close()
+
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{Name: "amqp"}})
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+	assert.Contains(t, result.Error(), "nacked")
+}
+
+func TestAmqpSender_SendEvent_Unroutable(t *testing.T) {
+	sender, connections := newAmqpMockSender(amqpConfirmWith(true))
+	defer sender.Close()
+
+	// Returned message is retried, though broker acks it after returning
+	config := models.Configuration{Name: "amqp", AmqpRoutingKey: amqpUnroutableKey}
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+	assert.Contains(t, result.Error(), "returned")
+
+	// Following publish on the same channel is confirmed as usual
+	config = models.Configuration{Name: "amqp", AmqpRoutingKey: "product.update"}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+	assert.Len(t, (*connections)[0].published(), 2)
+}
+
+func TestAmqpSender_SendEvent_ConfirmTimeout(t *testing.T) {
+	sender, _ := newAmqpMockSender(func(msg amqp.Publishing) *bool { return nil })
+	defer sender.Close()
+
+	config := models.Configuration{Name: "amqp", AmqpConfirmTimeout: "50ms"}
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+	assert.Contains(t, result.Error(), "not confirmed")
+}
+
+func TestAmqpSender_SendEvent_InvalidRoutingKey(t *testing.T) {
+	sender, connections := newAmqpMockSender(amqpConfirmWith(true))
+	defer sender.Close()
+
+	config := models.Configuration{Name: "amqp", AmqpRoutingKey: "{{ .event_key"}
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+	assert.IsType(t, &captin_errors.UnretryableError{}, result)
+	assert.Len(t, *connections, 0)
+}
+
+func TestAmqpSender_SendEvent_ChannelPool(t *testing.T) {
+	sender, connections := newAmqpMockSender(amqpConfirmWith(true))
+	sender.PoolSize = 2
+	defer sender.Close()
+
+	wg := sync.WaitGroup{}
+	results := make([]error, 6)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{Name: "amqp"}})
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, make([]error, 6), results)
+	assert.Len(t, *connections, 1)
+	assert.Len(t, (*connections)[0].channels, 2)
+	assert.Len(t, (*connections)[0].published(), 6)
+}
+
+func TestAmqpSender_SendEvent_ConcurrentPublishes(t *testing.T) {
+	sender, _ := newAmqpMockSender(amqpConfirmWith(true))
+	sender.PoolSize = 1
+	defer sender.Close()
+
+	// Confirms and returns arrive while other publishes are in flight on the same channel
+	wg := sync.WaitGroup{}
+	results := make([]error, 300)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			config := models.Configuration{Name: "amqp", AmqpRoutingKey: "product.update"}
+			if i%3 == 0 {
+				config.AmqpRoutingKey = amqpUnroutableKey
+			}
+			results[i] = sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+		}(i)
+	}
+	wg.Wait()
+	for i, result := range results {
+		if i%3 == 0 {
+			assert.IsType(t, &captin_errors.DispatcherError{}, result, i)
+		} else {
+			assert.Nil(t, result, i)
+		}
+	}
+}
+
+func TestAmqpSender_SendEvent_Reconnect(t *testing.T) {
+	sender, connections := newAmqpMockSender(amqpConfirmWith(true))
+	sender.PoolSize = 1
+	defer sender.Close()
+
+	destination := models.Destination{Config: models.Configuration{Name: "amqp"}}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, destination))
+
+	// Connection is closed by broker, the next send dials again
+	(*connections)[0].Close()
+	assert.Eventually(t, func() bool {
+		return sender.SendEvent(context.Background(), models.IncomingEvent{}, destination) == nil && len(*connections) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Len(t, (*connections)[1].published(), 1)
+}
+
+func TestAmqpSender_SendEvent_DialFailed(t *testing.T) {
+	sender, connections := newAmqpMockSender(amqpConfirmWith(true))
+	dial := sender.Dial
+	sender.Dial = func(url string) (AmqpConnection, error) {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	defer sender.Close()
+
+	destination := models.Destination{Config: models.Configuration{Name: "amqp"}}
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, destination)
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+
+	sender.Dial = dial
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, destination))
+	assert.Len(t, *connections, 1)
+}
+
+func TestAmqpSender_SendEvent_AfterClose(t *testing.T) {
+	sender, _ := newAmqpMockSender(amqpConfirmWith(true))
+	assert.Nil(t, sender.Close())
+
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{Name: "amqp"}})
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+}
@@ -0,0 +1,142 @@
+package senders_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+// chatWebhookServer - Incoming webhook responding status, posted payloads are recorded
+func chatWebhookServer(status *int, retryAfter string, payloads *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		*payloads = append(*payloads, payload)
+		if *status == http.StatusTooManyRequests && retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(*status)
+	}))
+}
+
+func chatEvent() models.IncomingEvent {
+	return models.IncomingEvent{
+		Key:        "order.refund_failed",
+		TargetType: "Order",
+		TargetId:   "o1",
+		Payload:    map[string]interface{}{"reason": "card expired"},
+	}
+}
+
+func TestChatSender_SendEvent_Slack(t *testing.T) {
+	status := http.StatusOK
+	payloads := []map[string]interface{}{}
+	server := chatWebhookServer(&status, "", &payloads)
+	defer server.Close()
+
+	destination := models.Destination{Config: models.Configuration{
+		Name:         "ops_refunds",
+		CallbackURL:  server.URL,
+		ChatTemplate: "Refund of *{{ .target_id }}* failed: {{ .payload.reason }}",
+	}}
+	sender := &ChatSender{}
+
+	assert.Nil(t, sender.SendEvent(context.Background(), chatEvent(), destination))
+	assert.Equal(t, []map[string]interface{}{{"text": "Refund of *o1* failed: card expired", "mrkdwn": true}}, payloads)
+}
+
+func TestChatSender_SendEvent_Teams(t *testing.T) {
+	status := http.StatusOK
+	payloads := []map[string]interface{}{}
+	server := chatWebhookServer(&status, "", &payloads)
+	defer server.Close()
+
+	destination := models.Destination{Config: models.Configuration{
+		Name:        "ops_refunds",
+		CallbackURL: server.URL,
+		ChatFormat:  models.ChatFormatTeams,
+	}}
+	sender := &ChatSender{}
+
+	assert.Nil(t, sender.SendEvent(context.Background(), chatEvent(), destination))
+	assert.Len(t, payloads, 1)
+	assert.Equal(t, "MessageCard", payloads[0]["@type"])
+	assert.Equal(t, "order.refund_failed", payloads[0]["summary"])
+	assert.Equal(t, "*order.refund_failed* Order o1", payloads[0]["text"])
+}
+
+func TestChatSender_SendEvent_Errors(t *testing.T) {
+	status := http.StatusBadRequest
+	payloads := []map[string]interface{}{}
+	server := chatWebhookServer(&status, "", &payloads)
+	defer server.Close()
+
+	destination := models.Destination{Config: models.Configuration{Name: "ops_refunds", CallbackURL: server.URL, ChatMinInterval: "1ms"}}
+	sender := &ChatSender{}
+
+	// Rejected payload is not retried
+	assert.IsType(t, &captin_errors.UnretryableError{}, sender.SendEvent(context.Background(), chatEvent(), destination))
+
+	// Template failing on event is not retried, nothing is posted
+	invalid := models.Destination{Config: models.Configuration{Name: "ops_refunds", CallbackURL: server.URL, ChatTemplate: "{{ .payload.reason.code }}"}}
+	assert.IsType(t, &captin_errors.UnretryableError{}, sender.SendEvent(context.Background(), chatEvent(), invalid))
+	assert.Len(t, payloads, 1)
+
+	status = http.StatusServiceUnavailable
+	time.Sleep(5 * time.Millisecond)
+	assert.IsType(t, &captin_errors.DispatcherError{}, sender.SendEvent(context.Background(), chatEvent(), destination))
+}
+
+func TestChatSender_SendEvent_RetryAfter(t *testing.T) {
+	status := http.StatusTooManyRequests
+	payloads := []map[string]interface{}{}
+	server := chatWebhookServer(&status, "1", &payloads)
+	defer server.Close()
+
+	destination := models.Destination{Config: models.Configuration{Name: "ops_refunds", CallbackURL: server.URL, ChatMinInterval: "1ms"}}
+	sender := &ChatSender{}
+
+	err := sender.SendEvent(context.Background(), chatEvent(), destination)
+	assert.IsType(t, &captin_errors.DispatcherError{}, err)
+	assert.Equal(t, "1", err.(*captin_errors.DispatcherError).Response.Headers["Retry-After"])
+
+	// Webhook is paused for Retry-After, events fail fast without being posted
+	status = http.StatusOK
+	assert.IsType(t, &captin_errors.DispatcherError{}, sender.SendEvent(context.Background(), chatEvent(), destination))
+	assert.Len(t, payloads, 1)
+
+	time.Sleep(1100 * time.Millisecond)
+	assert.Nil(t, sender.SendEvent(context.Background(), chatEvent(), destination))
+	assert.Len(t, payloads, 2)
+}
+
+func TestChatSender_SendEvent_MinInterval(t *testing.T) {
+	status := http.StatusOK
+	payloads := []map[string]interface{}{}
+	server := chatWebhookServer(&status, "", &payloads)
+	defer server.Close()
+
+	destination := models.Destination{Config: models.Configuration{Name: "ops_refunds", CallbackURL: server.URL, ChatMinInterval: "200ms"}}
+	sender := &ChatSender{}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, sender.SendEvent(context.Background(), chatEvent(), destination))
+	}
+	assert.True(t, time.Since(start) >= 400*time.Millisecond)
+	assert.Len(t, payloads, 3)
+
+	// Waiting for slot is given up with context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.IsType(t, &captin_errors.DispatcherError{}, sender.SendEvent(ctx, chatEvent(), destination))
+	assert.Len(t, payloads, 3)
+}
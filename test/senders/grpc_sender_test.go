@@ -0,0 +1,141 @@
+package senders_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	"github.com/shoplineapp/captin/v2/pkg/eventpb"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type eventReceiverMock struct {
+	eventpb.UnimplementedEventReceiverServer
+
+	events   []*eventpb.Event
+	metadata []metadata.MD
+	err      error
+	delay    time.Duration
+}
+
+func (r *eventReceiverMock) ReceiveEvent(ctx context.Context, event *eventpb.Event) (*eventpb.ReceiveEventResponse, error) {
+	time.Sleep(r.delay)
+	md, _ := metadata.FromIncomingContext(ctx)
+	r.events = append(r.events, event)
+	r.metadata = append(r.metadata, md)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &eventpb.ReceiveEventResponse{}, nil
+}
+
+// newGRPCTestSender - Sender connected to in-memory grpc server of receiver
+func newGRPCTestSender(receiver *eventReceiverMock) (*GRPCSender, func()) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	eventpb.RegisterEventReceiverServer(server, receiver)
+	go server.Serve(listener)
+
+	sender := NewGRPCSender(grpc.WithContextDialer(func(ctx context.Context, target string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	return sender, func() {
+		sender.Close()
+		server.Stop()
+	}
+}
+
+func TestGRPCSender_SendEvent_Success(t *testing.T) {
+	receiver := &eventReceiverMock{}
+	sender, stop := newGRPCTestSender(receiver)
+	defer stop()
+
+	event := models.IncomingEvent{
+		TraceId:    "trace_1",
+		Key:        "product.update",
+		Source:     "core",
+		TargetType: "Product",
+		TargetId:   "product_1",
+		Payload:    map[string]interface{}{"title": "Shirt", "price": 10},
+		// Kept by keep_throttled_payloads and keep_throttled_documents
+		ThrottledPayloads:  []map[string]interface{}{{"price": 9}, {"price": 8}},
+		ThrottledDocuments: []map[string]interface{}{{"stock": 1}},
+	}
+	event.DistributedTracingInfo.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	destination := models.Destination{Config: models.Configuration{Name: "grpc", CallbackURL: "passthrough:///orders", GRPCPlaintext: true}}
+	assert.Nil(t, sender.SendEvent(context.Background(), event, destination))
+	assert.Nil(t, sender.SendEvent(context.Background(), event, destination))
+
+	assert.Len(t, receiver.events, 2)
+	received := receiver.events[0]
+	assert.Equal(t, "trace_1", received.TraceId)
+	assert.Equal(t, "product.update", received.EventKey)
+	assert.Equal(t, "core", received.Source)
+	assert.Equal(t, "Product", received.TargetType)
+	assert.Equal(t, "product_1", received.TargetId)
+	assert.Equal(t, "grpc", received.Hook)
+	assert.Equal(t, map[string]interface{}{"title": "Shirt", "price": float64(10)}, received.Payload.AsMap())
+	assert.Nil(t, received.TargetDocument)
+	if assert.Len(t, received.ThrottledPayloads, 2) {
+		assert.Equal(t, map[string]interface{}{"price": float64(8)}, received.ThrottledPayloads[1].AsMap())
+	}
+	if assert.Len(t, received.ThrottledDocuments, 1) {
+		assert.Equal(t, map[string]interface{}{"stock": float64(1)}, received.ThrottledDocuments[0].AsMap())
+	}
+	assert.NotEmpty(t, receiver.metadata[0].Get("traceparent"))
+	assert.Equal(t, receiver.metadata[0].Get("traceparent")[0], received.DistributedTracingInfo["traceparent"])
+}
+
+func TestGRPCSender_SendEvent_StatusCodes(t *testing.T) {
+	receiver := &eventReceiverMock{}
+	sender, stop := newGRPCTestSender(receiver)
+	defer stop()
+
+	destination := models.Destination{Config: models.Configuration{Name: "grpc", CallbackURL: "passthrough:///orders", GRPCPlaintext: true}}
+
+	receiver.err = status.Error(codes.Unavailable, "overloaded")
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, destination)
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+	assert.Contains(t, result.Error(), "overloaded")
+
+	receiver.err = status.Error(codes.InvalidArgument, "unknown event")
+	result = sender.SendEvent(context.Background(), models.IncomingEvent{}, destination)
+	assert.IsType(t, &captin_errors.UnretryableError{}, result)
+	assert.Contains(t, result.Error(), "unknown event")
+}
+
+func TestGRPCSender_SendEvent_Deadline(t *testing.T) {
+	receiver := &eventReceiverMock{delay: 200 * time.Millisecond}
+	sender, stop := newGRPCTestSender(receiver)
+	defer stop()
+
+	destination := models.Destination{Config: models.Configuration{Name: "grpc", CallbackURL: "passthrough:///orders", GRPCPlaintext: true, GRPCTimeout: "50ms"}}
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, destination)
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+	assert.Contains(t, result.Error(), codes.DeadlineExceeded.String())
+}
+
+func TestGRPCSender_SendEvent_Unimplemented(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	go server.Serve(listener)
+	defer server.Stop()
+
+	sender := NewGRPCSender(grpc.WithContextDialer(func(ctx context.Context, target string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	defer sender.Close()
+
+	destination := models.Destination{Config: models.Configuration{Name: "grpc", CallbackURL: "passthrough:///orders", GRPCPlaintext: true}}
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, destination)
+	assert.IsType(t, &captin_errors.UnretryableError{}, result)
+}
@@ -0,0 +1,235 @@
+package senders_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+func oauth2TokenServer(requests *int32, expiresIn int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(requests, 1)
+		r.ParseForm()
+		clientID, clientSecret, _ := r.BasicAuth()
+		if r.Form.Get("grant_type") != "client_credentials" || clientID != "captin" || clientSecret != "client-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token_%d_%s","token_type":"Bearer","expires_in":%d}`, n, r.Form.Get("scope"), expiresIn)
+	}))
+}
+
+func TestHTTPEventSender_SendEvent_Headers(t *testing.T) {
+	captured := capturedRequest{}
+	server := capturingServer(&captured)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update", TargetId: "product_1"}, models.Destination{Config: models.Configuration{
+		Name:        "headers_test",
+		CallbackURL: server.URL,
+		HTTPHeaders: map[string]string{
+			"X-Source":    "captin",
+			"X-Target-Id": "{{ .target_id }}",
+			"X-Hook":      "{{ .hook }}",
+		},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, "captin", captured.header.Get("X-Source"))
+	assert.Equal(t, "product_1", captured.header.Get("X-Target-Id"))
+	assert.Equal(t, "headers_test", captured.header.Get("X-Hook"))
+	assert.Equal(t, "application/json", captured.header.Get("Content-Type"))
+}
+
+func TestHTTPEventSender_SendEvent_Auth(t *testing.T) {
+	os.Setenv("TEST_HTTP_AUTH_SECRET", "s3cret")
+	defer os.Unsetenv("TEST_HTTP_AUTH_SECRET")
+
+	captured := capturedRequest{}
+	server := capturingServer(&captured)
+	defer server.Close()
+	sender := &HTTPEventSender{}
+
+	config := models.Configuration{Name: "auth_test", CallbackURL: server.URL, HTTPAuth: models.HTTPAuthAPIKey, HTTPAuthSecret: "env:TEST_HTTP_AUTH_SECRET"}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+	assert.Equal(t, "s3cret", captured.header.Get("X-API-Key"))
+
+	config.HTTPAuthHeader = "X-Partner-Key"
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+	assert.Equal(t, "s3cret", captured.header.Get("X-Partner-Key"))
+
+	config.HTTPAuth = models.HTTPAuthBearer
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+	assert.Equal(t, "Bearer s3cret", captured.header.Get("Authorization"))
+
+	// Static credentials rejected by destination are not retried
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer rejecting.Close()
+	rejected := config
+	rejected.CallbackURL = rejecting.URL
+	assert.IsType(t, &captin_errors.UnretryableError{}, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: rejected}))
+
+	config.HTTPAuth = models.HTTPAuthBasic
+	config.HTTPAuthUsername = "partner"
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+	req := http.Request{Header: captured.header}
+	username, password, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "partner", username)
+	assert.Equal(t, "s3cret", password)
+}
+
+func TestHTTPEventSender_SendEvent_AuthSecretMissing(t *testing.T) {
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{
+		Name:           "auth_test",
+		CallbackURL:    "http://localhost",
+		HTTPAuth:       models.HTTPAuthBearer,
+		HTTPAuthSecret: "env:TEST_HTTP_AUTH_SECRET_MISSING",
+	}})
+	assert.IsType(t, &captin_errors.UnretryableError{}, err)
+}
+
+func TestHTTPEventSender_SendEvent_OAuth2(t *testing.T) {
+	os.Setenv("TEST_OAUTH2_SECRET", "client-secret")
+	defer os.Unsetenv("TEST_OAUTH2_SECRET")
+
+	var tokenRequests int32
+	tokenServer := oauth2TokenServer(&tokenRequests, 3600)
+	defer tokenServer.Close()
+
+	status := http.StatusOK
+	authorizations := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	destination := models.Destination{Config: models.Configuration{
+		Name:               "oauth2_test",
+		CallbackURL:        server.URL,
+		HTTPAuth:           models.HTTPAuthOAuth2,
+		HTTPAuthSecret:     "env:TEST_OAUTH2_SECRET",
+		HTTPOAuth2TokenURL: tokenServer.URL,
+		HTTPOAuth2ClientID: "captin",
+		HTTPOAuth2Scopes:   []string{"webhooks"},
+	}}
+	sender := &HTTPEventSender{}
+
+	// Token is cached across events
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, destination))
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, destination))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokenRequests))
+	assert.Equal(t, []string{"Bearer token_1_webhooks", "Bearer token_1_webhooks"}, authorizations)
+
+	// Token is dropped when destination rejects it, and the event is retried with a new token
+	status = http.StatusUnauthorized
+	assert.IsType(t, &captin_errors.DispatcherError{}, sender.SendEvent(context.Background(), models.IncomingEvent{}, destination))
+	status = http.StatusOK
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, destination))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&tokenRequests))
+	assert.Equal(t, "Bearer token_2_webhooks", authorizations[3])
+}
+
+func TestHTTPEventSender_SendEvent_OAuth2ConcurrentClients(t *testing.T) {
+	os.Setenv("TEST_OAUTH2_SECRET", "client-secret")
+	defer os.Unsetenv("TEST_OAUTH2_SECRET")
+
+	release := make(chan struct{})
+	slowTokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, `{"access_token":"slow","expires_in":3600}`)
+	}))
+	defer slowTokenServer.Close()
+	defer close(release)
+	var tokenRequests int32
+	tokenServer := oauth2TokenServer(&tokenRequests, 3600)
+	defer tokenServer.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	config := models.Configuration{
+		Name:               "oauth2_test",
+		CallbackURL:        server.URL,
+		HTTPAuth:           models.HTTPAuthOAuth2,
+		HTTPAuthSecret:     "env:TEST_OAUTH2_SECRET",
+		HTTPOAuth2TokenURL: slowTokenServer.URL,
+		HTTPOAuth2ClientID: "captin",
+	}
+	sender := &HTTPEventSender{}
+	go sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+
+	// Token of another client is requested while the slow token endpoint is pending
+	config.HTTPOAuth2TokenURL = tokenServer.URL
+	done := make(chan error)
+	go func() {
+		done <- sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+	}()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "token request is blocked by another client")
+	}
+}
+
+func TestHTTPEventSender_SendEvent_OAuth2Refresh(t *testing.T) {
+	os.Setenv("TEST_OAUTH2_SECRET", "client-secret")
+	defer os.Unsetenv("TEST_OAUTH2_SECRET")
+
+	// Token expiring within refresh margin is requested again
+	var tokenRequests int32
+	tokenServer := oauth2TokenServer(&tokenRequests, 10)
+	defer tokenServer.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	destination := models.Destination{Config: models.Configuration{
+		Name:               "oauth2_test",
+		CallbackURL:        server.URL,
+		HTTPAuth:           models.HTTPAuthOAuth2,
+		HTTPAuthSecret:     "env:TEST_OAUTH2_SECRET",
+		HTTPOAuth2TokenURL: tokenServer.URL,
+		HTTPOAuth2ClientID: "captin",
+	}}
+	sender := &HTTPEventSender{}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, destination))
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, destination))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&tokenRequests))
+}
+
+func TestHTTPEventSender_SendEvent_OAuth2TokenError(t *testing.T) {
+	os.Setenv("TEST_OAUTH2_SECRET", "wrong-secret")
+	defer os.Unsetenv("TEST_OAUTH2_SECRET")
+
+	var tokenRequests int32
+	tokenServer := oauth2TokenServer(&tokenRequests, 3600)
+	defer tokenServer.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{
+		Name:               "oauth2_test",
+		CallbackURL:        "http://localhost",
+		HTTPAuth:           models.HTTPAuthOAuth2,
+		HTTPAuthSecret:     "env:TEST_OAUTH2_SECRET",
+		HTTPOAuth2TokenURL: tokenServer.URL,
+		HTTPOAuth2ClientID: "captin",
+	}})
+	if assert.IsType(t, &captin_errors.DispatcherError{}, err) {
+		assert.NotContains(t, err.Error(), "wrong-secret")
+	}
+}
@@ -0,0 +1,141 @@
+package senders_test
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPClientPool_Client_Reuse(t *testing.T) {
+	pool := NewHTTPClientPool(nil)
+	destination := models.Destination{Config: models.Configuration{Name: "pool_test", HTTPTimeout: "5s"}}
+
+	client, err := pool.Client(destination)
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Second, client.Timeout)
+
+	again, _ := pool.Client(destination)
+	assert.Same(t, client, again)
+
+	// Changed settings get a new client
+	changed, _ := pool.Client(models.Destination{Config: models.Configuration{Name: "pool_test", HTTPTimeout: "10s"}})
+	assert.NotSame(t, client, changed)
+
+	other, _ := pool.Client(models.Destination{Config: models.Configuration{Name: "other", HTTPTimeout: "5s"}})
+	assert.NotSame(t, client, other)
+}
+
+func TestHTTPClientPool_Client_Replace(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var once sync.Once
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			once.Do(func() { closed <- struct{}{} })
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	pool := NewHTTPClientPool(nil)
+	config := models.Configuration{Name: "pool_test", HTTPTimeout: "5s"}
+	client, _ := pool.Client(models.Destination{Config: config})
+	res, err := client.Get(server.URL)
+	assert.Nil(t, err)
+	ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	// Idle connection of replaced client is closed
+	changed := config
+	changed.HTTPTimeout = "10s"
+	pool.Client(models.Destination{Config: changed})
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "idle connection of replaced client is not closed")
+	}
+
+	// Replaced client is not kept for reverted settings
+	reverted, _ := pool.Client(models.Destination{Config: config})
+	assert.NotSame(t, client, reverted)
+}
+
+func TestHTTPClientPool_Client_Transport(t *testing.T) {
+	pool := NewHTTPClientPool(nil)
+	client, err := pool.Client(models.Destination{Config: models.Configuration{
+		Name:                    "transport_test",
+		HTTPMaxIdleConns:        20,
+		HTTPMaxIdleConnsPerHost: 5,
+		HTTPTLSTimeout:          "3s",
+		HTTPEnableHTTP2:         true,
+	}})
+	assert.Nil(t, err)
+
+	transport := client.Transport.(*http.Transport)
+	assert.Equal(t, 20, transport.MaxIdleConns)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 3*time.Second, transport.TLSHandshakeTimeout)
+	assert.Equal(t, 90*time.Second, transport.IdleConnTimeout)
+	assert.True(t, transport.ForceAttemptHTTP2)
+	assert.False(t, transport.TLSClientConfig.InsecureSkipVerify)
+	assert.Equal(t, 30*time.Second, client.Timeout)
+}
+
+func TestHTTPEventSender_SendEvent_VerifyTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	sender := &HTTPEventSender{Clients: NewHTTPClientPool(nil)}
+	event := models.IncomingEvent{Key: "product.update"}
+
+	// Self-signed certificate is rejected by default
+	err := sender.SendEvent(context.Background(), event, models.Destination{Config: models.Configuration{Name: "tls_default", CallbackURL: server.URL}})
+	assert.Error(t, err)
+
+	err = sender.SendEvent(context.Background(), event, models.Destination{Config: models.Configuration{Name: "tls_insecure", CallbackURL: server.URL, HTTPInsecureSkipVerify: true}})
+	assert.Nil(t, err)
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.Nil(t, ioutil.WriteFile(bundle, pemData, 0600))
+	err = sender.SendEvent(context.Background(), event, models.Destination{Config: models.Configuration{Name: "tls_bundle", CallbackURL: server.URL, HTTPCABundle: bundle}})
+	assert.Nil(t, err)
+}
+
+func TestHTTPEventSender_SendEvent_InvalidCABundle(t *testing.T) {
+	sender := &HTTPEventSender{Clients: NewHTTPClientPool(nil)}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{
+		Name:         "tls_missing_bundle",
+		CallbackURL:  "https://localhost",
+		HTTPCABundle: filepath.Join(os.TempDir(), "missing-ca.pem"),
+	}})
+	assert.IsType(t, &captin_errors.UnretryableError{}, err)
+}
+
+func TestHTTPEventSender_SendEvent_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	sender := &HTTPEventSender{Clients: NewHTTPClientPool(nil)}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{
+		Name:        "timeout_test",
+		CallbackURL: server.URL,
+		HTTPTimeout: "50ms",
+	}})
+	assert.Error(t, err)
+}
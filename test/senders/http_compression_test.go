@@ -0,0 +1,53 @@
+package senders_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	"github.com/shoplineapp/captin/v2/pkg/webhooks"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPEventSender_SendEvent_Compression(t *testing.T) {
+	os.Setenv("TEST_SIGNING_SECRET", "current")
+	defer os.Unsetenv("TEST_SIGNING_SECRET")
+
+	captured := capturedRequest{}
+	server := capturingServer(&captured)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	config := models.Configuration{
+		Name:                 "compression_test",
+		CallbackURL:          server.URL,
+		HTTPBody:             models.HTTPBodyPayload,
+		Compression:          helpers.CompressionGzip,
+		CompressionThreshold: 100,
+		SigningSecrets:       []string{"env:TEST_SIGNING_SECRET"},
+	}
+	large := models.IncomingEvent{Payload: map[string]interface{}{"description": strings.Repeat("a", 200)}}
+	assert.Nil(t, sender.SendEvent(context.Background(), large, models.Destination{Config: config}))
+	assert.Equal(t, "gzip", captured.header.Get("Content-Encoding"))
+	body, err := helpers.Decompress(captured.body, helpers.CompressionGzip)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"description":"`+strings.Repeat("a", 200)+`"}`, string(body))
+	assert.Nil(t, webhooks.Verify([]string{"current"}, captured.header, body, 0))
+
+	// Body under threshold is sent as is
+	small := models.IncomingEvent{Payload: map[string]interface{}{"id": 1}}
+	assert.Nil(t, sender.SendEvent(context.Background(), small, models.Destination{Config: config}))
+	assert.Empty(t, captured.header.Get("Content-Encoding"))
+	assert.JSONEq(t, `{"id":1}`, string(captured.body))
+
+	config.Compression = helpers.CompressionZstd
+	assert.Nil(t, sender.SendEvent(context.Background(), large, models.Destination{Config: config}))
+	assert.Equal(t, "zstd", captured.header.Get("Content-Encoding"))
+	body, err = helpers.Decompress(captured.body, helpers.CompressionZstd)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"description":"`+strings.Repeat("a", 200)+`"}`, string(body))
+}
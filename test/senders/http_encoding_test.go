@@ -0,0 +1,148 @@
+package senders_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type methodCapturedRequest struct {
+	method string
+	url    *url.URL
+	header http.Header
+	body   []byte
+}
+
+func methodCapturingServer(captured *methodCapturedRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured.method = r.Method
+		captured.url = r.URL
+		captured.header = r.Header
+		captured.body, _ = ioutil.ReadAll(r.Body)
+	}))
+}
+
+func encodingTestEvent() models.IncomingEvent {
+	return models.IncomingEvent{
+		Key:      "product.update",
+		TargetId: "product_1",
+		Payload: map[string]interface{}{
+			"id":    "product_1",
+			"price": 1000000,
+			"items": []interface{}{map[string]interface{}{"sku": "sku_1"}},
+		},
+	}
+}
+
+func TestHTTPEventSender_SendEvent_MethodAndBody(t *testing.T) {
+	captured := methodCapturedRequest{}
+	server := methodCapturingServer(&captured)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), encodingTestEvent(), models.Destination{Config: models.Configuration{
+		Name:        "encoding_test",
+		CallbackURL: server.URL,
+		HTTPMethod:  "put",
+		HTTPBody:    models.HTTPBodyPayload,
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, "PUT", captured.method)
+	assert.Equal(t, "application/json", captured.header.Get("Content-Type"))
+	assert.JSONEq(t, `{"id":"product_1","price":1000000,"items":[{"sku":"sku_1"}]}`, string(captured.body))
+
+	// Full event by default
+	err = sender.SendEvent(context.Background(), encodingTestEvent(), models.Destination{Config: models.Configuration{Name: "encoding_test", CallbackURL: server.URL}})
+	assert.Nil(t, err)
+	assert.Equal(t, "POST", captured.method)
+	assert.Contains(t, string(captured.body), `"event_key":"product.update"`)
+}
+
+func TestHTTPEventSender_SendEvent_FormEncoding(t *testing.T) {
+	captured := methodCapturedRequest{}
+	server := methodCapturingServer(&captured)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), encodingTestEvent(), models.Destination{Config: models.Configuration{
+		Name:         "encoding_test",
+		CallbackURL:  server.URL,
+		HTTPMethod:   "PATCH",
+		HTTPEncoding: models.HTTPEncodingForm,
+		HTTPBody:     models.HTTPBodyPayload,
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, "PATCH", captured.method)
+	assert.Equal(t, "application/x-www-form-urlencoded", captured.header.Get("Content-Type"))
+	values, _ := url.ParseQuery(string(captured.body))
+	assert.Equal(t, url.Values{"id": {"product_1"}, "price": {"1000000"}, "items[0][sku]": {"sku_1"}}, values)
+}
+
+func TestHTTPEventSender_SendEvent_XMLEncoding(t *testing.T) {
+	captured := methodCapturedRequest{}
+	server := methodCapturingServer(&captured)
+	defer server.Close()
+
+	event := encodingTestEvent()
+	event.Payload["1st note"] = "a < b"
+	sender := &HTTPProxyEventSender{}
+	err := sender.SendEvent(context.Background(), event, models.Destination{Config: models.Configuration{
+		Name:         "encoding_test",
+		CallbackURL:  server.URL,
+		HTTPEncoding: models.HTTPEncodingXML,
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, "application/xml", captured.header.Get("Content-Type"))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<payload><_1st_note>a &lt; b</_1st_note><id>product_1</id><items><item><sku>sku_1</sku></item></items><price>1000000</price></payload>`,
+		string(captured.body))
+}
+
+func TestHTTPEventSender_SendEvent_MsgpackEncoding(t *testing.T) {
+	captured := methodCapturedRequest{}
+	server := methodCapturingServer(&captured)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), encodingTestEvent(), models.Destination{Config: models.Configuration{
+		Name:         "encoding_test",
+		CallbackURL:  server.URL,
+		HTTPEncoding: models.HTTPEncodingMsgpack,
+		HTTPBody:     models.HTTPBodyPayload,
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, "application/msgpack", captured.header.Get("Content-Type"))
+
+	decoded := map[string]interface{}{}
+	assert.Nil(t, msgpack.Unmarshal(captured.body, &decoded))
+	assert.Equal(t, "product_1", decoded["id"])
+	assert.EqualValues(t, 1000000, decoded["price"])
+}
+
+func TestHTTPEventSender_SendEvent_QueryParameters(t *testing.T) {
+	captured := methodCapturedRequest{}
+	server := methodCapturingServer(&captured)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), encodingTestEvent(), models.Destination{Config: models.Configuration{
+		Name:        "encoding_test",
+		CallbackURL: server.URL + "/callback?token=abc",
+		HTTPMethod:  "GET",
+		HTTPBody:    models.HTTPBodyPayload,
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, "GET", captured.method)
+	assert.Empty(t, captured.body)
+	assert.Empty(t, captured.header.Get("Content-Type"))
+	assert.Equal(t, "/callback", captured.url.Path)
+	assert.Equal(t, url.Values{"token": {"abc"}, "id": {"product_1"}, "price": {"1000000"}, "items[0][sku]": {"sku_1"}}, captured.url.Query())
+}
@@ -0,0 +1,130 @@
+package senders_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+func httpTestServer(statusCode int, body string, headers map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for key, value := range headers {
+			w.Header().Set(key, value)
+		}
+		w.WriteHeader(statusCode)
+		w.Write([]byte(body))
+	}))
+}
+
+func httpTestDestination(url string) models.Destination {
+	return models.Destination{Config: models.Configuration{Name: "http_test", CallbackURL: url}}
+}
+
+func TestHTTPEventSender_SendEvent_Success(t *testing.T) {
+	server := httpTestServer(http.StatusCreated, "ok", nil)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update"}, httpTestDestination(server.URL))
+	assert.Nil(t, err)
+}
+
+func TestHTTPEventSender_SendEvent_RetryableStatus(t *testing.T) {
+	for _, status := range []int{http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		server := httpTestServer(status, "try later", map[string]string{"Retry-After": "30", "X-Internal": "secret"})
+
+		sender := &HTTPEventSender{}
+		err := sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update"}, httpTestDestination(server.URL))
+		server.Close()
+
+		dispatcherErr, ok := err.(*captin_errors.DispatcherError)
+		if assert.True(t, ok, "status %d should be retryable", status) {
+			assert.Equal(t, status, dispatcherErr.Response.StatusCode)
+			assert.Equal(t, map[string]string{"Retry-After": "30", "Content-Type": "text/plain; charset=utf-8"}, dispatcherErr.Response.Headers)
+			assert.Equal(t, "try later", dispatcherErr.Response.Body)
+			assert.Equal(t, "http_test", dispatcherErr.Destination.Config.GetName())
+		}
+	}
+}
+
+func TestHTTPEventSender_SendEvent_UnretryableStatus(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusFound} {
+		server := httpTestServer(status, "not found", nil)
+
+		sender := &HTTPEventSender{}
+		err := sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update"}, httpTestDestination(server.URL))
+		server.Close()
+
+		unretryableErr, ok := err.(*captin_errors.UnretryableError)
+		if assert.True(t, ok, "status %d should not be retryable", status) {
+			assert.Equal(t, status, unretryableErr.Response.StatusCode)
+		}
+	}
+}
+
+func TestHTTPEventSender_SendEvent_TruncateBody(t *testing.T) {
+	server := httpTestServer(http.StatusInternalServerError, strings.Repeat("a", 2000), nil)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update"}, httpTestDestination(server.URL))
+	dispatcherErr := err.(*captin_errors.DispatcherError)
+	assert.Equal(t, strings.Repeat("a", 1024)+"...(truncated)", dispatcherErr.Response.Body)
+}
+
+func TestHTTPEventSender_SendEvent_UnboundedBody(t *testing.T) {
+	// Body never ends, it is written until connection is closed by sender
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		chunk := []byte(strings.Repeat("a", 4096))
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	start := time.Now()
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update"}, httpTestDestination(server.URL))
+	assert.True(t, time.Since(start) < 5*time.Second)
+	dispatcherErr := err.(*captin_errors.DispatcherError)
+	assert.Equal(t, strings.Repeat("a", 1024)+"...(truncated)", dispatcherErr.Response.Body)
+}
+
+func TestHTTPEventSender_SendEvent_SuccessCodes(t *testing.T) {
+	server := httpTestServer(http.StatusConflict, "already exists", nil)
+	defer server.Close()
+
+	config := models.Configuration{Name: "http_test", CallbackURL: server.URL, HTTPSuccessCodes: []int{200, 409}}
+
+	sender := &HTTPEventSender{}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update"}, models.Destination{Config: config}))
+
+	// 2xx codes not listed are no longer successful
+	server2 := httpTestServer(http.StatusAccepted, "", nil)
+	defer server2.Close()
+	config.CallbackURL = server2.URL
+	assert.IsType(t, &captin_errors.UnretryableError{}, sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update"}, models.Destination{Config: config}))
+}
+
+func TestHTTPProxyEventSender_SendEvent_Status(t *testing.T) {
+	server := httpTestServer(http.StatusOK, "ok", nil)
+	sender := &HTTPProxyEventSender{}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{Payload: map[string]interface{}{"id": 1}}, httpTestDestination(server.URL)))
+	server.Close()
+
+	server = httpTestServer(http.StatusBadGateway, "bad gateway", nil)
+	defer server.Close()
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{Payload: map[string]interface{}{"id": 1}}, httpTestDestination(server.URL))
+	assert.IsType(t, &captin_errors.DispatcherError{}, err)
+}
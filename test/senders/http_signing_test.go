@@ -0,0 +1,115 @@
+package senders_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	"github.com/shoplineapp/captin/v2/pkg/webhooks"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+type capturedRequest struct {
+	header http.Header
+	url    *url.URL
+	body   []byte
+}
+
+func capturingServer(captured *capturedRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured.header = r.Header
+		captured.url = r.URL
+		captured.body, _ = ioutil.ReadAll(r.Body)
+	}))
+}
+
+func TestHTTPEventSender_SendEvent_StandardWebhooksSignature(t *testing.T) {
+	os.Setenv("TEST_SIGNING_SECRET", "current")
+	os.Setenv("TEST_SIGNING_SECRET_NEXT", "next")
+	defer os.Unsetenv("TEST_SIGNING_SECRET")
+	defer os.Unsetenv("TEST_SIGNING_SECRET_NEXT")
+
+	captured := capturedRequest{}
+	server := capturingServer(&captured)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update", TraceId: "trace_1"}, models.Destination{Config: models.Configuration{
+		Name:           "signing_test",
+		CallbackURL:    server.URL,
+		SigningSecrets: []string{"env:TEST_SIGNING_SECRET", "env:TEST_SIGNING_SECRET_NEXT"},
+	}})
+	assert.Nil(t, err)
+
+	assert.Equal(t, "trace_1", captured.header.Get(webhooks.HeaderID))
+	assert.Nil(t, webhooks.Verify([]string{"current"}, captured.header, captured.body, 0))
+	assert.Nil(t, webhooks.Verify([]string{"next"}, captured.header, captured.body, 0))
+}
+
+func TestHTTPEventSender_SendEvent_QuerySignature(t *testing.T) {
+	os.Setenv("TEST_SIGNING_SECRET", "current")
+	defer os.Unsetenv("TEST_SIGNING_SECRET")
+
+	captured := capturedRequest{}
+	server := capturingServer(&captured)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update", TraceId: "trace_1", TargetId: "product_id"}, models.Destination{Config: models.Configuration{
+		Name:           "signing_test",
+		CallbackURL:    server.URL + "?token=abc",
+		HTTPMethod:     "GET",
+		SigningSecrets: []string{"env:TEST_SIGNING_SECRET"},
+	}})
+	assert.Nil(t, err)
+
+	// Event is carried in query parameters, signature covers them instead of the empty body
+	assert.Empty(t, captured.body)
+	assert.Equal(t, "product_id", captured.url.Query().Get("target_id"))
+	assert.Nil(t, webhooks.Verify([]string{"current"}, captured.header, webhooks.CanonicalQuery(captured.url), 0))
+	assert.Equal(t, webhooks.ErrNoMatch, webhooks.Verify([]string{"current"}, captured.header, captured.body, 0))
+}
+
+func TestHTTPProxyEventSender_SendEvent_HMACSignature(t *testing.T) {
+	os.Setenv("TEST_SIGNING_SECRET", "current")
+	defer os.Unsetenv("TEST_SIGNING_SECRET")
+
+	captured := capturedRequest{}
+	server := capturingServer(&captured)
+	defer server.Close()
+
+	sender := &HTTPProxyEventSender{}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{Payload: map[string]interface{}{"id": 1}}, models.Destination{Config: models.Configuration{
+		Name:           "signing_test",
+		CallbackURL:    server.URL,
+		SigningSecrets: []string{"env:TEST_SIGNING_SECRET"},
+		SigningScheme:  models.SigningSchemeHMAC,
+		SigningHeader:  "X-Partner-Signature",
+	}})
+	assert.Nil(t, err)
+
+	assert.Empty(t, captured.header.Get(webhooks.HeaderSignature))
+	assert.Nil(t, webhooks.VerifyHMAC([]string{"current"}, captured.header.Get("X-Partner-Signature"), captured.body))
+}
+
+func TestHTTPEventSender_SendEvent_MissingSigningSecret(t *testing.T) {
+	captured := capturedRequest{}
+	server := capturingServer(&captured)
+	defer server.Close()
+
+	sender := &HTTPEventSender{}
+	err := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{
+		Name:           "signing_test",
+		CallbackURL:    server.URL,
+		SigningSecrets: []string{"env:TEST_SIGNING_SECRET_MISSING"},
+	}})
+	assert.IsType(t, &captin_errors.UnretryableError{}, err)
+	assert.Nil(t, captured.header)
+}
@@ -0,0 +1,235 @@
+package senders_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	statsd "github.com/joeycumines/statsd"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCertificate struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCertificate(t *testing.T, serial int64, commonName string, notAfter time.Time, parent *testCertificate) testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     []string{commonName},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	parentCert, parentKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	assert.Nil(t, err)
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return testCertificate{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c testCertificate) write(t *testing.T, dir string, name string) (string, string) {
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	assert.Nil(t, ioutil.WriteFile(certPath, c.certPEM, 0600))
+	assert.Nil(t, ioutil.WriteFile(keyPath, c.keyPEM, 0600))
+	return certPath, keyPath
+}
+
+// mtlsServer - Server requiring client certificates issued by CA, responds with serial of client certificate
+func mtlsServer(t *testing.T, ca testCertificate, serverName string, serials *[]int64) *httptest.Server {
+	serverCert := newTestCertificate(t, 100, serverName, time.Now().Add(24*time.Hour), &ca)
+	pair, err := tls.X509KeyPair(serverCert.certPEM, serverCert.keyPEM)
+	assert.Nil(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*serials = append(*serials, r.TLS.PeerCertificates[0].SerialNumber.Int64())
+		// Close connection so that every request does a new handshake
+		w.Header().Set("Connection", "close")
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	server.StartTLS()
+	return server
+}
+
+func TestHTTPEventSender_SendEvent_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, 1, "Test CA", time.Now().Add(24*time.Hour), nil)
+	caPath, _ := ca.write(t, dir, "ca")
+	certPath, keyPath := newTestCertificate(t, 2, "captin", time.Now().Add(24*time.Hour), &ca).write(t, dir, "client")
+
+	serials := []int64{}
+	server := mtlsServer(t, ca, "partner.internal", &serials)
+	defer server.Close()
+
+	sender := &HTTPEventSender{Clients: NewHTTPClientPool(nil)}
+	config := models.Configuration{
+		Name:              "mtls_test",
+		CallbackURL:       server.URL,
+		HTTPCABundle:      caPath,
+		HTTPTLSCert:       certPath,
+		HTTPTLSKey:        keyPath,
+		HTTPTLSServerName: "partner.internal",
+	}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+	assert.Equal(t, []int64{2}, serials)
+
+	// Renewed certificate is picked up on next handshake
+	renewed := newTestCertificate(t, 3, "captin", time.Now().Add(48*time.Hour), &ca)
+	renewed.write(t, dir, "client")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certPath, future, future)
+	os.Chtimes(keyPath, future, future)
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+	assert.Equal(t, []int64{2, 3}, serials)
+
+	// Client certificate is required by server
+	config.Name = "mtls_without_cert"
+	config.HTTPTLSCert = ""
+	config.HTTPTLSKey = ""
+	assert.Error(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+}
+
+func TestHTTPClientPool_CertificateExpiryGauge(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+	client, err := statsd.New(statsd.Address(conn.LocalAddr().String()))
+	assert.Nil(t, err)
+	defer client.Close()
+
+	dir := t.TempDir()
+	ca := newTestCertificate(t, 1, "Test CA", time.Now().Add(24*time.Hour), nil)
+	caPath, _ := ca.write(t, dir, "ca")
+	certPath, keyPath := newTestCertificate(t, 2, "captin", time.Now().Add(24*time.Hour), &ca).write(t, dir, "client")
+
+	serials := []int64{}
+	server := mtlsServer(t, ca, "partner.internal", &serials)
+	defer server.Close()
+
+	config := models.Configuration{
+		Name:              "mtls_gauge",
+		CallbackURL:       server.URL,
+		HTTPCABundle:      caPath,
+		HTTPTLSCert:       certPath,
+		HTTPTLSKey:        keyPath,
+		HTTPTLSServerName: "partner.internal",
+	}
+	readGauge := func() string {
+		client.Flush()
+		received := ""
+		buffer := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		for !strings.Contains(received, "certificate_expiry_seconds") {
+			n, _, err := conn.ReadFrom(buffer)
+			if !assert.Nil(t, err) {
+				break
+			}
+			received += string(buffer[:n])
+		}
+		return received
+	}
+
+	// Reported on load of certificate, before anything is sent
+	pool := NewHTTPClientPool(nil)
+	pool.SetStatsdClient(client)
+	_, err = pool.Client(models.Destination{Config: config})
+	assert.Nil(t, err)
+	assert.Regexp(t, `hook\.sender\.http\.certificate_expiry_seconds,metricname=mtls_gauge,hook=mtls_gauge:8[0-9]{4}\|g`, readGauge())
+
+	// Reported again on reload
+	renewed := newTestCertificate(t, 3, "captin", time.Now().Add(48*time.Hour), &ca)
+	renewed.write(t, dir, "client")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certPath, future, future)
+	os.Chtimes(keyPath, future, future)
+	sender := &HTTPEventSender{Clients: pool}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+	assert.Equal(t, []int64{3}, serials)
+	assert.Regexp(t, `certificate_expiry_seconds,metricname=mtls_gauge,hook=mtls_gauge:1[0-9]{5}\|g`, readGauge())
+}
+
+func TestHTTPEventSender_SendEvent_CertificatePins(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, 1, "Test CA", time.Now().Add(24*time.Hour), nil)
+	caPath, _ := ca.write(t, dir, "ca")
+	certPath, keyPath := newTestCertificate(t, 2, "captin", time.Now().Add(24*time.Hour), &ca).write(t, dir, "client")
+	other := newTestCertificate(t, 9, "Other CA", time.Now().Add(24*time.Hour), nil)
+
+	serials := []int64{}
+	server := mtlsServer(t, ca, "127.0.0.1", &serials)
+	defer server.Close()
+
+	sender := &HTTPEventSender{Clients: NewHTTPClientPool(nil)}
+	config := models.Configuration{
+		Name:         "pins_test",
+		CallbackURL:  server.URL,
+		HTTPCABundle: caPath,
+		HTTPTLSCert:  certPath,
+		HTTPTLSKey:   keyPath,
+		HTTPTLSPins:  []string{helpers.CertificatePin(other.cert), helpers.CertificatePin(ca.cert)},
+	}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+
+	config.HTTPTLSPins = []string{helpers.CertificatePin(other.cert)}
+	assert.Error(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config}))
+	assert.Equal(t, 1, len(serials))
+}
+
+func TestConfiguration_Verify_ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, 1, "Test CA", time.Now().Add(24*time.Hour), nil)
+	certPath, keyPath := newTestCertificate(t, 2, "captin", time.Now().Add(24*time.Hour), &ca).write(t, dir, "valid")
+	expiredCertPath, expiredKeyPath := newTestCertificate(t, 3, "captin", time.Now().Add(-time.Minute), &ca).write(t, dir, "expired")
+
+	assert.Nil(t, models.Configuration{Name: "mtls", HTTPTLSCert: certPath, HTTPTLSKey: keyPath}.Verify())
+	assert.Nil(t, models.Configuration{Name: "mtls", HTTPTLSCert: "file:" + certPath, HTTPTLSKey: "file:" + keyPath}.Verify())
+	assert.Error(t, models.Configuration{Name: "mtls", HTTPTLSCert: expiredCertPath, HTTPTLSKey: expiredKeyPath}.Verify())
+	assert.Error(t, models.Configuration{Name: "mtls", HTTPTLSCert: certPath}.Verify())
+	assert.Error(t, models.Configuration{Name: "mtls", HTTPTLSCert: certPath, HTTPTLSKey: expiredKeyPath}.Verify())
+	assert.Error(t, models.Configuration{Name: "mtls", HTTPTLSPins: []string{"sha1/abc"}}.Verify())
+}
@@ -0,0 +1,197 @@
+package senders_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+// kafkaMockSender - Kafka sender producing to mock producers, which record the config of each producer
+type kafkaMockSender struct {
+	*KafkaSender
+
+	mu        sync.Mutex
+	producers []*mocks.AsyncProducer
+	configs   []*sarama.Config
+	expect    func(producer *mocks.AsyncProducer)
+}
+
+func newKafkaMockSender(t *testing.T, expect func(producer *mocks.AsyncProducer)) *kafkaMockSender {
+	s := &kafkaMockSender{KafkaSender: NewKafkaSender([]string{"localhost:9092"}), expect: expect}
+	s.NewProducer = func(brokers []string, config *sarama.Config) (sarama.AsyncProducer, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		assert.Nil(t, config.Validate())
+		producer := mocks.NewAsyncProducer(t, config)
+		s.expect(producer)
+		s.producers = append(s.producers, producer)
+		s.configs = append(s.configs, config)
+		return producer, nil
+	}
+	return s
+}
+
+func TestKafkaSender_SendEvent_Success(t *testing.T) {
+	sender := newKafkaMockSender(t, func(producer *mocks.AsyncProducer) {
+		producer.ExpectInputWithCheckerFunctionAndSucceed(func(val []byte) error {
+			if !strings.Contains(string(val), `"event_key":"product.update"`) {
+				return errors.New("unexpected record value")
+			}
+			return nil
+		})
+	})
+
+	destination := models.Destination{Config: models.Configuration{Name: "kafka", KafkaTopic: "products"}}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update"}, destination))
+	assert.Nil(t, sender.Close())
+	assert.Len(t, sender.producers, 1)
+	assert.Equal(t, sarama.WaitForAll, sender.configs[0].Producer.RequiredAcks)
+}
+
+func TestKafkaSender_SendEvent_Record(t *testing.T) {
+	sender := newKafkaMockSender(t, func(producer *mocks.AsyncProducer) {})
+	records := make(chan *sarama.ProducerMessage, 1)
+	sender.NewProducer = func(brokers []string, config *sarama.Config) (sarama.AsyncProducer, error) {
+		return &kafkaRecordingProducer{records: records, successes: make(chan *sarama.ProducerMessage, 1), errors: make(chan *sarama.ProducerError, 1)}, nil
+	}
+
+	event := models.IncomingEvent{TraceId: "trace_1", Key: "product.update", Source: "core", TargetType: "Product", TargetId: "product_1"}
+	event.DistributedTracingInfo.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	destination := models.Destination{Config: models.Configuration{Name: "kafka", KafkaTopic: "products"}}
+	assert.Nil(t, sender.SendEvent(context.Background(), event, destination))
+	assert.Nil(t, sender.Close())
+
+	record := <-records
+	assert.Equal(t, "products", record.Topic)
+	key, _ := record.Key.Encode()
+	assert.Equal(t, "product_1", string(key))
+
+	headers := map[string]string{}
+	for _, header := range record.Headers {
+		headers[string(header.Key)] = string(header.Value)
+	}
+	assert.Equal(t, "product.update", headers["event_key"])
+	assert.Equal(t, "core", headers["source"])
+	assert.NotEmpty(t, headers["traceparent"])
+}
+
+func TestKafkaSender_SendEvent_PartitionKeyTemplate(t *testing.T) {
+	sender := newKafkaMockSender(t, func(producer *mocks.AsyncProducer) {})
+	records := make(chan *sarama.ProducerMessage, 1)
+	sender.NewProducer = func(brokers []string, config *sarama.Config) (sarama.AsyncProducer, error) {
+		return &kafkaRecordingProducer{records: records, successes: make(chan *sarama.ProducerMessage, 1), errors: make(chan *sarama.ProducerError, 1)}, nil
+	}
+
+	config := models.Configuration{Name: "kafka", KafkaPartitionKey: "{{ .target_type }}-{{ .target_id }}"}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{TargetType: "Product", TargetId: "product_1"}, models.Destination{Config: config}))
+	key, _ := (<-records).Key.Encode()
+	assert.Equal(t, "Product-product_1", string(key))
+
+	config.KafkaPartitionKey = "{{ .target_id"
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+	assert.IsType(t, &captin_errors.UnretryableError{}, result)
+}
+
+func TestKafkaSender_SendEvent_Failed(t *testing.T) {
+	sender := newKafkaMockSender(t, func(producer *mocks.AsyncProducer) {
+		producer.ExpectInputAndFail(errors.New("kafka: broker not available"))
+	})
+
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{Name: "failed"}})
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+	assert.Contains(t, result.Error(), "broker not available")
+	assert.Nil(t, sender.Close())
+}
+
+func TestKafkaSender_SendEvent_ProducerSettings(t *testing.T) {
+	sender := newKafkaMockSender(t, func(producer *mocks.AsyncProducer) {
+		producer.ExpectInputAndSucceed()
+	})
+
+	idempotent := models.Configuration{Name: "idempotent", KafkaIdempotent: true, KafkaLinger: "50ms", KafkaBatchSize: 100}
+	leader := models.Configuration{Name: "leader", KafkaAcks: models.KafkaAcksLeader}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: idempotent}))
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: leader}))
+	assert.Nil(t, sender.Close())
+
+	assert.Len(t, sender.configs, 2)
+	assert.True(t, sender.configs[0].Producer.Idempotent)
+	assert.Equal(t, sarama.WaitForAll, sender.configs[0].Producer.RequiredAcks)
+	assert.Equal(t, 1, sender.configs[0].Net.MaxOpenRequests)
+	assert.Equal(t, 50*time.Millisecond, sender.configs[0].Producer.Flush.Frequency)
+	assert.Equal(t, 100, sender.configs[0].Producer.Flush.Messages)
+	assert.Equal(t, sarama.WaitForLocal, sender.configs[1].Producer.RequiredAcks)
+}
+
+func TestKafkaSender_SendEvent_Concurrent(t *testing.T) {
+	sender := newKafkaMockSender(t, func(producer *mocks.AsyncProducer) {
+		for i := 0; i < 10; i++ {
+			producer.ExpectInputAndSucceed()
+		}
+	})
+
+	wg := sync.WaitGroup{}
+	results := make([]error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{Name: "kafka"}})
+		}(i)
+	}
+	wg.Wait()
+	assert.Nil(t, sender.Close())
+	assert.Equal(t, make([]error, 10), results)
+	assert.Len(t, sender.producers, 1)
+}
+
+func TestKafkaSender_SendEvent_AfterClose(t *testing.T) {
+	sender := newKafkaMockSender(t, func(producer *mocks.AsyncProducer) {})
+	assert.Nil(t, sender.Close())
+
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{Name: "kafka"}})
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+	assert.Len(t, sender.producers, 0)
+}
+
+// kafkaRecordingProducer - Producer succeeding every record and keeping it for inspection
+type kafkaRecordingProducer struct {
+	sarama.AsyncProducer
+
+	records   chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+}
+
+func (p *kafkaRecordingProducer) Input() chan<- *sarama.ProducerMessage {
+	input := make(chan *sarama.ProducerMessage)
+	go func() {
+		message := <-input
+		p.records <- message
+		p.successes <- message
+	}()
+	return input
+}
+
+func (p *kafkaRecordingProducer) Successes() <-chan *sarama.ProducerMessage {
+	return p.successes
+}
+
+func (p *kafkaRecordingProducer) Errors() <-chan *sarama.ProducerError {
+	return p.errors
+}
+
+func (p *kafkaRecordingProducer) AsyncClose() {
+	close(p.successes)
+	close(p.errors)
+}
@@ -0,0 +1,137 @@
+package senders_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+// runNatsServer - Start embedded NATS server with JetStream and stream of "captin.>" subjects
+func runNatsServer(t *testing.T) (*server.Server, *nats.Conn, func()) {
+	storeDir, err := ioutil.TempDir("", "captin-nats")
+	assert.Nil(t, err)
+
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: storeDir})
+	assert.Nil(t, err)
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server is not ready")
+	}
+
+	conn, err := nats.Connect(s.ClientURL())
+	assert.Nil(t, err)
+	js, err := conn.JetStream()
+	assert.Nil(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "CAPTIN", Subjects: []string{"captin.>"}, Duplicates: time.Minute})
+	assert.Nil(t, err)
+
+	return s, conn, func() {
+		conn.Close()
+		s.Shutdown()
+		os.RemoveAll(storeDir)
+	}
+}
+
+func TestNatsSender_SendEvent_Subject(t *testing.T) {
+	s, conn, shutdown := runNatsServer(t)
+	defer shutdown()
+
+	sub, err := conn.SubscribeSync("events.>")
+	assert.Nil(t, err)
+	conn.Flush()
+
+	sender := NewNatsSender(s.ClientURL())
+	defer sender.Close()
+
+	event := models.IncomingEvent{TraceId: "trace_1", Key: "product.update", Source: "core", TargetType: "Product"}
+	event.DistributedTracingInfo.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	config := models.Configuration{Name: "nats", NatsSubject: "events.{{ .event_key }}"}
+	assert.Nil(t, sender.SendEvent(context.Background(), event, models.Destination{Config: config}))
+
+	msg, err := sub.NextMsg(time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "events.product.update", msg.Subject)
+	assert.Contains(t, string(msg.Data), `"event_key":"product.update"`)
+	assert.Equal(t, "product.update", msg.Header.Get("event_key"))
+	assert.Equal(t, "core", msg.Header.Get("source"))
+	assert.NotEmpty(t, msg.Header.Get("traceparent"))
+}
+
+func TestNatsSender_SendEvent_InvalidSubject(t *testing.T) {
+	sender := NewNatsSender(nats.DefaultURL)
+
+	config := models.Configuration{Name: "nats", NatsSubject: "events.{{ .event_key"}
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+	assert.IsType(t, &captin_errors.UnretryableError{}, result)
+}
+
+func TestNatsSender_SendEvent_ConnectFailed(t *testing.T) {
+	sender := NewNatsSender("nats://127.0.0.1:1")
+
+	config := models.Configuration{Name: "nats", NatsSubject: "events.product"}
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+}
+
+func TestNatsSender_SendEvent_JetStream(t *testing.T) {
+	s, conn, shutdown := runNatsServer(t)
+	defer shutdown()
+
+	sender := NewNatsSender(s.ClientURL())
+	defer sender.Close()
+
+	config := models.Configuration{Name: "nats", NatsSubject: "captin.{{ .event_key }}", NatsJetStream: true}
+	event := models.IncomingEvent{TraceId: "trace_1", Key: "product.update"}
+	assert.Nil(t, sender.SendEvent(context.Background(), event, models.Destination{Config: config}))
+	// Same trace ID is deduplicated by stream
+	assert.Nil(t, sender.SendEvent(context.Background(), event, models.Destination{Config: config}))
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{TraceId: "trace_2", Key: "product.update"}, models.Destination{Config: config}))
+
+	js, _ := conn.JetStream()
+	info, err := js.StreamInfo("CAPTIN")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), info.State.Msgs)
+
+	msg, err := js.GetMsg("CAPTIN", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "captin.product.update", msg.Subject)
+	assert.Equal(t, "trace_1", msg.Header.Get(nats.MsgIdHdr))
+}
+
+func TestNatsSender_SendEvent_JetStreamDeduplicationID(t *testing.T) {
+	s, conn, shutdown := runNatsServer(t)
+	defer shutdown()
+
+	sender := NewNatsSender(s.ClientURL())
+	defer sender.Close()
+
+	config := models.Configuration{Name: "nats", NatsSubject: "captin.{{ .event_key }}", NatsJetStream: true, DeduplicationID: "{{ .trace_id }}-{{ .hook }}"}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{TraceId: "trace_1", Key: "product.update"}, models.Destination{Config: config}))
+
+	js, _ := conn.JetStream()
+	msg, err := js.GetMsg("CAPTIN", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "trace_1-nats", msg.Header.Get(nats.MsgIdHdr))
+}
+
+func TestNatsSender_SendEvent_JetStreamNoStream(t *testing.T) {
+	s, _, shutdown := runNatsServer(t)
+	defer shutdown()
+
+	sender := NewNatsSender(s.ClientURL())
+	defer sender.Close()
+
+	// Subject is not bound to any stream, the missing ack is retryable
+	config := models.Configuration{Name: "nats", NatsSubject: "unknown.{{ .event_key }}", NatsJetStream: true, NatsAckTimeout: "200ms"}
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{TraceId: "trace_1", Key: "product.update"}, models.Destination{Config: config})
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+}
@@ -0,0 +1,247 @@
+package senders_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+var pushDestination = models.Destination{Config: models.Configuration{Name: "dashboard", PushChannel: "merchant:{{ .control.merchant_id }}"}}
+
+func pushEvent(key string) models.IncomingEvent {
+	return models.IncomingEvent{Key: key, Control: map[string]interface{}{"merchant_id": "1"}}
+}
+
+// readSSE - Read next event of stream as fields, comments are skipped
+func readSSE(t *testing.T, reader *bufio.Reader) map[string]string {
+	fields := map[string]string{}
+	for {
+		line, err := reader.ReadString('\n')
+		if !assert.Nil(t, err) {
+			return fields
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		parts := strings.SplitN(line, ": ", 2)
+		fields[parts[0]] = parts[1]
+	}
+}
+
+func TestPushSender_ServeHTTP_Stream(t *testing.T) {
+	sender := NewPushSender(10, PushPolicyDisconnect, 10, time.Minute)
+	server := httptest.NewServer(sender)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "?channel=merchant:1")
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	assert.Nil(t, sender.SendEvent(context.Background(), pushEvent("order.create"), pushDestination))
+	// Event of other channel is not received
+	other := models.IncomingEvent{Key: "order.create", Control: map[string]interface{}{"merchant_id": "2"}}
+	assert.Nil(t, sender.SendEvent(context.Background(), other, pushDestination))
+	assert.Nil(t, sender.SendEvent(context.Background(), pushEvent("order.update"), pushDestination))
+
+	reader := bufio.NewReader(res.Body)
+	event := readSSE(t, reader)
+	assert.Equal(t, "1", event["id"])
+	assert.Equal(t, "order.create", event["event"])
+	assert.Contains(t, event["data"], `"event_key":"order.create"`)
+
+	event = readSSE(t, reader)
+	assert.Equal(t, "2", event["id"])
+	assert.Equal(t, "order.update", event["event"])
+}
+
+func TestPushSender_ServeHTTP_Replay(t *testing.T) {
+	sender := NewPushSender(10, PushPolicyDisconnect, 3, time.Minute)
+	server := httptest.NewServer(sender)
+	defer server.Close()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		assert.Nil(t, sender.SendEvent(context.Background(), pushEvent(key), pushDestination))
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"?channel=merchant:1", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	reader := bufio.NewReader(res.Body)
+	assert.Equal(t, "3", readSSE(t, reader)["id"])
+	assert.Equal(t, "4", readSSE(t, reader)["id"])
+	res.Body.Close()
+
+	// History is bounded, unknown ID replays what is kept
+	req.Header.Set("Last-Event-ID", "unknown")
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	reader = bufio.NewReader(res.Body)
+	assert.Equal(t, "b", readSSE(t, reader)["event"])
+	assert.Equal(t, "c", readSSE(t, reader)["event"])
+	assert.Equal(t, "d", readSSE(t, reader)["event"])
+	res.Body.Close()
+}
+
+func TestPushSender_ServeHTTP_Unauthorized(t *testing.T) {
+	sender := NewPushSender(10, PushPolicyDisconnect, 10, time.Minute)
+	sender.Authorize = func(r *http.Request, channel string) bool {
+		return r.Header.Get("Authorization") == "Bearer merchant_1" && channel == "merchant:1"
+	}
+	server := httptest.NewServer(sender)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, err = http.Get(server.URL + "?channel=merchant:2")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func TestPushSender_SendEvent_InvalidChannel(t *testing.T) {
+	sender := NewPushSender(10, PushPolicyDisconnect, 10, time.Minute)
+	destination := models.Destination{Config: models.Configuration{Name: "dashboard", PushChannel: "merchant:{{ .control.merchant_id"}}
+	result := sender.SendEvent(context.Background(), pushEvent("order.create"), destination)
+	assert.IsType(t, &captin_errors.UnretryableError{}, result)
+}
+
+// blockingResponseWriter - Response writer blocking writes until released, for simulating slow consumers
+type blockingResponseWriter struct {
+	header  http.Header
+	writing chan struct{}
+	release chan struct{}
+
+	mu   sync.Mutex
+	body strings.Builder
+}
+
+func newBlockingResponseWriter() *blockingResponseWriter {
+	return &blockingResponseWriter{header: http.Header{}, writing: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (w *blockingResponseWriter) Header() http.Header { return w.header }
+func (w *blockingResponseWriter) WriteHeader(int)     {}
+func (w *blockingResponseWriter) Flush()              {}
+
+func (w *blockingResponseWriter) Write(data []byte) (int, error) {
+	w.writing <- struct{}{}
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.body.Write(data)
+}
+
+func (w *blockingResponseWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.body.String()
+}
+
+// serveSlowConsumer - Publish events to subscriber with buffer of 1 while it is blocked by writing the first one
+func serveSlowConsumer(t *testing.T, policy string) (*blockingResponseWriter, chan struct{}, context.CancelFunc) {
+	sender := NewPushSender(1, policy, 10, time.Minute)
+	w := newBlockingResponseWriter()
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/?channel=merchant:1", nil).WithContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		sender.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	// Wait for subscription before publishing
+	assert.Eventually(t, func() bool {
+		assert.Nil(t, sender.SendEvent(context.Background(), pushEvent("a"), pushDestination))
+		select {
+		case <-w.writing:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	assert.Nil(t, sender.SendEvent(context.Background(), pushEvent("b"), pushDestination))
+	assert.Nil(t, sender.SendEvent(context.Background(), pushEvent("c"), pushDestination))
+
+	go func() {
+		for range w.writing {
+			w.release <- struct{}{}
+		}
+	}()
+	w.release <- struct{}{}
+	return w, done, cancel
+}
+
+func TestPushSender_SlowConsumer_Disconnect(t *testing.T) {
+	_, done, cancel := serveSlowConsumer(t, PushPolicyDisconnect)
+	defer cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected slow consumer to be disconnected")
+	}
+}
+
+func TestPushSender_SlowConsumer_DropOldest(t *testing.T) {
+	w, done, cancel := serveSlowConsumer(t, PushPolicyDropOldest)
+
+	assert.Eventually(t, func() bool { return strings.Contains(w.String(), "event: c") }, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	assert.NotContains(t, w.String(), "event: b")
+}
+
+func TestPushSender_SlowConsumer_DropNewest(t *testing.T) {
+	w, done, cancel := serveSlowConsumer(t, PushPolicyDropNewest)
+
+	assert.Eventually(t, func() bool { return strings.Contains(w.String(), "event: b") }, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	assert.NotContains(t, w.String(), "event: c")
+}
+
+func TestPushSender_WebSocketHandler(t *testing.T) {
+	sender := NewPushSender(10, PushPolicyDisconnect, 10, time.Minute)
+	server := httptest.NewServer(sender.WebSocketHandler(websocket.Upgrader{}))
+	defer server.Close()
+
+	assert.Nil(t, sender.SendEvent(context.Background(), pushEvent("order.create"), pushDestination))
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?channel=merchant:1&last_event_id=0"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	assert.Nil(t, sender.SendEvent(context.Background(), pushEvent("order.update"), pushDestination))
+
+	for _, expected := range []string{"order.create", "order.update"} {
+		frame := map[string]interface{}{}
+		assert.Nil(t, conn.ReadJSON(&frame))
+		assert.Equal(t, expected, frame["event"])
+		data, _ := json.Marshal(frame["data"])
+		assert.Contains(t, string(data), `"event_key":"`+expected+`"`)
+	}
+}
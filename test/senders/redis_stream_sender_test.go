@@ -0,0 +1,83 @@
+package senders_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRedisStreamSender(t *testing.T) (*RedisStreamSender, *miniredis.Miniredis, *redis.Client) {
+	server, err := miniredis.Run()
+	assert.Nil(t, err)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	return NewRedisStreamSender(client), server, client
+}
+
+func TestRedisStreamSender_SendEvent_Success(t *testing.T) {
+	sender, server, client := newRedisStreamSender(t)
+	defer server.Close()
+
+	event := models.IncomingEvent{TraceId: "trace_1", Key: "product.update", Source: "core", TargetType: "Product", TargetId: "product_1"}
+	event.DistributedTracingInfo.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	assert.Nil(t, sender.SendEvent(context.Background(), event, models.Destination{Config: models.Configuration{Name: "product_stream"}}))
+
+	entries, err := client.XRange(context.Background(), "product_stream", "-", "+").Result()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+	values := entries[0].Values
+	assert.Equal(t, "product.update", values["event_key"])
+	assert.Equal(t, "core", values["source"])
+	assert.Equal(t, "Product", values["target_type"])
+	assert.Equal(t, "product_1", values["target_id"])
+	assert.NotEmpty(t, values["traceparent"])
+	assert.Contains(t, values[RedisStreamBodyField], `"event_key":"product.update"`)
+}
+
+func TestRedisStreamSender_SendEvent_StreamTemplate(t *testing.T) {
+	sender, server, client := newRedisStreamSender(t)
+	defer server.Close()
+
+	config := models.Configuration{Name: "stream", RedisStream: "captin:{{ .target_type }}"}
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{TargetType: "Product"}, models.Destination{Config: config}))
+	length, err := client.XLen(context.Background(), "captin:Product").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), length)
+
+	config.RedisStream = "captin:{{ .target_type"
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+	assert.IsType(t, &captin_errors.UnretryableError{}, result)
+}
+
+func TestRedisStreamSender_SendEvent_MaxLen(t *testing.T) {
+	sender, server, client := newRedisStreamSender(t)
+	defer server.Close()
+
+	config := models.Configuration{Name: "trimmed", RedisStreamMaxLen: 2, RedisStreamTrimExact: true}
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{Key: key}, models.Destination{Config: config}))
+	}
+
+	entries, err := client.XRange(context.Background(), "trimmed", "-", "+").Result()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "b", entries[0].Values["event_key"])
+	assert.Equal(t, "c", entries[1].Values["event_key"])
+
+	// Approximate trimming is accepted as well
+	config.RedisStreamTrimExact = false
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{Key: "d"}, models.Destination{Config: config}))
+}
+
+func TestRedisStreamSender_SendEvent_Failed(t *testing.T) {
+	sender, server, _ := newRedisStreamSender(t)
+	server.Close()
+
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: models.Configuration{Name: "failed"}})
+	assert.IsType(t, &captin_errors.DispatcherError{}, result)
+}
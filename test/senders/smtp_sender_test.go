@@ -0,0 +1,285 @@
+package senders_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	smtp "github.com/emersion/go-smtp"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+)
+
+// smtpTestBackend - In-process SMTP server accepting emails of user "captin", recipients are rejected with rcptError if set
+type smtpTestBackend struct {
+	mu        sync.Mutex
+	rcptError *smtp.SMTPError
+	messages  []smtpTestMessage
+}
+
+type smtpTestMessage struct {
+	from string
+	to   []string
+	data []byte
+	auth string
+}
+
+func (b *smtpTestBackend) NewSession(c *smtp.Conn) (smtp.Session, error) {
+	return &smtpTestSession{backend: b}, nil
+}
+
+func (b *smtpTestBackend) received() []smtpTestMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]smtpTestMessage{}, b.messages...)
+}
+
+type smtpTestSession struct {
+	backend *smtpTestBackend
+	message smtpTestMessage
+}
+
+func (s *smtpTestSession) AuthPlain(username, password string) error {
+	if username != "captin" || password != "smtp-secret" {
+		return &smtp.SMTPError{Code: 535, EnhancedCode: smtp.EnhancedCode{5, 7, 8}, Message: "invalid credentials"}
+	}
+	s.message.auth = username
+	return nil
+}
+
+func (s *smtpTestSession) Mail(from string, opts *smtp.MailOptions) error {
+	s.message.from = from
+	return nil
+}
+
+func (s *smtpTestSession) Rcpt(to string) error {
+	s.backend.mu.Lock()
+	defer s.backend.mu.Unlock()
+	if s.backend.rcptError != nil {
+		return s.backend.rcptError
+	}
+	s.message.to = append(s.message.to, to)
+	return nil
+}
+
+func (s *smtpTestSession) Data(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.message.data = data
+	s.backend.mu.Lock()
+	s.backend.messages = append(s.backend.messages, s.message)
+	s.backend.mu.Unlock()
+	return nil
+}
+
+func (s *smtpTestSession) Reset() {
+	s.message = smtpTestMessage{auth: s.message.auth}
+}
+
+func (s *smtpTestSession) Logout() error {
+	return nil
+}
+
+// smtpTestServer - Start SMTP server on localhost, STARTTLS is offered if certificate is given
+func smtpTestServer(t *testing.T, backend *smtpTestBackend, cert *testCertificate) (string, func()) {
+	server := smtp.NewServer(backend)
+	server.Domain = "localhost"
+	server.ReadTimeout = 5 * time.Second
+	server.WriteTimeout = 5 * time.Second
+	if cert != nil {
+		pair, err := tls.X509KeyPair(cert.certPEM, cert.keyPEM)
+		assert.Nil(t, err)
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{pair}}
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go server.Serve(listener)
+	return listener.Addr().String(), func() { server.Close() }
+}
+
+func smtpTestSender(cert testCertificate) *SMTPSender {
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.cert)
+	return &SMTPSender{From: "Captin <captin@example.com>", TLSConfig: &tls.Config{RootCAs: pool}}
+}
+
+func smtpEvent() models.IncomingEvent {
+	return models.IncomingEvent{
+		Key:        "order.refund_failed",
+		TargetType: "Order",
+		TargetId:   "o1",
+		Payload:    map[string]interface{}{"email": "billing@partner.example", "reason": "<expired>"},
+	}
+}
+
+func TestSMTPSender_SendEvent(t *testing.T) {
+	os.Setenv("TEST_SMTP_PASSWORD", "smtp-secret")
+	defer os.Unsetenv("TEST_SMTP_PASSWORD")
+
+	cert := newTestCertificate(t, 1, "localhost", time.Now().Add(time.Hour), nil)
+	backend := &smtpTestBackend{}
+	addr, stop := smtpTestServer(t, backend, &cert)
+	defer stop()
+
+	destination := models.Destination{Config: models.Configuration{
+		Name:         "partner_refunds",
+		CallbackURL:  addr,
+		SMTPTo:       "{{ .payload.email }}, Ops <ops@example.com>",
+		SMTPSubject:  "Refund of {{ .target_id }} failed",
+		SMTPTextBody: "Reason: {{ .payload.reason }}",
+		SMTPHTMLBody: "<p>Reason: {{ .payload.reason }}</p>",
+		SMTPUsername: "captin",
+		SMTPPassword: "env:TEST_SMTP_PASSWORD",
+	}}
+
+	assert.Nil(t, smtpTestSender(cert).SendEvent(context.Background(), smtpEvent(), destination))
+
+	received := backend.received()
+	assert.Len(t, received, 1)
+	assert.Equal(t, "captin", received[0].auth)
+	assert.Equal(t, "captin@example.com", received[0].from)
+	assert.Equal(t, []string{"billing@partner.example", "ops@example.com"}, received[0].to)
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(received[0].data)))
+	assert.Nil(t, err)
+	assert.Equal(t, "Refund of o1 failed", msg.Header.Get("Subject"))
+	assert.Equal(t, `"Captin" <captin@example.com>`, msg.Header.Get("From"))
+	assert.Equal(t, `<billing@partner.example>, "Ops" <ops@example.com>`, msg.Header.Get("To"))
+
+	// Bodies are alternatives, payload is attached as JSON file
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.Nil(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+	mixed := multipart.NewReader(msg.Body, params["boundary"])
+
+	part, err := mixed.NextPart()
+	assert.Nil(t, err)
+	mediaType, params, _ = mime.ParseMediaType(part.Header.Get("Content-Type"))
+	assert.Equal(t, "multipart/alternative", mediaType)
+	alternative := multipart.NewReader(part, params["boundary"])
+	text, err := alternative.NextPart()
+	assert.Nil(t, err)
+	textBody, _ := ioutil.ReadAll(text)
+	assert.Equal(t, "Reason: <expired>", string(textBody))
+	html, err := alternative.NextPart()
+	assert.Nil(t, err)
+	assert.Equal(t, "text/html; charset=utf-8", html.Header.Get("Content-Type"))
+	htmlBody, _ := ioutil.ReadAll(html)
+	assert.Equal(t, "<p>Reason: &lt;expired&gt;</p>", string(htmlBody))
+
+	attachment, err := mixed.NextPart()
+	assert.Nil(t, err)
+	assert.Equal(t, "order.refund_failed.json", attachment.FileName())
+	assert.Equal(t, "base64", attachment.Header.Get("Content-Transfer-Encoding"))
+	// multipart reader does not decode base64
+	encoded, _ := ioutil.ReadAll(attachment)
+	assert.Contains(t, string(decodeBase64Lines(t, encoded)), `"reason": "<expired>"`)
+}
+
+func TestSMTPSender_SendEvent_PlainText(t *testing.T) {
+	backend := &smtpTestBackend{}
+	addr, stop := smtpTestServer(t, backend, nil)
+	defer stop()
+
+	destination := models.Destination{Config: models.Configuration{
+		Name:        "partner_refunds",
+		CallbackURL: addr,
+		SMTPFrom:    "refunds@example.com",
+		SMTPTo:      "billing@partner.example",
+		SMTPTLS:     models.SMTPTLSNone,
+	}}
+	sender := &SMTPSender{}
+
+	assert.Nil(t, sender.SendEvent(context.Background(), smtpEvent(), destination))
+	received := backend.received()
+	assert.Len(t, received, 1)
+	msg, err := mail.ReadMessage(strings.NewReader(string(received[0].data)))
+	assert.Nil(t, err)
+	assert.Equal(t, "[order.refund_failed] Order o1", msg.Header.Get("Subject"))
+	body, _ := ioutil.ReadAll(msg.Body)
+	assert.Contains(t, string(body), "Content-Type: text/plain; charset=utf-8")
+	assert.NotContains(t, string(body), "text/html")
+}
+
+func TestSMTPSender_SendEvent_StartTLSRequired(t *testing.T) {
+	backend := &smtpTestBackend{}
+	addr, stop := smtpTestServer(t, backend, nil)
+	defer stop()
+
+	destination := models.Destination{Config: models.Configuration{Name: "partner_refunds", CallbackURL: addr, SMTPTo: "billing@partner.example"}}
+	sender := &SMTPSender{From: "captin@example.com"}
+
+	assert.IsType(t, &captin_errors.UnretryableError{}, sender.SendEvent(context.Background(), smtpEvent(), destination))
+	assert.Len(t, backend.received(), 0)
+}
+
+func TestSMTPSender_SendEvent_ReplyCodes(t *testing.T) {
+	os.Setenv("TEST_SMTP_PASSWORD", "wrong")
+	defer os.Unsetenv("TEST_SMTP_PASSWORD")
+
+	cert := newTestCertificate(t, 1, "localhost", time.Now().Add(time.Hour), nil)
+	backend := &smtpTestBackend{}
+	addr, stop := smtpTestServer(t, backend, &cert)
+	defer stop()
+
+	destination := models.Destination{Config: models.Configuration{Name: "partner_refunds", CallbackURL: addr, SMTPTo: "billing@partner.example"}}
+	sender := smtpTestSender(cert)
+
+	// Transient failure is retried
+	backend.rcptError = &smtp.SMTPError{Code: 450, EnhancedCode: smtp.EnhancedCode{4, 2, 1}, Message: "mailbox busy"}
+	assert.IsType(t, &captin_errors.DispatcherError{}, sender.SendEvent(context.Background(), smtpEvent(), destination))
+
+	// Permanent failure is not
+	backend.rcptError = &smtp.SMTPError{Code: 550, EnhancedCode: smtp.EnhancedCode{5, 1, 1}, Message: "no such user"}
+	assert.IsType(t, &captin_errors.UnretryableError{}, sender.SendEvent(context.Background(), smtpEvent(), destination))
+
+	// Rejected credentials are not retried
+	backend.rcptError = nil
+	withAuth := models.Destination{Config: models.Configuration{
+		Name:         "partner_refunds",
+		CallbackURL:  addr,
+		SMTPTo:       "billing@partner.example",
+		SMTPUsername: "captin",
+		SMTPPassword: "env:TEST_SMTP_PASSWORD",
+	}}
+	assert.IsType(t, &captin_errors.UnretryableError{}, sender.SendEvent(context.Background(), smtpEvent(), withAuth))
+	assert.Len(t, backend.received(), 0)
+
+	// Unreachable server is retried
+	stop()
+	assert.IsType(t, &captin_errors.DispatcherError{}, sender.SendEvent(context.Background(), smtpEvent(), destination))
+}
+
+func TestSMTPSender_SendEvent_InvalidTemplates(t *testing.T) {
+	sender := &SMTPSender{From: "captin@example.com"}
+	for name, config := range map[string]models.Configuration{
+		"no recipients":      {Name: "partner_refunds", CallbackURL: "127.0.0.1:25", SMTPTo: " "},
+		"invalid recipients": {Name: "partner_refunds", CallbackURL: "127.0.0.1:25", SMTPTo: "not an address"},
+		"failing subject":    {Name: "partner_refunds", CallbackURL: "127.0.0.1:25", SMTPTo: "ops@example.com", SMTPSubject: "{{ .payload.reason.code }}"},
+	} {
+		err := sender.SendEvent(context.Background(), smtpEvent(), models.Destination{Config: config})
+		assert.IsType(t, &captin_errors.UnretryableError{}, err, name)
+	}
+}
+
+func decodeBase64Lines(t *testing.T, encoded []byte) []byte {
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(encoded)), ""))
+	assert.Nil(t, err)
+	return decoded
+}
@@ -0,0 +1,188 @@
+package senders_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	aws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	aws_sns "github.com/aws/aws-sdk-go/service/sns"
+	aws_snsiface "github.com/aws/aws-sdk-go/service/sns/snsiface"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
+	models "github.com/shoplineapp/captin/v2/models"
+	. "github.com/shoplineapp/captin/v2/senders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type snsMock struct {
+	aws_snsiface.SNSAPI
+	mock.Mock
+
+	PublishedMessages []aws_sns.PublishInput
+}
+
+func (s *snsMock) PublishWithContext(ctx context.Context, input *aws_sns.PublishInput, _ ...request.Option) (*aws_sns.PublishOutput, error) {
+	s.PublishedMessages = append(s.PublishedMessages, *input)
+	_ = s.Called(input)
+
+	payload := map[string]interface{}{}
+	json.Unmarshal([]byte(*input.Message), &payload)
+
+	// Throw error manually
+	if payload != nil && payload["control"] != nil && payload["control"].(map[string]interface{})["result"] == "failed" {
+		return nil, errors.New("SNSError: some error")
+	}
+	return &aws_sns.PublishOutput{MessageId: aws.String("message_1")}, nil
+}
+
+func TestSnsSender_SendEvent_Success(t *testing.T) {
+	sender := NewSnsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+
+	sns := new(snsMock)
+	sns.On("PublishWithContext", mock.Anything).Return(nil)
+	sender.DefaultClient = sns
+
+	result := sender.SendEvent(
+		context.Background(),
+		models.IncomingEvent{Key: "product.update"},
+		models.Destination{
+			Config: models.Configuration{CallbackURL: "arn:aws:sns:ap-southeast-1:000000000000:topic"},
+		},
+	)
+
+	assert.Nil(t, result)
+	sns.AssertNumberOfCalls(t, "PublishWithContext", 1)
+	assert.Equal(t, "arn:aws:sns:ap-southeast-1:000000000000:topic", *sns.PublishedMessages[0].TopicArn)
+	assert.Contains(t, *sns.PublishedMessages[0].Message, `"event_key":"product.update"`)
+}
+
+func TestSnsSender_SendEvent_Failed(t *testing.T) {
+	sender := NewSnsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+
+	sns := new(snsMock)
+	sns.On("PublishWithContext", mock.Anything).Return(nil)
+	sender.DefaultClient = sns
+
+	result := sender.SendEvent(
+		context.Background(),
+		models.IncomingEvent{Control: map[string]interface{}{"result": "failed"}},
+		models.Destination{Config: models.Configuration{Name: "failed"}},
+	)
+
+	assert.Error(t, result, "some error")
+	sns.AssertNumberOfCalls(t, "PublishWithContext", 1)
+}
+
+func TestSnsSender_SendEvent_InvalidMessageID(t *testing.T) {
+	sender := NewSnsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+
+	sns := new(snsMock)
+	sns.On("PublishWithContext", mock.Anything).Return(nil)
+	sender.DefaultClient = sns
+
+	config := models.Configuration{Name: "fifo", MessageGroupID: "{{ .target_id "}
+	result := sender.SendEvent(context.Background(), models.IncomingEvent{}, models.Destination{Config: config})
+
+	assert.IsType(t, &captin_errors.UnretryableError{}, result)
+	sns.AssertNumberOfCalls(t, "PublishWithContext", 0)
+}
+
+func TestSnsSender_SendEvent_FifoAndAttributes(t *testing.T) {
+	sender := NewSnsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+
+	sns := new(snsMock)
+	sns.On("PublishWithContext", mock.Anything).Return(nil)
+	sender.DefaultClient = sns
+
+	config := models.Configuration{
+		Name:            "fifo",
+		CallbackURL:     "arn:aws:sns:ap-southeast-1:000000000000:topic.fifo",
+		MessageGroupID:  "{{ .target_id }}",
+		DeduplicationID: "{{ .trace_id }}-{{ .hook }}",
+	}
+	event := models.IncomingEvent{
+		TraceId:    "trace_1",
+		Key:        "product.update",
+		Source:     "core",
+		TargetType: "Product",
+		TargetId:   strings.Repeat("a", 200),
+	}
+	assert.Nil(t, sender.SendEvent(context.Background(), event, models.Destination{Config: config}))
+
+	input := sns.PublishedMessages[0]
+	assert.Len(t, *input.MessageGroupId, 64)
+	assert.Equal(t, "trace_1-fifo", *input.MessageDeduplicationId)
+
+	attributes := map[string]string{}
+	for key, value := range input.MessageAttributes {
+		assert.Equal(t, "String", *value.DataType)
+		attributes[key] = *value.StringValue
+	}
+	assert.Equal(t, "product.update", attributes["event_key"])
+	assert.Equal(t, "core", attributes["source"])
+	assert.Equal(t, "Product", attributes["target_type"])
+	assert.Equal(t, "trace_1", attributes["trace_id"])
+}
+
+func TestSnsSender_SendEvent_Compression(t *testing.T) {
+	sender := NewSnsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+
+	sns := new(snsMock)
+	sns.On("PublishWithContext", mock.Anything).Return(nil)
+	sender.DefaultClient = sns
+
+	config := models.Configuration{Name: "compressed", Compression: helpers.CompressionGzip, CompressionThreshold: 400}
+	event := models.IncomingEvent{Key: "product.update", Payload: map[string]interface{}{"description": strings.Repeat("a", 1000)}}
+	assert.Nil(t, sender.SendEvent(context.Background(), event, models.Destination{Config: config}))
+
+	input := sns.PublishedMessages[0]
+	assert.Equal(t, "gzip+base64", *input.MessageAttributes[SnsContentEncodingAttribute].StringValue)
+	compressed, err := base64.StdEncoding.DecodeString(*input.Message)
+	assert.Nil(t, err)
+	body, err := helpers.Decompress(compressed, helpers.CompressionGzip)
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), `"event_key":"product.update"`)
+}
+
+func TestSnsSender_GetClient_UseAccessKey_WithCorrectAwsConfig(t *testing.T) {
+	sender := NewSnsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+
+	os.Setenv("HOOK_SNS_DESTINATION_SNS_SENDER_USE_CUSTOM_CONFIG", "true")
+	os.Setenv("HOOK_SNS_DESTINATION_SNS_SENDER_AWS_ENDPOINT", "http://localhost:4566")
+	os.Setenv("HOOK_SNS_DESTINATION_SNS_SENDER_AWS_REGION", "ap-southeast-1")
+	os.Setenv("HOOK_SNS_DESTINATION_SNS_SENDER_AWS_ACCESS_KEY_ID", "MY_ACCESS_KEY_ID")
+	os.Setenv("HOOK_SNS_DESTINATION_SNS_SENDER_AWS_SECRET_ACCESS_KEY", "MY_SECRET_ACCESS_KEY")
+	defer func() {
+		for _, key := range []string{"USE_CUSTOM_CONFIG", "AWS_ENDPOINT", "AWS_REGION", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"} {
+			os.Unsetenv("HOOK_SNS_DESTINATION_SNS_SENDER_" + key)
+		}
+	}()
+
+	destination := models.Destination{Config: models.Configuration{Name: "sns_destination"}}
+	client := sender.GetClient(destination)
+
+	snsClient, _ := (client).(*aws_sns.SNS)
+	credentials, _ := snsClient.Config.Credentials.Get()
+
+	assert.Equal(t, "ap-southeast-1", *snsClient.Config.Region)
+	assert.Equal(t, "http://localhost:4566", *snsClient.Config.Endpoint)
+	assert.Equal(t, "MY_ACCESS_KEY_ID", credentials.AccessKeyID)
+	assert.Equal(t, "MY_SECRET_ACCESS_KEY", credentials.SecretAccessKey)
+	assert.Same(t, client, sender.GetClient(destination))
+	assert.NotSame(t, client, sender.DefaultClient)
+}
+
+func TestSnsSender_GetClient_ZeroValue(t *testing.T) {
+	sender := &SnsSender{}
+	client := sender.GetClient(models.Destination{Config: models.Configuration{Name: "default"}})
+
+	assert.NotNil(t, client)
+	assert.Equal(t, client, sender.DefaultClient)
+}
@@ -2,15 +2,21 @@ package senders_test
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	aws "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	aws_sqs "github.com/aws/aws-sdk-go/service/sqs"
 	aws_sqsiface "github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	captin_errors "github.com/shoplineapp/captin/v2/errors"
+	"github.com/shoplineapp/captin/v2/internal/helpers"
 	models "github.com/shoplineapp/captin/v2/models"
 	. "github.com/shoplineapp/captin/v2/senders"
 	"github.com/stretchr/testify/assert"
@@ -22,6 +28,35 @@ type sqsMock struct {
 	mock.Mock
 
 	SentMessages []aws_sqs.SendMessageInput
+
+	muBatches   sync.Mutex
+	SentBatches []aws_sqs.SendMessageBatchInput
+}
+
+func (s *sqsMock) SendMessageBatchWithContext(ctx context.Context, input *aws_sqs.SendMessageBatchInput, _ ...request.Option) (*aws_sqs.SendMessageBatchOutput, error) {
+	s.muBatches.Lock()
+	s.SentBatches = append(s.SentBatches, *input)
+	s.muBatches.Unlock()
+
+	output := &aws_sqs.SendMessageBatchOutput{}
+	for _, entry := range input.Entries {
+		payload := map[string]interface{}{}
+		json.Unmarshal([]byte(*entry.MessageBody), &payload)
+
+		result := ""
+		if control, ok := payload["control"].(map[string]interface{}); ok {
+			result, _ = control["result"].(string)
+		}
+		switch result {
+		case "failed":
+			output.Failed = append(output.Failed, &aws_sqs.BatchResultErrorEntry{Id: entry.Id, Code: aws.String("InternalError"), Message: aws.String("some error"), SenderFault: aws.Bool(false)})
+		case "invalid":
+			output.Failed = append(output.Failed, &aws_sqs.BatchResultErrorEntry{Id: entry.Id, Code: aws.String("InvalidParameterValue"), Message: aws.String("invalid message"), SenderFault: aws.Bool(true)})
+		default:
+			output.Successful = append(output.Successful, &aws_sqs.SendMessageBatchResultEntry{Id: entry.Id})
+		}
+	}
+	return output, nil
 }
 
 func (s *sqsMock) SendMessageWithContext(ctx context.Context, input *aws_sqs.SendMessageInput, _ ...request.Option) (*aws_sqs.SendMessageOutput, error) {
@@ -136,3 +171,221 @@ func TestSqsSender_SendEvent_UseAccessKey_Success(t *testing.T) {
 	assert.Nil(t, result)
 	sqs.AssertNumberOfCalls(t, "SendMessageWithContext", 1)
 }
+
+func TestSqsSender_SendEvent_Compression(t *testing.T) {
+	awsConfig := aws.Config{Region: aws.String("ap-southeast-1")}
+	sender := NewSqsSender(awsConfig)
+
+	sqs := new(sqsMock)
+	sqs.On("SendMessageWithContext", mock.Anything, mock.Anything).Return(nil)
+	sender.DefaultClient = sqs
+
+	config := models.Configuration{Name: "compressed", Compression: helpers.CompressionGzip, CompressionThreshold: 400}
+	event := models.IncomingEvent{Key: "product.update", Payload: map[string]interface{}{"description": strings.Repeat("a", 1000)}}
+	assert.Nil(t, sender.SendEvent(context.Background(), event, models.Destination{Config: config}))
+
+	input := sqs.SentMessages[0]
+	assert.Equal(t, "gzip+base64", *input.MessageAttributes[SqsContentEncodingAttribute].StringValue)
+	compressed, err := base64.StdEncoding.DecodeString(*input.MessageBody)
+	assert.Nil(t, err)
+	body, err := helpers.Decompress(compressed, helpers.CompressionGzip)
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), `"event_key":"product.update"`)
+
+	// Small message is sent as is
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{Key: "product.update"}, models.Destination{Config: config}))
+	assert.Nil(t, sqs.SentMessages[1].MessageAttributes[SqsContentEncodingAttribute])
+	assert.Contains(t, *sqs.SentMessages[1].MessageBody, `"event_key":"product.update"`)
+}
+
+func TestSqsSender_SendEvent_FifoAndAttributes(t *testing.T) {
+	awsConfig := aws.Config{Region: aws.String("ap-southeast-1")}
+	sender := NewSqsSender(awsConfig)
+
+	sqs := new(sqsMock)
+	sqs.On("SendMessageWithContext", mock.Anything, mock.Anything).Return(nil)
+	sender.DefaultClient = sqs
+
+	config := models.Configuration{
+		Name:            "fifo",
+		CallbackURL:     "https://sqs.ap-southeast-1.amazonaws.com/000000000000/queue.fifo",
+		MessageGroupID:  "{{ .target_id }}",
+		DeduplicationID: "{{ .trace_id }}-{{ .hook }}",
+	}
+	event := models.IncomingEvent{
+		TraceId:    "trace_1",
+		Key:        "product.update",
+		Source:     "core",
+		TargetType: "Product",
+		TargetId:   "product_1",
+	}
+	event.DistributedTracingInfo.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	assert.Nil(t, sender.SendEvent(context.Background(), event, models.Destination{Config: config}))
+
+	input := sqs.SentMessages[0]
+	assert.Equal(t, "https://sqs.ap-southeast-1.amazonaws.com/000000000000/queue.fifo", *input.QueueUrl)
+	assert.Equal(t, "product_1", *input.MessageGroupId)
+	assert.Equal(t, "trace_1-fifo", *input.MessageDeduplicationId)
+	assert.Nil(t, input.DelaySeconds)
+
+	attributes := map[string]string{}
+	for key, value := range input.MessageAttributes {
+		assert.Equal(t, "String", *value.DataType)
+		attributes[key] = *value.StringValue
+	}
+	assert.Equal(t, "product.update", attributes["event_key"])
+	assert.Equal(t, "core", attributes["source"])
+	assert.Equal(t, "Product", attributes["target_type"])
+	assert.Equal(t, "trace_1", attributes["trace_id"])
+	assert.NotEmpty(t, attributes["traceparent"])
+}
+
+func TestSqsSender_SendEvent_LongMessageID(t *testing.T) {
+	awsConfig := aws.Config{Region: aws.String("ap-southeast-1")}
+	sender := NewSqsSender(awsConfig)
+
+	sqs := new(sqsMock)
+	sqs.On("SendMessageWithContext", mock.Anything, mock.Anything).Return(nil)
+	sender.DefaultClient = sqs
+
+	config := models.Configuration{Name: "fifo", MessageGroupID: "{{ .target_id }}"}
+	event := models.IncomingEvent{TargetId: strings.Repeat("a", 200)}
+	assert.Nil(t, sender.SendEvent(context.Background(), event, models.Destination{Config: config}))
+	assert.Len(t, *sqs.SentMessages[0].MessageGroupId, 64)
+}
+
+func TestSqsSender_SendEvent_NativeDelay(t *testing.T) {
+	awsConfig := aws.Config{Region: aws.String("ap-southeast-1")}
+	sender := NewSqsSender(awsConfig)
+
+	sqs := new(sqsMock)
+	sqs.On("SendMessageWithContext", mock.Anything, mock.Anything).Return(nil)
+	sender.DefaultClient = sqs
+
+	destination := models.Destination{Config: models.Configuration{Name: "delayed", Sender: models.SenderSqs, Delay: "90s", SqsNativeDelay: true}}
+	assert.False(t, destination.RequireDelay(models.IncomingEvent{}))
+
+	// Hooks of other senders are delayed by dispatcher
+	other := models.Destination{Config: models.Configuration{Name: "delayed", Sender: "sns", Delay: "90s", SqsNativeDelay: true}}
+	assert.True(t, other.RequireDelay(models.IncomingEvent{}))
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{}, destination))
+	assert.Equal(t, int64(90), *sqs.SentMessages[0].DelaySeconds)
+
+	// Event already delayed is sent immediately
+	delayed := models.IncomingEvent{Control: map[string]interface{}{"outstanding_delay_seconds": "0"}}
+	assert.Nil(t, sender.SendEvent(context.Background(), delayed, destination))
+	assert.Nil(t, sqs.SentMessages[1].DelaySeconds)
+}
+
+func sendSqsEventsConcurrently(sender *SqsSender, events []models.IncomingEvent) []error {
+	results := make([]error, len(events))
+	wg := sync.WaitGroup{}
+	for i, event := range events {
+		wg.Add(1)
+		go func(i int, event models.IncomingEvent) {
+			defer wg.Done()
+			results[i] = sender.SendEvent(context.Background(), event, models.Destination{Config: models.Configuration{Name: "batched", CallbackURL: "https://sqs/queue"}})
+		}(i, event)
+	}
+	wg.Wait()
+	return results
+}
+
+func TestSqsSender_SendEvent_Batching(t *testing.T) {
+	sender := NewSqsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+	sqs := new(sqsMock)
+	sender.DefaultClient = sqs
+	sender.SetBatching(3, time.Hour)
+	defer sender.Close()
+
+	results := sendSqsEventsConcurrently(sender, []models.IncomingEvent{{Key: "a"}, {Key: "b"}, {Key: "c"}})
+	assert.Equal(t, []error{nil, nil, nil}, results)
+	assert.Equal(t, 1, len(sqs.SentBatches))
+	assert.Equal(t, 3, len(sqs.SentBatches[0].Entries))
+	assert.Equal(t, "https://sqs/queue", *sqs.SentBatches[0].QueueUrl)
+	assert.Equal(t, 0, len(sqs.SentMessages))
+}
+
+func TestSqsSender_SendEvent_BatchingWindow(t *testing.T) {
+	sender := NewSqsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+	sqs := new(sqsMock)
+	sender.DefaultClient = sqs
+	sender.SetBatching(10, 20*time.Millisecond)
+	defer sender.Close()
+
+	results := sendSqsEventsConcurrently(sender, []models.IncomingEvent{{Key: "a"}, {Key: "b"}})
+	assert.Equal(t, []error{nil, nil}, results)
+	assert.Equal(t, 1, len(sqs.SentBatches))
+	assert.Equal(t, 2, len(sqs.SentBatches[0].Entries))
+}
+
+func TestSqsSender_SendEvent_BatchingEntryFailures(t *testing.T) {
+	sender := NewSqsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+	sqs := new(sqsMock)
+	sender.DefaultClient = sqs
+	sender.SetBatching(3, time.Hour)
+	defer sender.Close()
+
+	results := sendSqsEventsConcurrently(sender, []models.IncomingEvent{
+		{Key: "ok"},
+		{Key: "failed", Control: map[string]interface{}{"result": "failed"}},
+		{Key: "invalid", Control: map[string]interface{}{"result": "invalid"}},
+	})
+	assert.Nil(t, results[0])
+	if assert.Error(t, results[1]) {
+		assert.Contains(t, results[1].Error(), "InternalError")
+	}
+	assert.IsType(t, &captin_errors.UnretryableError{}, results[2])
+	assert.Equal(t, "invalid", results[2].(*captin_errors.UnretryableError).Event.Key)
+}
+
+func TestSqsSender_Close_FlushBatches(t *testing.T) {
+	sender := NewSqsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+	sqs := new(sqsMock)
+	sqs.On("SendMessageWithContext", mock.Anything, mock.Anything).Return(nil)
+	sender.DefaultClient = sqs
+	sender.SetBatching(10, time.Hour)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- sender.SendEvent(context.Background(), models.IncomingEvent{Key: "pending"}, models.Destination{Config: models.Configuration{Name: "batched"}})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, sender.Close())
+	assert.Nil(t, <-result)
+	assert.Equal(t, 1, len(sqs.SentBatches))
+
+	// Messages are sent alone after close
+	assert.Nil(t, sender.SendEvent(context.Background(), models.IncomingEvent{Key: "after"}, models.Destination{Config: models.Configuration{Name: "batched"}}))
+	sqs.AssertNumberOfCalls(t, "SendMessageWithContext", 1)
+}
+
+func TestSqsSender_SendEvent_BatchingAttributeBytes(t *testing.T) {
+	sender := NewSqsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+	sqs := new(sqsMock)
+	sender.DefaultClient = sqs
+	sender.SetBatching(2, 20*time.Millisecond)
+	defer sender.Close()
+
+	// Bodies of both fit in a batch, but not with attributes
+	targetType := strings.Repeat("a", 100*1024)
+	results := sendSqsEventsConcurrently(sender, []models.IncomingEvent{{Key: "a", TargetType: targetType}, {Key: "b", TargetType: targetType}})
+	assert.Equal(t, []error{nil, nil}, results)
+	assert.Equal(t, 2, len(sqs.SentBatches))
+}
+
+func TestSqsSender_SendEvent_BatchingContextCancelled(t *testing.T) {
+	sender := NewSqsSender(aws.Config{Region: aws.String("ap-southeast-1")})
+	sqs := new(sqsMock)
+	sender.DefaultClient = sqs
+	sender.SetBatching(10, time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := sender.SendEvent(ctx, models.IncomingEvent{Key: "pending"}, models.Destination{Config: models.Configuration{Name: "batched"}})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// Entry is still flushed on close
+	assert.Nil(t, sender.Close())
+	assert.Equal(t, 1, len(sqs.SentBatches))
+}